@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Amounts are typed with the separators of the chat language, e.g. "1,234.50" in English and "1.234,50" in German.
+// Spaces and apostrophes always separate thousands, e.g. "1 234,50" or "1'234.50", and the k and m suffixes
+// multiply the amount by a thousand and a million, e.g. "1.5k"
+
+var (
+	errInvalidAmount = errors.New("invalid amount")
+	// E.g. "1.500" in English, which is 1.5 or 1500 depending on who typed it
+	errAmbiguousAmount = errors.New("ambiguous amount")
+)
+
+// groupSeparators can only separate thousands, unlike "." and "," which depend on the language
+const groupSeparators = " \u00a0\u202f'"
+
+// amountRe matches the amount at the start of a message. Spaces are only taken as a part of the amount
+// when they separate groups of three digits, otherwise the amount ends at the first space
+var amountRe = regexp.MustCompile(`^-?(?:\d{1,3}(?:[ \x{00a0}\x{202f}]\d{3})+(?:[.,]\d+)?|\d[\d.,']*)[kKmM]?`)
+
+// ungroupedAmountRe is the amount up to the first space, for when the digits after a space turn out to be
+// the start of the text, e.g. "5 100g flour"
+var ungroupedAmountRe = regexp.MustCompile(`^-?\d[\d.,']*[kKmM]?`)
+
+// parseAmount parses an amount typed in the language into hundredths
+func parseAmount(s string, l *language) (int64, error) {
+	value, err := parseDecimal(s, l)
+	if err != nil {
+		return 0, err
+	}
+
+	// Cents have no fractions, e.g. "1.50" is fine, but "1.505" is not
+	value.Mul(value, big.NewRat(100, 1))
+	if !value.IsInt() || !value.Num().IsInt64() {
+		return 0, errInvalidAmount
+	}
+	return value.Num().Int64(), nil
+}
+
+// parseDecimal parses a number typed in the language exactly, e.g. "1.5k" or "-0,333"
+func parseDecimal(s string, l *language) (*big.Rat, error) {
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	var multiplier int64 = 1
+	switch {
+	case strings.HasSuffix(s, "k"), strings.HasSuffix(s, "K"):
+		multiplier = 1000
+		s = s[:len(s)-1]
+	case strings.HasSuffix(s, "m"), strings.HasSuffix(s, "M"):
+		multiplier = 1000000
+		s = s[:len(s)-1]
+	}
+
+	integer, fraction, err := splitAmount(s, l)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := new(big.Rat).SetString(integer + "." + fraction + "0")
+	if !ok {
+		return nil, errInvalidAmount
+	}
+	value.Mul(value, big.NewRat(multiplier, 1))
+	if negative {
+		value.Neg(value)
+	}
+	return value, nil
+}
+
+// splitAmount separates the integer and the fraction digits of an amount without the sign and the suffix.
+// The decimal separator of the language is always decimal. The other one of "." and "," separates thousands
+// when it is followed by three digits, and is taken as decimal otherwise, e.g. "12,50" in English
+func splitAmount(s string, l *language) (string, string, error) {
+	decimal, other := l.decimalSeparator, "."
+	if decimal == "." {
+		other = ","
+	}
+
+	integer, fraction := s, ""
+	hasFraction := false
+	switch strings.Count(s, decimal) {
+	case 0:
+		i := strings.Index(s, other)
+		if i < 0 || strings.Count(s, other) > 1 {
+			break
+		}
+		// Thousands don't start with a zero, e.g. "0.125" is decimal in any language
+		if len(s)-i-1 != 3 || s[:i] == "0" {
+			integer, fraction, hasFraction = s[:i], s[i+1:], true
+		} else if other != l.thousandsSeparator {
+			return "", "", errAmbiguousAmount
+		}
+	case 1:
+		i := strings.Index(s, decimal)
+		integer, fraction, hasFraction = s[:i], s[i+1:], true
+		if len(fraction) == 3 && integer != "0" && !strings.ContainsAny(integer, groupSeparators+other) {
+			return "", "", errAmbiguousAmount
+		}
+	default:
+		return "", "", errInvalidAmount
+	}
+
+	if hasFraction && !isDigits(fraction) {
+		return "", "", errInvalidAmount
+	}
+
+	groups := []string{integer}
+	if strings.ContainsAny(integer, groupSeparators+other) {
+		groups = strings.Split(strings.Map(func(r rune) rune {
+			if strings.ContainsRune(groupSeparators+other, r) {
+				return '|'
+			}
+			return r
+		}, integer), "|")
+		for i, group := range groups {
+			if len(group) != 3 && (i > 0 || len(group) == 0 || len(group) > 3) {
+				return "", "", errInvalidAmount
+			}
+		}
+	}
+	integer = strings.Join(groups, "")
+	if !isDigits(integer) {
+		return "", "", errInvalidAmount
+	}
+
+	return integer, fraction, nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r > unicode.MaxASCII || !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// ambiguousAmountReply explains how to type the amount so that it can only be read one way
+func ambiguousAmountReply(chatStatus *ChatStatus) string {
+	return fmt.Sprintf(chatStatus.tr(MESSAGE_AMBIGUOUS_AMOUNT), chatStatus.localAmount(123450))
+}
+
+// parsePaymentText splits "<amount> <text>" into the amount in hundredths and the text, which may be empty.
+// The amount may be an arithmetic expression, which is returned as well to show what it came to
+func parsePaymentText(text string, l *language) (amount int64, rest string, expression string, err error) {
+	if tokens, end, ok := scanExpression(text); ok {
+		value, err := evaluateExpression(tokens, l)
+		if err != nil {
+			return 0, "", "", err
+		}
+		amount, err := roundToHundredths(value)
+		if err != nil {
+			return 0, "", "", err
+		}
+		return amount, strings.TrimSpace(text[end:]), strings.TrimSpace(text[:end]), nil
+	}
+
+	token := amountRe.FindString(text)
+	if token == "" {
+		return 0, "", "", errInvalidAmount
+	}
+	rest = text[len(token):]
+	if rest != "" && !unicode.IsSpace([]rune(rest)[0]) {
+		token = ungroupedAmountRe.FindString(text)
+		rest = text[len(token):]
+		if rest != "" && !unicode.IsSpace([]rune(rest)[0]) {
+			return 0, "", "", errInvalidAmount
+		}
+	}
+
+	amount, err = parseAmount(token, l)
+	if err != nil {
+		return 0, "", "", err
+	}
+	return amount, strings.TrimSpace(rest), "", nil
+}
+
+// withComputedAmount adds what the typed expression came to, so that a mistake in it is noticed
+func withComputedAmount(chatStatus *ChatStatus, reply string, expression string, amount int64) string {
+	if expression == "" || reply == MESSAGE_UNEXPECTED_SERVER_ERROR {
+		return reply
+	}
+	return chatStatus.tr(reply) + "\n" + fmt.Sprintf(chatStatus.tr(MESSAGE_COMPUTED_AMOUNT), expression, chatStatus.localAmount(amount))
+}
@@ -0,0 +1,72 @@
+package main
+
+import "testing"
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		language string
+		text     string
+		amount   int64
+		err      error
+	}{
+		{"en", "12", 1200, nil},
+		{"en", "12.50", 1250, nil},
+		{"en", "1,234.50", 123450, nil},
+		{"en", "-3.5", -350, nil},
+		// The other separator is decimal unless three digits follow it
+		{"en", "12,50", 1250, nil},
+		{"en", "1.500", 0, errAmbiguousAmount},
+		{"en", "0.125", 0, errInvalidAmount},
+		{"de", "1.234,50", 123450, nil},
+		{"de", "12,50", 1250, nil},
+		{"de", "1.500", 150000, nil},
+		{"de", "1,500", 0, errAmbiguousAmount},
+		{"ru", "1 234,50", 123450, nil},
+		{"ru", "1\u00a0234,50", 123450, nil},
+		{"ru", "12.50", 1250, nil},
+		{"en", "1 234.50", 123450, nil},
+		{"en", "1'234.50", 123450, nil},
+		{"en", "1.5k", 150000, nil},
+		{"en", "2K", 200000, nil},
+		{"de", "1,5m", 150000000, nil},
+		{"en", "1.2.3", 0, errInvalidAmount},
+		{"en", "12.5.0", 0, errInvalidAmount},
+		{"en", "1,23,456", 0, errInvalidAmount},
+		{"en", "12.345.678,9", 0, errInvalidAmount},
+		{"en", "1.505", 0, errAmbiguousAmount},
+		{"en", "0.505", 0, errInvalidAmount},
+		{"en", "abc", 0, errInvalidAmount},
+		{"en", "", 0, errInvalidAmount},
+		{"en", "99999999999999999", 0, errInvalidAmount},
+	}
+	for _, test := range tests {
+		amount, err := parseAmount(test.text, getLanguage(test.language))
+		if amount != test.amount || err != test.err {
+			t.Errorf("%s: parseAmount(%q) = %d, %v, expected %d, %v", test.language, test.text, amount, err, test.amount, test.err)
+		}
+	}
+}
+
+func TestParsePaymentTextAmount(t *testing.T) {
+	tests := []struct {
+		language string
+		text     string
+		amount   int64
+		rest     string
+		err      error
+	}{
+		{"ru", "1 234,50 продукты", 123450, "продукты", nil},
+		{"en", "1 234 groceries", 123400, "groceries", nil},
+		// The digits after the space are a part of the comment, not a group of thousands
+		{"en", "5 100g flour", 500, "100g flour", nil},
+		{"en", "1.5k rent", 150000, "rent", nil},
+		{"en", "12abc", 0, "", errInvalidAmount},
+		{"en", "food", 0, "", errInvalidAmount},
+	}
+	for _, test := range tests {
+		amount, rest, _, err := parsePaymentText(test.text, getLanguage(test.language))
+		if amount != test.amount || rest != test.rest || err != test.err {
+			t.Errorf("%s: parsePaymentText(%q) = %d, %q, %v, expected %d, %q, %v", test.language, test.text, amount, rest, err, test.amount, test.rest, test.err)
+		}
+	}
+}
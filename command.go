@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// command is a message starting with a slash, e.g. "/createCategory@BudgliBot groceries"
+type command struct {
+	name string
+	// Set when the command is addressed to a particular bot, as Telegram does in groups
+	botName   string
+	arguments string
+}
+
+func parseCommand(text string) (command, bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return command{}, false
+	}
+
+	word, arguments := text, ""
+	if i := strings.IndexFunc(text, unicode.IsSpace); i >= 0 {
+		word, arguments = text[:i], strings.TrimSpace(text[i:])
+	}
+	name, botName := word, ""
+	if at := strings.Index(word, "@"); at >= 0 {
+		name, botName = word[:at], word[at+1:]
+	}
+
+	return command{name: name, botName: botName, arguments: arguments}, true
+}
+
+func (c command) String() string {
+	if c.arguments == "" {
+		return c.name
+	}
+	return c.name + " " + c.arguments
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// exportData is everything an export format may need to write the payments of a sheet
+type exportData struct {
+	sheetID  string
+	settings *SheetSettings
+	payments []Payment
+	from     time.Time
+	to       time.Time
+}
+
+type exportFormat struct {
+	name      string
+	extension string
+	write     func(w io.Writer, data *exportData) error
+}
+
+var exportFormats = []*exportFormat{
+	{
+		name:      "CSV",
+		extension: ".csv",
+		write: func(w io.Writer, data *exportData) error {
+			return writePaymentsCSV(w, data.payments)
+		},
+	},
+	{
+		name:      "QIF",
+		extension: ".qif",
+		write: func(w io.Writer, data *exportData) error {
+			return writeQIF(w, data.payments)
+		},
+	},
+	{
+		name:      "OFX",
+		extension: ".ofx",
+		write:     writeOFX,
+	},
+	{
+		name:      "Ledger",
+		extension: ".ledger",
+		write:     writeLedger,
+	},
+	{
+		name:      "Beancount",
+		extension: ".beancount",
+		write:     writeBeancount,
+	},
+}
+
+func exportFormatNames() []string {
+	names := make([]string, len(exportFormats))
+	for i, format := range exportFormats {
+		names[i] = format.name
+	}
+	return names
+}
+
+// findExportFormat returns nil if there is no format with this name
+func findExportFormat(name string) *exportFormat {
+	for _, format := range exportFormats {
+		if normalizeText(format.name) == normalizeText(name) {
+			return format
+		}
+	}
+	return nil
+}
+
+var paymentsCSVHeader = []string{"date", "amount", "currency", "category", "comment", "author"}
+
+// writePaymentsCSV writes the payments one per row, preceded by a header row.
+// The author column is empty for the payments made before authors were recorded
+func writePaymentsCSV(w io.Writer, payments []Payment) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(paymentsCSVHeader); err != nil {
+		return err
+	}
+	for _, payment := range payments {
+		record := []string{
+			payment.madeTime.Format("2006-01-02 15:04:05"),
+			formatAmount(payment.amount),
+			payment.currency,
+			csvText(payment.categoryName),
+			csvText(payment.comment),
+			csvText(payment.author.name),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvText keeps the text users typed from being taken for a formula when the export is opened in a spreadsheet
+func csvText(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+// formatAmount formats an amount stored in hundredths, e.g. -1205 as "-12.05"
+func formatAmount(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
+
+const (
+	dateRangeThisMonth = MESSAGE_OPTION_THIS_MONTH
+	dateRangeLastMonth = MESSAGE_OPTION_LAST_MONTH
+	dateRangeThisYear  = MESSAGE_OPTION_THIS_YEAR
+	dateRangeAllTime   = MESSAGE_OPTION_ALL_TIME
+)
+
+var dateRangeOptions = []string{dateRangeThisMonth, dateRangeLastMonth, dateRangeThisYear, dateRangeAllTime}
+
+// parseDateRange accepts either one of dateRangeOptions or two dates "YYYY-MM-DD YYYY-MM-DD", both inclusive.
+// The returned range is [from, to)
+func parseDateRange(text string, now time.Time) (time.Time, time.Time, bool) {
+	year, month, _ := now.Date()
+	thisMonth := time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+
+	switch normalizeText(text) {
+	case normalizeText(dateRangeThisMonth):
+		return thisMonth, thisMonth.AddDate(0, 1, 0), true
+	case normalizeText(dateRangeLastMonth):
+		return thisMonth.AddDate(0, -1, 0), thisMonth, true
+	case normalizeText(dateRangeThisYear):
+		thisYear := time.Date(year, 1, 1, 0, 0, 0, 0, now.Location())
+		return thisYear, thisYear.AddDate(1, 0, 0), true
+	case normalizeText(dateRangeAllTime):
+		return time.Date(1970, 1, 1, 0, 0, 0, 0, now.Location()), now.AddDate(1, 0, 0), true
+	}
+
+	fields := strings.Fields(text)
+	if len(fields) != 2 {
+		return time.Time{}, time.Time{}, false
+	}
+	from, err := time.ParseInLocation("2006-01-02", fields[0], now.Location())
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	to, err := time.ParseInLocation("2006-01-02", fields[1], now.Location())
+	if err != nil || to.Before(from) {
+		return time.Time{}, time.Time{}, false
+	}
+
+	return from, to.AddDate(0, 0, 1), true
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPaymentsCSVFormulas(t *testing.T) {
+	madeTime := time.Date(2024, 1, 5, 12, 30, 0, 0, time.UTC)
+	payments := []Payment{
+		{amount: -1205, currency: "EUR", categoryName: "=cmd", comment: "+SUM(A1:A9)", madeTime: madeTime, author: Author{name: "@Alice"}},
+		{amount: 300, currency: "EUR", categoryName: "food", comment: "-5 discount", madeTime: madeTime, author: Author{name: "Bob"}},
+		{amount: 100, currency: "EUR", categoryName: "food", comment: "a=b", madeTime: madeTime},
+	}
+
+	var out strings.Builder
+	if err := writePaymentsCSV(&out, payments); err != nil {
+		t.Fatal(err)
+	}
+	expected := `date,amount,currency,category,comment,author
+2024-01-05 12:30:00,-12.05,EUR,'=cmd,'+SUM(A1:A9),'@Alice
+2024-01-05 12:30:00,3.00,EUR,food,'-5 discount,Bob
+2024-01-05 12:30:00,1.00,EUR,food,a=b,
+`
+	if out.String() != expected {
+		t.Errorf("got\n%s\nexpected\n%s", out.String(), expected)
+	}
+}
+
+func TestOFXCurrency(t *testing.T) {
+	madeTime := time.Date(2024, 1, 5, 12, 30, 0, 0, time.UTC)
+	data := &exportData{
+		sheetID:  "sheet",
+		settings: &SheetSettings{currency: "EUR"},
+		payments: []Payment{{id: "1", amount: 1000, currency: "USD", categoryName: "food", madeTime: madeTime}},
+		from:     madeTime,
+		to:       madeTime,
+	}
+
+	// The sheet currency has changed since the payments were made
+	var out strings.Builder
+	if err := writeOFX(&out, data); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "<CURDEF>USD</CURDEF>") {
+		t.Errorf("got\n%s\nexpected the statement to be in USD", out.String())
+	}
+
+	data.payments = append(data.payments, Payment{id: "2", amount: 500, currency: "EUR", categoryName: "food", madeTime: madeTime})
+	err := writeOFX(&out, data)
+	if mixed, ok := err.(*mixedCurrenciesError); !ok || strings.Join(mixed.currencies, ",") != "EUR,USD" {
+		t.Errorf("got %v, expected the mixed currencies to be refused", err)
+	}
+}
+
+func TestLedgerEscaping(t *testing.T) {
+	madeTime := time.Date(2024, 1, 5, 12, 30, 0, 0, time.UTC)
+	data := &exportData{
+		settings: &SheetSettings{currency: "EUR", fundingAccount: "Assets:Cash"},
+		payments: []Payment{{id: "1", amount: 1250, currency: "EUR", categoryName: "food; (drinks)", comment: "(bread;  butter) at 10:30", madeTime: madeTime, author: Author{name: "[Alice]"}}},
+	}
+
+	var out strings.Builder
+	if err := writeLedger(&out, data); err != nil {
+		t.Fatal(err)
+	}
+	expected := `2024/01/05 * bread, butter) at 10-30
+    ; payment_id: 1
+    ; author: Alice]
+    Expenses:Food-Drinks                      12.50 EUR
+    Assets:Cash
+
+`
+	if out.String() != expected {
+		t.Errorf("got\n%s\nexpected\n%s", out.String(), expected)
+	}
+}
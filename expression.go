@@ -0,0 +1,193 @@
+package main
+
+import (
+	"math/big"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Amounts may be typed as arithmetic expressions, e.g. "12.5+7.3 lunch", "3*4.99 beer" or "(120-20)/2 dinner".
+// They are evaluated with exact fractions and only the result is rounded to hundredths
+
+// Keeps the evaluation cheap whatever is typed, nesting can't be deeper than this either
+const maxExpressionTokens = 64
+
+var expressionNumberRe = regexp.MustCompile(`^\d[\d.,']*[kKmM]?`)
+
+var expressionOperators = map[rune]string{'+': "+", '-': "-", '*': "*", '×': "*", '/': "/", '÷': "/"}
+
+// scanExpression splits the arithmetic expression the text starts with into tokens. It stops at the first
+// token which can't continue the expression, the rest of the text being the category.
+// ok is false unless the text starts with an expression of at least one operator or parenthesis
+func scanExpression(text string) (tokens []string, end int, ok bool) {
+	expectOperand := true
+	depth := 0
+	operators := 0
+	completeTokens, completeOperators := 0, 0
+
+	for i := 0; i < len(text) && len(tokens) <= maxExpressionTokens; {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+
+		if expectOperand {
+			if r == '(' || r == '-' {
+				tokens = append(tokens, string(r))
+				i += size
+				if r == '(' {
+					depth++
+					operators++
+				}
+				continue
+			}
+			number := expressionNumberRe.FindString(text[i:])
+			if number == "" {
+				break
+			}
+			tokens = append(tokens, number)
+			i += len(number)
+			expectOperand = false
+		} else if r == ')' && depth > 0 {
+			tokens = append(tokens, ")")
+			i += size
+			depth--
+		} else if operator, isOperator := expressionOperators[r]; isOperator {
+			tokens = append(tokens, operator)
+			i += size
+			expectOperand = true
+			operators++
+			continue
+		} else {
+			break
+		}
+
+		if depth == 0 {
+			completeTokens, completeOperators, end = len(tokens), operators, i
+		}
+	}
+
+	if rest := text[end:]; rest != "" {
+		if r, _ := utf8.DecodeRuneInString(rest); !unicode.IsSpace(r) {
+			return nil, 0, false
+		}
+	}
+	if completeOperators == 0 || expressionDateRe.MatchString(text[:end]) {
+		return nil, 0, false
+	}
+	return tokens[:completeTokens], end, true
+}
+
+// A date such as 2024-01-05 or 5/1/2024 typed before the comment is not taken for a subtraction or a division
+var expressionDateRe = regexp.MustCompile(`^\s*\d{1,4}[-/]\d{1,2}[-/]\d{1,4}$`)
+
+type expressionParser struct {
+	tokens   []string
+	position int
+	language *language
+}
+
+// evaluateExpression computes the expression exactly, the numbers are typed the same way as amounts
+func evaluateExpression(tokens []string, l *language) (*big.Rat, error) {
+	if len(tokens) > maxExpressionTokens {
+		return nil, errInvalidAmount
+	}
+
+	p := &expressionParser{tokens: tokens, language: l}
+	value, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.position != len(p.tokens) {
+		return nil, errInvalidAmount
+	}
+	return value, nil
+}
+
+func (p *expressionParser) peek() string {
+	if p.position < len(p.tokens) {
+		return p.tokens[p.position]
+	}
+	return ""
+}
+
+func (p *expressionParser) next() string {
+	token := p.peek()
+	p.position++
+	return token
+}
+
+func (p *expressionParser) sum() (*big.Rat, error) {
+	value, err := p.product()
+	for err == nil && (p.peek() == "+" || p.peek() == "-") {
+		operator := p.next()
+		var operand *big.Rat
+		if operand, err = p.product(); err != nil {
+			break
+		}
+		if operator == "+" {
+			value.Add(value, operand)
+		} else {
+			value.Sub(value, operand)
+		}
+	}
+	return value, err
+}
+
+func (p *expressionParser) product() (*big.Rat, error) {
+	value, err := p.factor()
+	for err == nil && (p.peek() == "*" || p.peek() == "/") {
+		operator := p.next()
+		var operand *big.Rat
+		if operand, err = p.factor(); err != nil {
+			break
+		}
+		if operator == "*" {
+			value.Mul(value, operand)
+		} else if operand.Sign() == 0 {
+			err = errInvalidAmount
+		} else {
+			value.Quo(value, operand)
+		}
+	}
+	return value, err
+}
+
+func (p *expressionParser) factor() (*big.Rat, error) {
+	switch token := p.next(); token {
+	case "-":
+		value, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return value.Neg(value), nil
+	case "(":
+		value, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, errInvalidAmount
+		}
+		return value, nil
+	case "", "+", "*", "/", ")":
+		return nil, errInvalidAmount
+	default:
+		return parseDecimal(token, p.language)
+	}
+}
+
+// roundToHundredths rounds half away from zero, e.g. a bill of 100 split in 3 is 33.33
+func roundToHundredths(value *big.Rat) (int64, error) {
+	scaled := new(big.Rat).Mul(value, big.NewRat(100, 1))
+	quotient, remainder := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	if remainder.Abs(remainder).Lsh(remainder, 1).Cmp(scaled.Denom()) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(scaled.Sign())))
+	}
+	if !quotient.IsInt64() {
+		return 0, errInvalidAmount
+	}
+	return quotient.Int64(), nil
+}
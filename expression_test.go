@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestParsePaymentTextExpressions(t *testing.T) {
+	tests := []struct {
+		text       string
+		amount     int64
+		rest       string
+		expression string
+		err        error
+	}{
+		{"12.5+7.3 lunch", 1980, "lunch", "12.5+7.3", nil},
+		{"(120-20)/2 dinner", 5000, "dinner", "(120-20)/2", nil},
+		{"10 - 5 - 2 taxi", 300, "taxi", "10 - 5 - 2", nil},
+		{"12 food", 1200, "food", "", nil},
+		// Dates are not subtractions or divisions
+		{"2024-01-05 dentist", 0, "", "", errInvalidAmount},
+		{"5/1/2024 dentist", 0, "", "", errInvalidAmount},
+		{"2024-01-05", 0, "", "", errInvalidAmount},
+	}
+	for _, test := range tests {
+		amount, rest, expression, err := parsePaymentText(test.text, getLanguage("en"))
+		if amount != test.amount || rest != test.rest || expression != test.expression || err != test.err {
+			t.Errorf("parsePaymentText(%q) = %d, %q, %q, %v, expected %d, %q, %q, %v",
+				test.text, amount, rest, expression, err, test.amount, test.rest, test.expression, test.err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// In groups the sheet belongs to the whole chat, but every user runs their own flows,
+// so the multi-step state is kept per user of the chat. In private chats the user ID is the chat ID
+type chatStatusKey struct {
+	chatID int64
+	userID int64
+}
+
+func isGroupChat(chat *tgbotapi.Chat) bool {
+	return chat.IsGroup() || chat.IsSuperGroup()
+}
+
+// chatName is how a member chat is listed: a group by its title, a private chat by the name of its user
+func chatName(chat *tgbotapi.Chat, author Author) string {
+	if isGroupChat(chat) {
+		return chat.Title
+	}
+	return author.name
+}
+
+func (c *ChatStatus) inGroup() bool {
+	return c.chatID != c.author.userID
+}
+
+// isGroupAdmin tells whether the user may act as the owner of the sheets the group owns. Everyone in the group
+// shares the role of the group, but the sheet is only managed by the admins, so that e.g. any participant
+// can't delete it. The user is not an admin if Telegram can't tell
+func (h *Handler) isGroupAdmin(chatID int64, userID int64) (bool, error) {
+	member, err := h.bot.GetChatMember(tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: int(userID)})
+	if err != nil {
+		return false, err
+	}
+	return member.IsCreator() || member.IsAdministrator(), nil
+}
+
+// stripBotMention removes the "@<bot>" suffix of a command and the "@<bot>" mention the text may start with.
+// mentioned tells whether the bot was mentioned either way, and ok is false for commands meant for another bot
+func stripBotMention(text string, botUserName string) (stripped string, mentioned bool, ok bool) {
+	if cmd, isCommand := parseCommand(text); isCommand {
+		if cmd.botName == "" {
+			return text, false, true
+		}
+		if !strings.EqualFold(cmd.botName, botUserName) {
+			return text, false, false
+		}
+		return cmd.String(), true, true
+	}
+
+	fields := strings.Fields(text)
+	if len(fields) > 0 && strings.EqualFold(fields[0], "@"+botUserName) {
+		return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text), fields[0])), true, true
+	}
+	return text, false, true
+}
+
+// isAddressedToBot decides whether a group message is meant for the bot. With privacy mode on Telegram only
+// delivers these messages anyway, with privacy mode off this keeps the bot from replying to every message in the group
+func (h *Handler) isAddressedToBot(message *tgbotapi.Message, text string, mentioned bool, key chatStatusKey) bool {
+	if mentioned || strings.HasPrefix(text, "/") {
+		return true
+	}
+	if reply := message.ReplyToMessage; reply != nil && reply.From != nil && reply.From.ID == h.botUser.ID {
+		return true
+	}
+	// The user is in the middle of a flow and this is the input it asked for
+	status, ok := chatStatuses[key]
+	return ok && status.stage != None
+}
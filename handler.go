@@ -1,19 +1,42 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"log/slog"
+	"net/http"
 	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/google/uuid"
 )
 
+// botSender is the part of the Telegram bot API the handler uses, *tgbotapi.BotAPI in the bot itself
+type botSender interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	AnswerCallbackQuery(config tgbotapi.CallbackConfig) (tgbotapi.APIResponse, error)
+	GetFileDirectURL(fileID string) (string, error)
+	GetChatMember(config tgbotapi.ChatConfigWithUser) (tgbotapi.ChatMember, error)
+}
+
 type Handler struct {
-	storage *Storage
-	bot     *tgbotapi.BotAPI
+	storage Storage
+	bot     botSender
+	// The bot account itself, for its user name and ID
+	botUser tgbotapi.User
+
+	passwordAttempts *passwordAttempts
 
-	subhandlersByText  map[string]Subhandler
-	subhandlersByStage map[ChatStage]Subhandler
-	defaultSubhandler  Subhandler
+	subhandlersByText     map[string]Subhandler
+	subhandlersByStage    map[ChatStage]Subhandler
+	subhandlersByCallback map[string]Subhandler
+	defaultSubhandler     Subhandler
+	documentSubhandler    *Subhandler
 }
 
 type ChatStatus struct {
@@ -21,12 +44,59 @@ type ChatStatus struct {
 
 	stage   ChatStage
 	sheetID *string
+	// Role of the chat in the current sheet, refreshed on every message
+	role Role
+	// Telegram user who sent the message being handled
+	author Author
+	// How the chat is shown to the other members of the sheet, see chatName
+	chatName string
+	// What has been stored of the author as a participant of the current sheet, see recordParticipant
+	recordedParticipant participantRecord
+	// Language of the reply to the message being handled
+	language string
+	// Language chosen with /language for the whole chat, empty if none
+	chosenLanguage string
+	// Translated reply options of the last reply, by their normalized translation
+	offeredOptions map[string]string
 
 	// For CreateSheet* flow
 	newSheetName string
 
 	// For ConnectToSheet* flow
 	connectToSheetID string
+
+	// For SetMemberRole* flow
+	memberChatID int64
+
+	// For CreatePayment* flow
+	pendingPayment Payment
+
+	// For the payment buttons: Change category and EditPayment* flow
+	editPaymentID string
+
+	// For CreateInvite* flow
+	inviteRole Role
+
+	// For AddSharedPayment* flow, the payment itself is kept in pendingPayment. The payer is a user ID
+	sharedPaidBy int64
+
+	// For Settle* flow
+	pendingTransfers []Transfer
+
+	// Document attached to the message being handled, if any
+	document *Document
+	// Error which made the subhandler give up on the message being handled, see serverError
+	failure error
+	// When the last message or button press was handled, idle statuses are evicted
+	lastActive time.Time
+
+	// For Export* flow
+	exportFormat *exportFormat
+
+	// For Import* flow
+	importStatement *csvStatement
+	importProfile   ImportProfile
+	importPayments  []importedPayment
 }
 
 type ChatStage int
@@ -34,32 +104,162 @@ type ChatStage int
 const (
 	None ChatStage = iota
 
+	SetLanguageInputLanguage
+
 	CreateSheetInputName
 	CreateSheetInputPassword
 	ConnectToSheetInputID
 	ConnectToSheetInputPassword
+	RenameSheetInputName
+	ChangeSheetPasswordInputPassword
+	SetSheetCurrencyInputCurrency
+	SetSheetFundingAccountInputAccount
+	TransferOwnershipInputMember
+	DeleteSheetInputConfirmation
+
+	ExportInputFormat
+	ExportInputDateRange
+
+	ImportInputDateColumn
+	ImportInputAmountColumn
+	ImportInputDescriptionColumn
+	ImportInputExpensesSign
+	ImportInputConfirmation
 
 	CreateCategoryInputName
+
+	CreatePaymentInputCategory
+	EditPaymentInputAmount
+
+	AddSharedPaymentInputPayment
+	AddSharedPaymentInputPayer
+	AddSharedPaymentInputSplitMode
+	AddSharedPaymentInputShares
+	AddSharedPaymentInputAmounts
+	SettleInputConfirmation
+
+	AddRuleInputRule
+	MoveRuleInputPositions
+	DeleteRuleInputPosition
+	TestRuleInputRule
+
+	SetMemberRoleInputMember
+	SetMemberRoleInputRole
+	RemoveMemberInputMember
+
+	CreateInviteInputRole
+	CreateInviteInputUses
+	RevokeInviteInputToken
 )
 
+var chatStageNames = map[ChatStage]string{
+	None:                               "None",
+	SetLanguageInputLanguage:           "SetLanguageInputLanguage",
+	CreateSheetInputName:               "CreateSheetInputName",
+	CreateSheetInputPassword:           "CreateSheetInputPassword",
+	ConnectToSheetInputID:              "ConnectToSheetInputID",
+	ConnectToSheetInputPassword:        "ConnectToSheetInputPassword",
+	RenameSheetInputName:               "RenameSheetInputName",
+	ChangeSheetPasswordInputPassword:   "ChangeSheetPasswordInputPassword",
+	SetSheetCurrencyInputCurrency:      "SetSheetCurrencyInputCurrency",
+	SetSheetFundingAccountInputAccount: "SetSheetFundingAccountInputAccount",
+	TransferOwnershipInputMember:       "TransferOwnershipInputMember",
+	DeleteSheetInputConfirmation:       "DeleteSheetInputConfirmation",
+	ExportInputFormat:                  "ExportInputFormat",
+	ExportInputDateRange:               "ExportInputDateRange",
+	ImportInputDateColumn:              "ImportInputDateColumn",
+	ImportInputAmountColumn:            "ImportInputAmountColumn",
+	ImportInputDescriptionColumn:       "ImportInputDescriptionColumn",
+	ImportInputExpensesSign:            "ImportInputExpensesSign",
+	ImportInputConfirmation:            "ImportInputConfirmation",
+	CreateCategoryInputName:            "CreateCategoryInputName",
+	CreatePaymentInputCategory:         "CreatePaymentInputCategory",
+	EditPaymentInputAmount:             "EditPaymentInputAmount",
+	AddSharedPaymentInputPayment:       "AddSharedPaymentInputPayment",
+	AddSharedPaymentInputPayer:         "AddSharedPaymentInputPayer",
+	AddSharedPaymentInputSplitMode:     "AddSharedPaymentInputSplitMode",
+	AddSharedPaymentInputShares:        "AddSharedPaymentInputShares",
+	AddSharedPaymentInputAmounts:       "AddSharedPaymentInputAmounts",
+	SettleInputConfirmation:            "SettleInputConfirmation",
+	AddRuleInputRule:                   "AddRuleInputRule",
+	MoveRuleInputPositions:             "MoveRuleInputPositions",
+	DeleteRuleInputPosition:            "DeleteRuleInputPosition",
+	TestRuleInputRule:                  "TestRuleInputRule",
+	SetMemberRoleInputMember:           "SetMemberRoleInputMember",
+	SetMemberRoleInputRole:             "SetMemberRoleInputRole",
+	RemoveMemberInputMember:            "RemoveMemberInputMember",
+	CreateInviteInputRole:              "CreateInviteInputRole",
+	CreateInviteInputUses:              "CreateInviteInputUses",
+	RevokeInviteInputToken:             "RevokeInviteInputToken",
+}
+
+func (s ChatStage) String() string {
+	if name, ok := chatStageNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("ChatStage(%d)", int(s))
+}
+
 type ReplyExtras struct {
 	ReplyOptions []string
+	// Shown under the reply instead of the reply options, pressing one is handled by the subhandler of its callback action
+	InlineButtons [][]InlineButton
+	// Sent as a separate message right after the reply text
+	Document *Document
+}
+
+// Author is the Telegram user payments and categories are attributed to
+type Author struct {
+	userID int64
+	name   string
+	// Language of the Telegram app of the user, used unless the chat has chosen one
+	languageCode string
+}
+
+// authorFromUser prefers the full name of the user, since that is how people know each other in shared sheets
+func authorFromUser(user *tgbotapi.User) Author {
+	if user == nil {
+		return Author{}
+	}
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if name == "" {
+		name = user.UserName
+	}
+	return Author{userID: int64(user.ID), name: name, languageCode: user.LanguageCode}
+}
+
+// Document is a file either sent by the bot or uploaded by a user
+type Document struct {
+	Name    string
+	Content []byte
 }
 
-func CreateHandler(storage *Storage, bot *tgbotapi.BotAPI) *Handler {
-	h := Handler{storage: storage, bot: bot}
+func CreateHandler(storage Storage, bot botSender, botUser tgbotapi.User) *Handler {
+	h := Handler{storage: storage, bot: bot, botUser: botUser, passwordAttempts: newPasswordAttempts()}
 
 	var subhandlers []Subhandler
 	subhandlers = append(subhandlers, getInfoSubhandlers(&h)...)
 	subhandlers = append(subhandlers, getSheetSubhandlers(&h)...)
+	subhandlers = append(subhandlers, getMemberSubhandlers(&h)...)
+	subhandlers = append(subhandlers, getInviteSubhandlers(&h)...)
 	subhandlers = append(subhandlers, getCategorySubhandlers(&h)...)
+	subhandlers = append(subhandlers, getRuleSubhandlers(&h)...)
+	subhandlers = append(subhandlers, getExportSubhandlers(&h)...)
+	subhandlers = append(subhandlers, getImportSubhandlers(&h)...)
+	subhandlers = append(subhandlers, getSplitSubhandlers(&h)...)
 	subhandlers = append(subhandlers, getPaymentSubhandlers(&h)...)
 	h.subhandlersByText = make(map[string]Subhandler)
 	h.subhandlersByStage = make(map[ChatStage]Subhandler)
+	h.subhandlersByCallback = make(map[string]Subhandler)
 	defaultSubhandlerDefined := false
 	for _, subhandler := range subhandlers {
 		normalizedExpectedText := normalizeText(subhandler.expectedText)
-		if normalizedExpectedText != "" {
+		if subhandler.expectedCallback != "" {
+			h.subhandlersByCallback[subhandler.expectedCallback] = subhandler
+		} else if subhandler.expectedDocument {
+			documentSubhandler := subhandler
+			h.documentSubhandler = &documentSubhandler
+		} else if normalizedExpectedText != "" {
 			h.subhandlersByText[normalizedExpectedText] = subhandler
 		} else if subhandler.expectedStage != None {
 			h.subhandlersByStage[subhandler.expectedStage] = subhandler
@@ -74,79 +274,448 @@ func CreateHandler(storage *Storage, bot *tgbotapi.BotAPI) *Handler {
 	return &h
 }
 
+// Limits how long the storage may take to handle an update, so that the following updates aren't held up forever
+const updateTimeout = 30 * time.Second
+
 func (h *Handler) ProcessUpdate(update *tgbotapi.Update) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), updateTimeout)
+	defer cancel()
+	defer recordActiveFlows()
+
+	if update.CallbackQuery != nil {
+		h.processCallbackQuery(ctx, update.CallbackQuery)
+		return
+	}
 	if update.Message == nil {
 		return
 	}
 
-	log.Printf("[%s] %s", update.Message.From.UserName, update.Message.Text)
-
 	chatID := update.Message.Chat.ID
+	author := authorFromUser(update.Message.From)
+	key := chatStatusKey{chatID: chatID, userID: author.userID}
+	logger := chatLogger(key)
+	logger.Debug("message received", "update_id", update.UpdateID, "message_id", update.Message.MessageID)
+	inGroup := isGroupChat(update.Message.Chat)
+
+	text := update.Message.Text
+	if update.Message.Document != nil {
+		text = update.Message.Caption
+	}
+	text, mentioned, ok := stripBotMention(text, h.botUser.UserName)
+	if !ok || (inGroup && !h.isAddressedToBot(update.Message, text, mentioned, key)) {
+		return
+	}
 
-	replyText, replyExtras := h.replyToMessage(chatID, update.Message.Text)
+	var replyText string
+	var replyExtras *ReplyExtras
+	if update.Message.Document != nil {
+		document, err := h.downloadDocument(ctx, update.Message.Document)
+		if err != nil {
+			logger.Warn("failed to download document", "file_name", update.Message.Document.FileName, "error", err)
+			botMetrics.telegramErrors.add(1, "downloadDocument")
+			replyText = translate(author.languageCode, MESSAGE_FAILURE_DOWNLOAD_DOCUMENT)
+		} else {
+			replyText, replyExtras = h.replyToMessage(ctx, update.Message.Chat, author, text, document)
+		}
+	} else {
+		replyText, replyExtras = h.replyToMessage(ctx, update.Message.Chat, author, text, nil)
+	}
 	msg := tgbotapi.NewMessage(chatID, replyText)
 
-	if replyExtras == nil || len(replyExtras.ReplyOptions) == 0 {
+	if replyExtras != nil && len(replyExtras.InlineButtons) > 0 {
+		msg.ReplyMarkup = inlineKeyboardMarkup(replyExtras.InlineButtons)
+	} else if replyExtras == nil || len(replyExtras.ReplyOptions) == 0 {
 		msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+		// With privacy mode on the answer only reaches the bot if it is a reply to the question
+		if status, ok := chatStatuses[key]; inGroup && ok && status.stage != None {
+			msg.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true, Selective: true}
+		}
 	} else {
 		rows := make([][]tgbotapi.KeyboardButton, len(replyExtras.ReplyOptions))
 		for i, replyOption := range replyExtras.ReplyOptions {
 			rows[i] = tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(replyOption))
 		}
-		msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(rows...)
+		keyboard := tgbotapi.NewReplyKeyboard(rows...)
+		// Only the user who is asked sees the options
+		keyboard.Selective = inGroup
+		msg.ReplyMarkup = keyboard
+	}
+	if inGroup {
+		msg.ReplyToMessageID = update.Message.MessageID
+	}
+	if _, err := h.bot.Send(msg); err != nil {
+		logger.Error("failed to send reply", "error", err)
+		botMetrics.telegramErrors.add(1, "sendMessage")
+	}
+	botMetrics.replyDuration.observe(time.Since(start).Seconds(), "message")
+
+	if replyExtras != nil && replyExtras.Document != nil {
+		document := tgbotapi.NewDocumentUpload(chatID, tgbotapi.FileBytes{
+			Name:  replyExtras.Document.Name,
+			Bytes: replyExtras.Document.Content,
+		})
+		if _, err := h.bot.Send(document); err != nil {
+			logger.Error("failed to send document", "file_name", replyExtras.Document.Name, "error", err)
+			botMetrics.telegramErrors.add(1, "sendDocument")
+		}
+	}
+}
+
+// processCallbackQuery handles a press of an inline button. The reply replaces the message the button belongs to
+func (h *Handler) processCallbackQuery(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	start := time.Now()
+	// Stops the loading animation on the button
+	if _, err := h.bot.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, "")); err != nil {
+		slog.Warn("failed to answer callback query", "query_id", query.ID, "error", err)
+		botMetrics.telegramErrors.add(1, "answerCallbackQuery")
+	}
+	if query.Message == nil {
+		return
+	}
+
+	chatID := query.Message.Chat.ID
+	author := authorFromUser(query.From)
+	logger := chatLogger(chatStatusKey{chatID: chatID, userID: author.userID})
+	logger.Debug("callback query received", "query_id", query.ID, "message_id", query.Message.MessageID)
+
+	replyText, replyExtras := h.replyToCallback(ctx, query.Message.Chat, author, query.Data)
+
+	edit := tgbotapi.NewEditMessageText(chatID, query.Message.MessageID, replyText)
+	if replyExtras != nil && len(replyExtras.InlineButtons) > 0 {
+		markup := inlineKeyboardMarkup(replyExtras.InlineButtons)
+		edit.ReplyMarkup = &markup
+	}
+	if _, err := h.bot.Send(edit); err != nil {
+		logger.Error("failed to edit message", "message_id", query.Message.MessageID, "error", err)
+		botMetrics.telegramErrors.add(1, "editMessageText")
+	}
+	botMetrics.replyDuration.observe(time.Since(start).Seconds(), "callback")
+}
+
+// notifyChat sends a message to a chat other than the one currently being replied to
+func (h *Handler) notifyChat(chatID int64, text string) {
+	if _, err := h.bot.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+		slog.Error("failed to notify chat", "chat_id", chatID, "error", err)
+		botMetrics.telegramErrors.add(1, "sendMessage")
+	}
+}
+
+// Larger documents are not downloaded at all
+const maxDocumentSize = 1 << 20
+
+// A stalled download holds up all the following updates, and leaves less of updateTimeout to the storage
+const documentDownloadTimeout = 10 * time.Second
+
+func (h *Handler) downloadDocument(ctx context.Context, document *tgbotapi.Document) (*Document, error) {
+	if document.FileSize > maxDocumentSize {
+		return nil, fmt.Errorf("document is too large: %d bytes", document.FileSize)
+	}
+
+	url, err := h.bot.GetFileDirectURL(document.FileID)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, documentDownloadTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
 	}
-	h.bot.Send(msg)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	content, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDocumentSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Document{Name: document.FileName, Content: content}, nil
 }
 
-func (h *Handler) replyToMessage(chatID int64, text string) (string, *ReplyExtras) {
-	chatStatus, err := h.getChatStatus(chatID)
+func (h *Handler) replyToMessage(ctx context.Context, chat *tgbotapi.Chat, author Author, text string, document *Document) (string, *ReplyExtras) {
+	key := chatStatusKey{chatID: chat.ID, userID: author.userID}
+	chatStatus, err := h.getChatStatus(ctx, key)
 	if err != nil {
-		return MESSAGE_UNEXPECTED_SERVER_ERROR, nil
+		return failureReply(chatLogger(key), author.languageCode, err), nil
 	}
+	chatStatus.setAuthor(author)
+	chatStatus.chatName = chatName(chat, author)
+	chatStatus.document = document
+	text = chatStatus.delocalizeOption(text)
 
 	var sh Subhandler
-	sh, ok := h.subhandlersByText[normalizeText(text)]
+	var ok bool
+	cmd, isCommand := parseCommand(text)
+	if isCommand {
+		sh, ok = h.subhandlersByText[normalizeText(cmd.name)]
+	}
+	if document != nil {
+		if h.documentSubhandler == nil {
+			return chatStatus.tr(MESSAGE_FAILURE_UNEXPECTED_DOCUMENT), nil
+		}
+		sh, ok = *h.documentSubhandler, true
+	} else if ok && cmd.arguments != "" && !sh.withPayload {
+		return h.handleCommandWithArguments(ctx, sh, chatStatus, cmd)
+	}
 	if !ok {
 		sh, ok = h.subhandlersByStage[chatStatus.stage]
 		if !ok {
 			sh = h.defaultSubhandler
 		}
 	}
+
+	return h.handleWithSubhandler(ctx, sh, chatStatus, text)
+}
+
+// handleCommandWithArguments passes the arguments on as the input of the stage the command starts,
+// so that "/createCategory groceries" works just like "/createCategory" followed by "groceries"
+func (h *Handler) handleCommandWithArguments(ctx context.Context, sh Subhandler, chatStatus *ChatStatus, cmd command) (string, *ReplyExtras) {
+	reply, replyExtras := h.handleWithSubhandler(ctx, sh, chatStatus, cmd.name)
+	if chatStatus.stage == None {
+		return reply, replyExtras
+	}
+	stageSubhandler, ok := h.subhandlersByStage[chatStatus.stage]
+	if !ok {
+		return reply, replyExtras
+	}
+
+	return h.handleWithSubhandler(ctx, stageSubhandler, chatStatus, cmd.arguments)
+}
+
+func (h *Handler) replyToCallback(ctx context.Context, chat *tgbotapi.Chat, author Author, data string) (string, *ReplyExtras) {
+	key := chatStatusKey{chatID: chat.ID, userID: author.userID}
+	chatStatus, err := h.getChatStatus(ctx, key)
+	if err != nil {
+		return failureReply(chatLogger(key), author.languageCode, err), nil
+	}
+	chatStatus.setAuthor(author)
+	chatStatus.chatName = chatName(chat, author)
+	chatStatus.document = nil
+
+	action, argument := parseCallbackData(data)
+	sh, ok := h.subhandlersByCallback[action]
+	if !ok {
+		return chatStatus.tr(MESSAGE_FAILURE_EXPIRED_BUTTON), nil
+	}
+
+	return h.handleWithSubhandler(ctx, sh, chatStatus, argument)
+}
+
+// handleWithSubhandler checks the chat may use the subhandler in its current sheet before handling the text
+func (h *Handler) handleWithSubhandler(ctx context.Context, sh Subhandler, chatStatus *ChatStatus, text string) (string, *ReplyExtras) {
+	logger := chatLogger(chatStatusKey{chatID: chatStatus.chatID, userID: chatStatus.author.userID}).With("subhandler", sh.name())
+	logger.Info("handling message", "stage", chatStatus.stage.String(), "input", loggedInput(chatStatus.stage, sh, text))
+
+	chatStatus.role = RoleNone
+	if chatStatus.sheetID != nil {
+		role, err := h.storage.GetSheetMemberRole(ctx, *chatStatus.sheetID, chatStatus.chatID)
+		if err != nil {
+			botMetrics.updates.add(1, sh.name(), "failure")
+			return failureReply(logger, chatStatus.language, err), nil
+		}
+		chatStatus.role = role
+		// The chat has been removed from the sheet since it connected to it
+		if chatStatus.role == RoleNone {
+			chatStatus.sheetID = nil
+			chatStatus.recordedParticipant = participantRecord{}
+		}
+	}
 	if !sh.sheetOptional && chatStatus.sheetID == nil {
-		return "You are not yet connected to a sheet. Please either create a new one or connect to an existing one.\n" +
-			"/createSheet /connectSheet", nil
+		botMetrics.updates.add(1, sh.name(), "not_connected")
+		return chatStatus.tr(MESSAGE_NOT_CONNECTED_TO_SHEET), nil
+	}
+	if chatStatus.role < sh.requiredRole {
+		botMetrics.updates.add(1, sh.name(), "denied")
+		chatStatus.stage = None
+		return fmt.Sprintf(chatStatus.tr(MESSAGE_FAILURE_PERMISSION_DENIED), chatStatus.roleName(sh.requiredRole)), nil
+	}
+	if sh.requiredRole == RoleOwner && chatStatus.inGroup() {
+		admin, err := h.isGroupAdmin(chatStatus.chatID, chatStatus.author.userID)
+		if err != nil {
+			logger.Warn("failed to get chat member", "error", err)
+			botMetrics.telegramErrors.add(1, "getChatMember")
+		}
+		if !admin {
+			botMetrics.updates.add(1, sh.name(), "denied")
+			chatStatus.stage = None
+			return chatStatus.tr(MESSAGE_FAILURE_GROUP_ADMINS_ONLY), nil
+		}
 	}
 
 	var replyExtras ReplyExtras
-	return sh.handle(text, chatStatus, &replyExtras), &replyExtras
+	chatStatus.failure = nil
+	reply := sh.handle(ctx, text, chatStatus, &replyExtras)
+	if chatStatus.failure != nil {
+		botMetrics.updates.add(1, sh.name(), "failure")
+		return failureReply(logger, chatStatus.language, chatStatus.failure), nil
+	}
+	botMetrics.updates.add(1, sh.name(), "ok")
+	h.recordParticipant(ctx, logger, chatStatus)
+	chatStatus.localizeReplyExtras(&replyExtras)
+	// Replies composed of several messages are already translated and are returned as they are
+	return chatStatus.tr(reply), &replyExtras
+}
+
+// serverError is what a subhandler returns when it can't go on because of the error. The reply to the user
+// is then chosen by the kind of the error, see failureReply
+func (c *ChatStatus) serverError(err error) string {
+	c.failure = err
+	return MESSAGE_UNEXPECTED_SERVER_ERROR
+}
+
+// failureReply tells the user whether trying again may help. The error is logged with a short ID
+// which the user is shown as well, so that what they report can be found in the log
+func failureReply(logger *slog.Logger, language string, err error) string {
+	errorID := strings.SplitN(uuid.New().String(), "-", 2)[0]
+	logger.Error("failed to handle message", "error_id", errorID, "error", err)
+
+	message := MESSAGE_UNEXPECTED_SERVER_ERROR
+	switch {
+	case errors.Is(err, errTransient):
+		message = MESSAGE_FAILURE_TRY_AGAIN
+	case errors.Is(err, errNotFound):
+		message = MESSAGE_FAILURE_NOT_FOUND
+	case errors.Is(err, errDuplicate):
+		message = MESSAGE_FAILURE_DUPLICATE
+	case errors.Is(err, errConstraint):
+		message = MESSAGE_FAILURE_CONSTRAINT
+	}
+	return translate(language, message) + "\n" + fmt.Sprintf(translate(language, MESSAGE_ERROR_ID), errorID)
 }
 
 func normalizeText(text string) string {
 	return strings.TrimSpace(strings.ToLower(text))
 }
 
-var chatStatuses = make(map[int64]*ChatStatus)
+var chatStatuses = make(map[chatStatusKey]*ChatStatus)
+
+const (
+	// A flow which hasn't been continued for this long is forgotten, the sheet and the language are reloaded
+	chatStatusIdleTimeout   = 24 * time.Hour
+	chatStatusSweepInterval = time.Hour
+)
+
+var lastChatStatusSweep time.Time
+
+// evictIdleChatStatuses keeps chatStatuses from growing with every user of every group the bot has seen
+func evictIdleChatStatuses(now time.Time) {
+	if now.Sub(lastChatStatusSweep) < chatStatusSweepInterval {
+		return
+	}
+	lastChatStatusSweep = now
+
+	for key, status := range chatStatuses {
+		if now.Sub(status.lastActive) > chatStatusIdleTimeout {
+			delete(chatStatuses, key)
+		}
+	}
+}
 
-func (h *Handler) getChatStatus(chatID int64) (*ChatStatus, error) {
-	if status, ok := chatStatuses[chatID]; ok {
+func (h *Handler) getChatStatus(ctx context.Context, key chatStatusKey) (*ChatStatus, error) {
+	now := time.Now()
+	evictIdleChatStatuses(now)
+	if status, ok := chatStatuses[key]; ok {
+		status.lastActive = now
 		return status, nil
 	}
 
-	currentSheetID, err := h.storage.FetchCurrentSheetFromDB(chatID)
+	currentSheetID, err := h.storage.FetchCurrentSheetFromDB(ctx, key.chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	chosenLanguage, err := h.storage.GetChatLanguage(ctx, key.chatID)
 	if err != nil {
 		return nil, err
 	}
 
-	status := &ChatStatus{chatID: chatID, sheetID: currentSheetID}
-	chatStatuses[chatID] = status
+	status := &ChatStatus{chatID: key.chatID, sheetID: currentSheetID, chosenLanguage: chosenLanguage, lastActive: now}
+	chatStatuses[key] = status
 	return status, nil
 }
 
+func (c *ChatStatus) setAuthor(author Author) {
+	c.author = author
+	c.language = c.chosenLanguage
+	if c.language == "" {
+		c.language = getLanguage(author.languageCode).code
+	}
+}
+
+// participantRecord is what recordParticipant has stored of the author
+type participantRecord struct {
+	sheetID  string
+	chatName string
+	userName string
+}
+
+// recordParticipant stores the author as a participant of the sheet the chat works with, so that the shared payments
+// are split between users and the members are listed by name. It is only stored again when the sheet or a name changes
+func (h *Handler) recordParticipant(ctx context.Context, logger *slog.Logger, chatStatus *ChatStatus) {
+	if chatStatus.sheetID == nil || chatStatus.author.userID == 0 {
+		return
+	}
+	record := participantRecord{sheetID: *chatStatus.sheetID, chatName: chatStatus.chatName, userName: chatStatus.author.name}
+	if record == chatStatus.recordedParticipant {
+		return
+	}
+	// The reply doesn't depend on it, the next message tries again
+	if err := h.storage.RecordSheetParticipant(ctx, record.sheetID, chatStatus.chatID, record.chatName, chatStatus.author); err != nil {
+		logger.Warn("failed to record sheet participant", "error", err)
+		return
+	}
+	chatStatus.recordedParticipant = record
+}
+
+// setChatLanguage changes the language for all the users of the chat, like setChatSheet
+func setChatLanguage(chatID int64, language string) {
+	for key, status := range chatStatuses {
+		if key.chatID == chatID {
+			status.chosenLanguage = language
+			status.language = language
+		}
+	}
+}
+
+// chatLanguage is the language to notify a chat in, when there is no message from it to tell the language of its user
+func (h *Handler) chatLanguage(ctx context.Context, chatID int64) string {
+	language, err := h.storage.GetChatLanguage(ctx, chatID)
+	if err != nil || language == "" {
+		return defaultLanguage
+	}
+	return language
+}
+
+// setChatSheet changes the current sheet for all the users of the chat, in groups they share it
+func setChatSheet(chatID int64, sheetID *string) {
+	for key, status := range chatStatuses {
+		if key.chatID == chatID {
+			status.sheetID = sheetID
+		}
+	}
+}
+
 type Subhandler struct {
 	expectedText  string
 	expectedStage ChatStage
+	// Whether the command handles its arguments itself, e.g. "/start <token>" coming from a deep link.
+	// Arguments of other commands are the input of the stage the command starts
+	withPayload bool
+	// Handles all the messages with a document attached, the document is available in ChatStatus
+	expectedDocument bool
+	// Handles the presses of inline buttons with this callback action, the text is the callback argument
+	expectedCallback string
 
 	sheetOptional bool
+	// The minimal role in the current sheet the chat must have, only checked if set
+	requiredRole Role
 
-	handle func(text string, status *ChatStatus, replyExtras *ReplyExtras) string
+	handle func(ctx context.Context, text string, status *ChatStatus, replyExtras *ReplyExtras) string
 }
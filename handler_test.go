@@ -0,0 +1,570 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+var createdSheetIDRegexp = regexp.MustCompile(`ID: (\S+)`)
+
+// createSheet goes through /createSheet and returns the ID of the new sheet
+func createSheet(u *chatUser, name string, password string) string {
+	u.c.t.Helper()
+
+	u.script(
+		step{"/createSheet", MESSAGE_INPUT_NEW_SHEET_NAME},
+		step{name, MESSAGE_INPUT_NEW_SHEET_PASSWORD},
+	)
+	reply := u.send(password)
+	if expected := fmt.Sprintf(MESSAGE_CREATED_NEW_SHEET, name, ""); !strings.HasPrefix(reply.text, expected) {
+		u.c.t.Fatalf("got reply %q, expected the sheet to be created", reply.text)
+	}
+	return createdSheetIDRegexp.FindStringSubmatch(reply.text)[1]
+}
+
+// paymentAmounts returns the amounts of all the payments of the sheet, oldest first
+func paymentAmounts(c *conversation, sheetID string) []int64 {
+	c.t.Helper()
+
+	payments, err := c.storage.ListPayments(context.Background(), sheetID, time.Time{}, time.Now().Add(time.Hour))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	var amounts []int64
+	for _, payment := range payments {
+		amounts = append(amounts, payment.amount)
+	}
+	return amounts
+}
+
+func TestCreateSheet(t *testing.T) {
+	c := newConversation(t)
+	alice := c.privateChat(100, "Alice")
+
+	alice.script(
+		step{"/createSheet", MESSAGE_INPUT_NEW_SHEET_NAME},
+		step{"Home", MESSAGE_INPUT_NEW_SHEET_PASSWORD},
+		step{"ab", MESSAGE_INCORRECT_NEW_PASSWORD_TOO_SHORT},
+	)
+	reply := alice.send("secret")
+	match := createdSheetIDRegexp.FindStringSubmatch(reply.text)
+	if match == nil {
+		t.Fatalf("got reply %q, expected the ID of the new sheet", reply.text)
+	}
+	sheetID := match[1]
+	if expected := fmt.Sprintf(MESSAGE_CREATED_NEW_SHEET, "Home", sheetID); reply.text != expected {
+		t.Errorf("got reply %q, expected %q", reply.text, expected)
+	}
+
+	ctx := context.Background()
+	if role, _ := c.storage.GetSheetMemberRole(ctx, sheetID, alice.chatID()); role != RoleOwner {
+		t.Errorf("creator has role %v, expected %v", role, RoleOwner)
+	}
+	if currentSheetID, _ := c.storage.FetchCurrentSheetFromDB(ctx, alice.chatID()); currentSheetID == nil || *currentSheetID != sheetID {
+		t.Errorf("creator is connected to %v, expected the new sheet", currentSheetID)
+	}
+	if ok, _ := c.storage.CheckPassword(ctx, sheetID, "secret"); !ok {
+		t.Error("the password of the new sheet doesn't match")
+	}
+}
+
+func TestConnectSheet(t *testing.T) {
+	c := newConversation(t)
+	alice := c.privateChat(100, "Alice")
+	bob := c.privateChat(200, "Bob")
+	sheetID := createSheet(alice, "Home", "secret")
+
+	bob.script(
+		step{"5 food", MESSAGE_NOT_CONNECTED_TO_SHEET},
+		step{"/connectSheet", MESSAGE_INPUT_SHEET_ID},
+		step{"not a sheet", MESSAGE_INCORRECT_SHEET_ID_FORMAT},
+		step{sheetID, MESSAGE_INPUT_SHEET_PASSWORD},
+		step{"wrong", MESSAGE_INCORRECT_PASSWORD},
+		step{"/connectSheet", MESSAGE_INPUT_SHEET_ID},
+		step{sheetID, MESSAGE_INPUT_SHEET_PASSWORD},
+		step{"secret", MESSAGE_SUCCESS_CONNECT_TO_SHEET},
+	)
+	if role, _ := c.storage.GetSheetMemberRole(context.Background(), sheetID, bob.chatID()); role != RoleEditor {
+		t.Errorf("connected chat has role %v, expected %v", role, RoleEditor)
+	}
+
+	// Members choose the sheet from the options without the password
+	alice.script(step{"/disconnectSheet", MESSAGE_SUCCESS_DISCONNECT_SHEET})
+	reply := alice.send("/connectSheet")
+	if expected := []string{sheetID + " (Home)"}; !reflect.DeepEqual(reply.options, expected) {
+		t.Fatalf("got options %q, expected %q", reply.options, expected)
+	}
+	alice.script(step{reply.options[0], MESSAGE_SUCCESS_CONNECT_TO_SHEET})
+}
+
+func TestCreatePayment(t *testing.T) {
+	c := newConversation(t)
+	alice := c.privateChat(100, "Alice")
+	sheetID := createSheet(alice, "Home", "secret")
+
+	alice.script(
+		step{"/createCategory", MESSAGE_INPUT_CATEGORY_NAME},
+		step{"Food", MESSAGE_SUCCESS_CREATE_CATEGORY},
+		step{"12.50 food", MESAGE_SUCCESS_CREATE_PAYMENT},
+	)
+	if amounts := paymentAmounts(c, sheetID); !reflect.DeepEqual(amounts, []int64{1250}) {
+		t.Fatalf("got payments %v, expected the new one", amounts)
+	}
+
+	if reply := alice.press(MESSAGE_BUTTON_UNDO); reply.text != fmt.Sprintf(MESSAGE_SUCCESS_UNDO_PAYMENT, "12.50", "Food") {
+		t.Errorf("got reply %q after undo", reply.text)
+	}
+	if amounts := paymentAmounts(c, sheetID); len(amounts) != 0 {
+		t.Fatalf("got payments %v, expected none after undo", amounts)
+	}
+
+	reply := alice.send("2*3.5 Food")
+	if expected := MESAGE_SUCCESS_CREATE_PAYMENT + "\n" + fmt.Sprintf(MESSAGE_COMPUTED_AMOUNT, "2*3.5", "7.00"); reply.text != expected {
+		t.Errorf("got reply %q, expected %q", reply.text, expected)
+	}
+
+	alice.script(
+		step{"1.500 food", fmt.Sprintf(MESSAGE_AMBIGUOUS_AMOUNT, "1,234.50")},
+		step{"12.50", MESSAGE_FAILURE_UNKNOWN_CATEGORY_NAME},
+		step{"2024-01-05 food", MESSAGE_FAILURE_PARSING},
+		// Nothing is saved until every line is fixed
+		step{"10 food\n5 groceries", "1 line could not be read"},
+		step{"10 food\n5.5 food", "Successfully created 2 payment records"},
+	)
+	if amounts := paymentAmounts(c, sheetID); !reflect.DeepEqual(amounts, []int64{700, 1000, 550}) {
+		t.Errorf("got payments %v", amounts)
+	}
+}
+
+func TestViewerCannotCreatePayment(t *testing.T) {
+	c := newConversation(t)
+	alice := c.privateChat(100, "Alice")
+	bob := c.privateChat(200, "Bob")
+	sheetID := createSheet(alice, "Home", "secret")
+
+	ctx := context.Background()
+	if err := c.storage.AddSheetMember(ctx, sheetID, bob.chatID(), RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	bob.script(
+		step{"/connectSheet " + sheetID, MESSAGE_SUCCESS_CONNECT_TO_SHEET},
+		step{"5 food", fmt.Sprintf(MESSAGE_FAILURE_PERMISSION_DENIED, RoleEditor)},
+	)
+	if amounts := paymentAmounts(c, sheetID); len(amounts) != 0 {
+		t.Errorf("got payments %v, expected none", amounts)
+	}
+}
+
+// failingStorage fails to insert categories, everything else is left to the storage it wraps
+type failingStorage struct {
+	Storage
+	err error
+}
+
+func (s *failingStorage) InsertNewCategory(ctx context.Context, sheetID string, id string, name string, author Author) error {
+	return s.err
+}
+
+var errorIDRegexp = regexp.MustCompile(`\n` + fmt.Sprintf(regexp.QuoteMeta(MESSAGE_ERROR_ID), `[0-9a-f]{8}`) + `$`)
+
+func TestStorageFailure(t *testing.T) {
+	c := newConversation(t)
+	alice := c.privateChat(100, "Alice")
+	createSheet(alice, "Home", "secret")
+
+	storage := &failingStorage{Storage: c.storage}
+	c.handler.storage = storage
+	for _, test := range []struct {
+		err      error
+		expected string
+	}{
+		{&storageError{kind: errTransient, err: errors.New("deadlock")}, MESSAGE_FAILURE_TRY_AGAIN},
+		{&storageError{kind: errConstraint, err: errors.New("data too long")}, MESSAGE_FAILURE_CONSTRAINT},
+		{errors.New("unknown"), MESSAGE_UNEXPECTED_SERVER_ERROR},
+	} {
+		storage.err = test.err
+		alice.script(step{"/createCategory", MESSAGE_INPUT_CATEGORY_NAME})
+		reply := alice.send("Food")
+		if !strings.HasPrefix(reply.text, test.expected) || !errorIDRegexp.MatchString(reply.text) {
+			t.Errorf("%v: got reply %q, expected %q and the error code", test.err, reply.text, test.expected)
+		}
+	}
+}
+
+// ruleCategories returns the categories of the rules by their positions
+func ruleCategories(c *conversation, sheetID string) map[int]string {
+	c.t.Helper()
+
+	rules, err := c.storage.ListRules(context.Background(), sheetID)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	categories := make(map[int]string)
+	for _, rule := range rules {
+		categories[rule.position] = rule.categoryName
+	}
+	return categories
+}
+
+func TestDeleteAndMoveRule(t *testing.T) {
+	c := newConversation(t)
+	alice := c.privateChat(100, "Alice")
+	sheetID := createSheet(alice, "Home", "secret")
+
+	for _, category := range []string{"Food", "Rent", "Fun"} {
+		alice.script(
+			step{"/createCategory", MESSAGE_INPUT_CATEGORY_NAME},
+			step{category, MESSAGE_SUCCESS_CREATE_CATEGORY},
+			step{"/addRule", MESSAGE_INPUT_RULE},
+			step{"description contains " + category + " -> " + category, MESSAGE_SUCCESS_ADD_RULE},
+		)
+	}
+
+	alice.script(
+		step{"/deleteRule", MESSAGE_INPUT_RULE_POSITION},
+		step{"1", MESSAGE_SUCCESS_DELETE_RULE},
+	)
+	if categories := ruleCategories(c, sheetID); !reflect.DeepEqual(categories, map[int]string{1: "Rent", 2: "Fun"}) {
+		t.Fatalf("got rules %v after deleting the first one", categories)
+	}
+
+	alice.script(
+		step{"/moveRule", MESSAGE_INPUT_RULE_POSITIONS},
+		step{"2 1", MESSAGE_SUCCESS_MOVE_RULE},
+		step{"/deleteRule", MESSAGE_INPUT_RULE_POSITION},
+		step{"2", MESSAGE_SUCCESS_DELETE_RULE},
+	)
+	if categories := ruleCategories(c, sheetID); !reflect.DeepEqual(categories, map[int]string{1: "Fun"}) {
+		t.Errorf("got rules %v, expected the moved rule to be left", categories)
+	}
+}
+
+func TestPaymentKeepsCurrency(t *testing.T) {
+	c := newConversation(t)
+	alice := c.privateChat(100, "Alice")
+	sheetID := createSheet(alice, "Home", "secret")
+
+	alice.script(
+		step{"/createCategory", MESSAGE_INPUT_CATEGORY_NAME},
+		step{"Food", MESSAGE_SUCCESS_CREATE_CATEGORY},
+		step{"10 food", MESAGE_SUCCESS_CREATE_PAYMENT},
+		step{"/setCurrency", MESSAGE_INPUT_SHEET_CURRENCY},
+		step{"usd", MESSAGE_SUCCESS_SET_SHEET_CURRENCY},
+		step{"20 food", MESAGE_SUCCESS_CREATE_PAYMENT},
+	)
+
+	payments, err := c.storage.ListPayments(context.Background(), sheetID, time.Time{}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var currencies []string
+	for _, payment := range payments {
+		currencies = append(currencies, payment.currency)
+	}
+	if expected := []string{"EUR", "USD"}; !reflect.DeepEqual(currencies, expected) {
+		t.Errorf("got currencies %v, expected %v", currencies, expected)
+	}
+}
+
+// memberFailingStorage fails to add sheet members, in transactions as well
+type memberFailingStorage struct {
+	Storage
+}
+
+func (s *memberFailingStorage) AddSheetMember(ctx context.Context, sheetID string, chatID int64, role Role) error {
+	return errors.New("connection lost")
+}
+
+func (s *memberFailingStorage) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	return s.Storage.WithTx(ctx, func(tx Storage) error {
+		return fn(&memberFailingStorage{Storage: tx})
+	})
+}
+
+func TestRedeemInviteFailureKeepsUse(t *testing.T) {
+	c := newConversation(t)
+	alice := c.privateChat(100, "Alice")
+	bob := c.privateChat(200, "Bob")
+	sheetID := createSheet(alice, "Home", "secret")
+
+	token, err := generateInviteToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := c.storage.InsertNewInvite(ctx, sheetID, alice.chatID(), token, RoleEditor, 1, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	c.handler.storage = &memberFailingStorage{Storage: c.storage}
+	if reply := bob.send("/start " + token); !strings.HasPrefix(reply.text, MESSAGE_UNEXPECTED_SERVER_ERROR) {
+		t.Fatalf("got reply %q, expected the failure", reply.text)
+	}
+	if usesLeft := c.storage.data.invites[token].invite.usesLeft; usesLeft != 1 {
+		t.Fatalf("invite has %d uses left after the failure, expected 1", usesLeft)
+	}
+
+	c.handler.storage = c.storage
+	bob.script(step{"/start " + token, MESSAGE_SUCCESS_CONNECT_TO_SHEET})
+	if role, _ := c.storage.GetSheetMemberRole(ctx, sheetID, bob.chatID()); role != RoleEditor {
+		t.Errorf("invited chat has role %v, expected %v", role, RoleEditor)
+	}
+}
+
+func TestGroupOwnerCommandsForAdminsOnly(t *testing.T) {
+	c := newConversation(t)
+	alice := c.groupChat(-500, 100, "Alice")
+	bob := c.groupChat(-500, 200, "Bob")
+	c.bot.admins[100] = true
+	createSheet(alice, "Trip", "secret")
+
+	bob.script(
+		step{"/createCategory", MESSAGE_INPUT_CATEGORY_NAME},
+		step{"Food", MESSAGE_SUCCESS_CREATE_CATEGORY},
+		step{"/setCurrency", MESSAGE_FAILURE_GROUP_ADMINS_ONLY},
+		step{"/createInvite", MESSAGE_FAILURE_GROUP_ADMINS_ONLY},
+	)
+	alice.script(
+		step{"/setCurrency", MESSAGE_INPUT_SHEET_CURRENCY},
+		step{"USD", MESSAGE_SUCCESS_SET_SHEET_CURRENCY},
+	)
+}
+
+func TestIdleChatStatusEvicted(t *testing.T) {
+	c := newConversation(t)
+	alice := c.privateChat(100, "Alice")
+	bob := c.privateChat(200, "Bob")
+	sheetID := createSheet(alice, "Home", "secret")
+	alice.script(step{"/createCategory", MESSAGE_INPUT_CATEGORY_NAME})
+	bob.script(step{"/help", ""})
+
+	aliceKey := chatStatusKey{chatID: alice.chatID(), userID: alice.chatID()}
+	bobKey := chatStatusKey{chatID: bob.chatID(), userID: bob.chatID()}
+	chatStatuses[aliceKey].lastActive = time.Now().Add(-chatStatusIdleTimeout - time.Minute)
+	lastChatStatusSweep = time.Now().Add(-chatStatusSweepInterval)
+
+	bob.script(step{"/help", ""})
+	if _, ok := chatStatuses[aliceKey]; ok {
+		t.Error("the idle status is kept")
+	}
+	if _, ok := chatStatuses[bobKey]; !ok {
+		t.Error("the active status is evicted")
+	}
+
+	// The flow is forgotten, so the name is not taken for a category
+	alice.send("Food")
+	if categories, _ := c.storage.ListCategories(context.Background(), sheetID); len(categories) != 0 {
+		t.Errorf("got categories %v, expected none", categories)
+	}
+}
+
+func TestSharedPaymentSplitBetweenGroupUsers(t *testing.T) {
+	c := newConversation(t)
+	alice := c.groupChat(-500, 100, "Alice")
+	bob := c.groupChat(-500, 200, "Bob")
+	c.bot.admins[100] = true
+	createSheet(alice, "Flat", "secret")
+	alice.script(
+		step{"/createCategory", MESSAGE_INPUT_CATEGORY_NAME},
+		step{"Food", MESSAGE_SUCCESS_CREATE_CATEGORY},
+	)
+	bob.script(step{"/balances", MESSAGE_LIST_BALANCES_INTRO})
+
+	alice.script(
+		step{"/addShared", MESSAGE_INPUT_SHARED_PAYMENT},
+		step{"30 food", MESSAGE_INPUT_SHARED_PAYER},
+	)
+	if expected := []string{splitMe, "Bob (200)"}; !reflect.DeepEqual(alice.lastReply.options, expected) {
+		t.Errorf("got payer options %v, expected %v", alice.lastReply.options, expected)
+	}
+	alice.script(
+		step{"Bob (200)", MESSAGE_INPUT_SPLIT_MODE},
+		step{MESSAGE_OPTION_SPLIT_EQUALLY, MESSAGE_SUCCESS_ADD_SHARED_PAYMENT},
+	)
+
+	// Both users write from the same group chat, yet each has a balance of their own
+	reply := alice.send("/balances")
+	for _, line := range []string{"Alice (me): -15.00", "Bob (200): 15.00"} {
+		if !strings.Contains(reply.text, line) {
+			t.Errorf("got balances %q, expected them to contain %q", reply.text, line)
+		}
+	}
+	if reply := alice.send("/listMembers"); !strings.Contains(reply.text, "-500 Flat (owner)") {
+		t.Errorf("got members %q, expected the group to be listed by its title", reply.text)
+	}
+
+	// Users who haven't written to the sheet can't be split with
+	alice.script(
+		step{"/addShared", MESSAGE_INPUT_SHARED_PAYMENT},
+		step{"10 food", MESSAGE_INPUT_SHARED_PAYER},
+		step{"300", MESSAGE_INCORRECT_MEMBER},
+	)
+}
+
+func TestSuggestCategories(t *testing.T) {
+	c := newConversation(t)
+	alice := c.privateChat(100, "Alice")
+	sheetID := createSheet(alice, "Home", "secret")
+	alice.script(
+		step{"/createCategory", MESSAGE_INPUT_CATEGORY_NAME},
+		step{"Food", MESSAGE_SUCCESS_CREATE_CATEGORY},
+		step{"/createCategory", MESSAGE_INPUT_CATEGORY_NAME},
+		step{"Transport", MESSAGE_SUCCESS_CREATE_CATEGORY},
+		// Nothing to learn from yet
+		step{"5 bakery", MESSAGE_FAILURE_UNKNOWN_CATEGORY_NAME},
+		step{"4 food", MESAGE_SUCCESS_CREATE_PAYMENT},
+		step{"20 transport", MESAGE_SUCCESS_CREATE_PAYMENT},
+		step{"5 bakery", MESSAGE_INPUT_SUGGESTED_CATEGORY},
+	)
+	// A small amount is more like the food than the transport
+	if expected := []string{"Food", "Transport"}; !reflect.DeepEqual(alice.lastReply.options, expected) {
+		t.Fatalf("got suggestions %q, expected %q", alice.lastReply.options, expected)
+	}
+	alice.script(step{"Food", MESAGE_SUCCESS_CREATE_PAYMENT})
+	if amounts := paymentAmounts(c, sheetID); !reflect.DeepEqual(amounts, []int64{400, 2000, 500}) {
+		t.Fatalf("got payments %v, expected the suggested one to be saved", amounts)
+	}
+
+	// Not one of the suggestions, so the pending payment is dropped for the new one
+	alice.script(
+		step{"6 bakery", MESSAGE_INPUT_SUGGESTED_CATEGORY},
+		step{"7 food", MESAGE_SUCCESS_CREATE_PAYMENT},
+	)
+	if amounts := paymentAmounts(c, sheetID); !reflect.DeepEqual(amounts, []int64{400, 2000, 500, 700}) {
+		t.Errorf("got payments %v, expected only the new one to be saved", amounts)
+	}
+}
+
+func TestPaymentButtons(t *testing.T) {
+	c := newConversation(t)
+	alice := c.privateChat(100, "Alice")
+	sheetID := createSheet(alice, "Home", "secret")
+	for _, name := range []string{"Food", "Groceries", "Misc 1", "Misc 2", "Misc 3", "Misc 4", "Misc 5", "Misc 6", "Misc 7"} {
+		alice.script(
+			step{"/createCategory", MESSAGE_INPUT_CATEGORY_NAME},
+			step{name, MESSAGE_SUCCESS_CREATE_CATEGORY},
+		)
+	}
+	alice.script(step{"12.50 food", MESAGE_SUCCESS_CREATE_PAYMENT})
+
+	// Nine categories take two pages
+	if reply := alice.press(MESSAGE_BUTTON_CHANGE_CATEGORY); reply.text != fmt.Sprintf(MESSAGE_INPUT_NEW_CATEGORY, 1, 2) {
+		t.Fatalf("got reply %q after change category", reply.text)
+	}
+	if reply := alice.press(MESSAGE_BUTTON_NEXT_PAGE); reply.text != fmt.Sprintf(MESSAGE_INPUT_NEW_CATEGORY, 2, 2) {
+		t.Fatalf("got reply %q on the next page", reply.text)
+	}
+	alice.press(MESSAGE_BUTTON_PREVIOUS_PAGE)
+	categoryPage := alice.lastReply
+	if reply := alice.press("Groceries"); reply.text != fmt.Sprintf(MESSAGE_SUCCESS_CHANGE_CATEGORY, "Groceries") {
+		t.Fatalf("got reply %q after choosing the category", reply.text)
+	}
+
+	if reply := alice.press(MESSAGE_BUTTON_EDIT_AMOUNT); reply.text != fmt.Sprintf(MESSAGE_INPUT_NEW_AMOUNT, "12.50") {
+		t.Fatalf("got reply %q after edit amount", reply.text)
+	}
+	alice.script(
+		step{"twelve", MESSAGE_INCORRECT_AMOUNT},
+		step{"15", fmt.Sprintf(MESSAGE_SUCCESS_EDIT_AMOUNT, "15.00")},
+	)
+	confirmation := alice.lastReply
+	payments, err := c.storage.ListPayments(context.Background(), sheetID, time.Time{}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payments) != 1 || payments[0].amount != 1500 || payments[0].categoryName != "Groceries" {
+		t.Fatalf("got payments %+v, expected the edited one", payments)
+	}
+
+	// The category has already been changed with this list
+	alice.lastReply = categoryPage
+	if reply := alice.press("Food"); reply.text != MESSAGE_FAILURE_EXPIRED_BUTTON {
+		t.Errorf("got reply %q for a used category button", reply.text)
+	}
+
+	alice.lastReply = confirmation
+	if reply := alice.press(MESSAGE_BUTTON_UNDO); reply.text != fmt.Sprintf(MESSAGE_SUCCESS_UNDO_PAYMENT, "15.00", "Groceries") {
+		t.Fatalf("got reply %q after undo", reply.text)
+	}
+	// The buttons of the confirmation outlive the payment
+	alice.lastReply = confirmation
+	if reply := alice.press(MESSAGE_BUTTON_UNDO); reply.text != MESSAGE_FAILURE_PAYMENT_NOT_FOUND {
+		t.Errorf("got reply %q after the second undo", reply.text)
+	}
+}
+
+func TestCommandArguments(t *testing.T) {
+	c := newConversation(t)
+	alice := c.groupChat(-500, 100, "Alice")
+	c.bot.admins[100] = true
+	sheetID := createSheet(alice, "Flat", "secret")
+	alice.script(
+		step{"/createCategory Food", MESSAGE_SUCCESS_CREATE_CATEGORY},
+		step{"/createCategory@budgli_bot Transport", MESSAGE_SUCCESS_CREATE_CATEGORY},
+		// Without the arguments the command still asks for them
+		step{"/createCategory@Budgli_Bot", MESSAGE_INPUT_CATEGORY_NAME},
+		step{"Rent", MESSAGE_SUCCESS_CREATE_CATEGORY},
+	)
+
+	// Another bot in the group is asked, this one keeps quiet
+	chat := alice.chat
+	command := tgbotapi.Update{Message: &tgbotapi.Message{MessageID: 1000, From: &alice.user, Chat: &chat, Text: "/createCategory@other_bot Games"}}
+	if sent := alice.process(command); len(sent) != 0 {
+		t.Errorf("got replies %+v to a command for another bot", sent)
+	}
+
+	categoryIDs, err := c.storage.ListCategoryIDs(context.Background(), sheetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(categoryIDs) != 3 || categoryIDs["Games"] != "" {
+		t.Errorf("got categories %v, expected Food, Transport and Rent", categoryIDs)
+	}
+}
+
+func TestCreatePaymentsBatch(t *testing.T) {
+	c := newConversation(t)
+	alice := c.privateChat(100, "Alice")
+	sheetID := createSheet(alice, "Home", "secret")
+	alice.script(
+		step{"/createCategory", MESSAGE_INPUT_CATEGORY_NAME},
+		step{"Food", MESSAGE_SUCCESS_CREATE_CATEGORY},
+		step{"/createCategory", MESSAGE_INPUT_CATEGORY_NAME},
+		step{"Transport", MESSAGE_SUCCESS_CREATE_CATEGORY},
+	)
+	plurals := englishLanguage.plurals
+
+	// The good line isn't saved either while the others are wrong
+	failure := fmt.Sprintf(plurals[MESSAGE_FAILURE_CREATE_PAYMENTS][1], 3) + "\n\n" +
+		"1. 10.00 food\n" +
+		"2. 5 games: " + MESSAGE_FAILURE_UNKNOWN_CATEGORY_NAME + "\n" +
+		"3. taxi: " + MESSAGE_BATCH_INCORRECT_LINE + "\n" +
+		"4. 1.500 transport: " + MESSAGE_BATCH_AMBIGUOUS_AMOUNT + "\n"
+	alice.script(step{"10 food\n5 games\ntaxi\n1.500 transport", fmt.Sprintf(plurals[MESSAGE_FAILURE_CREATE_PAYMENTS][1], 3)})
+	if alice.lastReply.text != failure {
+		t.Errorf("got reply %q, expected %q", alice.lastReply.text, failure)
+	}
+	if amounts := paymentAmounts(c, sheetID); len(amounts) != 0 {
+		t.Fatalf("got payments %v, expected none", amounts)
+	}
+
+	// Blank lines and spaces around the lines are skipped
+	success := fmt.Sprintf(plurals[MESSAGE_SUCCESS_CREATE_PAYMENTS][1], 2) + "\n\n" +
+		"1. 10.00 food\n" +
+		"2. 5.50 transport\n"
+	alice.script(step{"10 food\n\n  5.5 transport  ", fmt.Sprintf(plurals[MESSAGE_SUCCESS_CREATE_PAYMENTS][1], 2)})
+	if alice.lastReply.text != success {
+		t.Errorf("got reply %q, expected %q", alice.lastReply.text, success)
+	}
+	if amounts := paymentAmounts(c, sheetID); !reflect.DeepEqual(amounts, []int64{1000, 550}) {
+		t.Errorf("got payments %v, expected both lines", amounts)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// fakeBot stands in for the Telegram bot API and keeps everything the handler sends
+type fakeBot struct {
+	sent          []sentMessage
+	nextMessageID int
+	// Users who are admins of all the groups
+	admins map[int]bool
+}
+
+// sentMessage is a message the bot sent or the new text of a message it edited
+type sentMessage struct {
+	chatID    int64
+	messageID int
+	text      string
+	edited    bool
+	// Texts of the reply keyboard buttons
+	options []string
+	buttons []tgbotapi.InlineKeyboardButton
+}
+
+func (b *fakeBot) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	var sent sentMessage
+	switch c := c.(type) {
+	case tgbotapi.MessageConfig:
+		b.nextMessageID++
+		sent = sentMessage{chatID: c.ChatID, messageID: b.nextMessageID, text: c.Text}
+		switch markup := c.ReplyMarkup.(type) {
+		case tgbotapi.ReplyKeyboardMarkup:
+			for _, row := range markup.Keyboard {
+				for _, button := range row {
+					sent.options = append(sent.options, button.Text)
+				}
+			}
+		case tgbotapi.InlineKeyboardMarkup:
+			sent.buttons = flattenButtons(markup)
+		}
+	case tgbotapi.EditMessageTextConfig:
+		sent = sentMessage{chatID: c.ChatID, messageID: c.MessageID, text: c.Text, edited: true}
+		if c.ReplyMarkup != nil {
+			sent.buttons = flattenButtons(*c.ReplyMarkup)
+		}
+	case tgbotapi.DocumentConfig:
+		b.nextMessageID++
+		sent = sentMessage{chatID: c.ChatID, messageID: b.nextMessageID}
+	default:
+		return tgbotapi.Message{}, errors.New("unexpected message type")
+	}
+	b.sent = append(b.sent, sent)
+	return tgbotapi.Message{MessageID: sent.messageID, Chat: &tgbotapi.Chat{ID: sent.chatID}}, nil
+}
+
+func (b *fakeBot) AnswerCallbackQuery(config tgbotapi.CallbackConfig) (tgbotapi.APIResponse, error) {
+	return tgbotapi.APIResponse{Ok: true}, nil
+}
+
+func (b *fakeBot) GetFileDirectURL(fileID string) (string, error) {
+	return "", errors.New("files can't be downloaded in tests")
+}
+
+func (b *fakeBot) GetChatMember(config tgbotapi.ChatConfigWithUser) (tgbotapi.ChatMember, error) {
+	status := "member"
+	if b.admins[config.UserID] {
+		status = "administrator"
+	}
+	return tgbotapi.ChatMember{User: &tgbotapi.User{ID: config.UserID}, Status: status}, nil
+}
+
+func flattenButtons(markup tgbotapi.InlineKeyboardMarkup) []tgbotapi.InlineKeyboardButton {
+	var buttons []tgbotapi.InlineKeyboardButton
+	for _, row := range markup.InlineKeyboard {
+		buttons = append(buttons, row...)
+	}
+	return buttons
+}
+
+// conversation runs the handler against the fake bot and an in-memory storage
+type conversation struct {
+	t       *testing.T
+	handler *Handler
+	bot     *fakeBot
+	storage *memoryStorage
+}
+
+func newConversation(t *testing.T) *conversation {
+	// The multi-step state of the chats is global, every conversation starts without any
+	chatStatuses = make(map[chatStatusKey]*ChatStatus)
+	lastChatStatusSweep = time.Time{}
+
+	bot := &fakeBot{admins: make(map[int]bool)}
+	storage := newMemoryStorage()
+	handler := CreateHandler(storage, bot, tgbotapi.User{ID: 1, UserName: "budgli_bot", IsBot: true})
+	return &conversation{t: t, handler: handler, bot: bot, storage: storage}
+}
+
+// chatUser is a user talking to the bot in a private chat, whose ID is the user ID, or in a group
+type chatUser struct {
+	c    *conversation
+	user tgbotapi.User
+	chat tgbotapi.Chat
+	// The last reply the user got, its buttons may be pressed
+	lastReply sentMessage
+}
+
+func (c *conversation) privateChat(userID int, firstName string) *chatUser {
+	return &chatUser{c: c, user: tgbotapi.User{ID: userID, FirstName: firstName, LanguageCode: "en"}, chat: tgbotapi.Chat{ID: int64(userID), Type: "private"}}
+}
+
+// groupChat is the user writing in the group, the users of the same group share its chat ID
+func (c *conversation) groupChat(groupID int64, userID int, firstName string) *chatUser {
+	return &chatUser{c: c, user: tgbotapi.User{ID: userID, FirstName: firstName, LanguageCode: "en"}, chat: tgbotapi.Chat{ID: groupID, Type: "group", Title: "Flat"}}
+}
+
+func (u *chatUser) chatID() int64 {
+	return u.chat.ID
+}
+
+// process passes the update to the handler and returns what the bot sent because of it
+func (u *chatUser) process(update tgbotapi.Update) []sentMessage {
+	sentBefore := len(u.c.bot.sent)
+	u.c.handler.ProcessUpdate(&update)
+	sent := u.c.bot.sent[sentBefore:]
+	for _, message := range sent {
+		if message.chatID == u.chatID() {
+			u.lastReply = message
+		}
+	}
+	return sent
+}
+
+// send sends the text to the bot and returns the reply to it
+func (u *chatUser) send(text string) sentMessage {
+	u.c.t.Helper()
+
+	chat := u.chat
+	sent := u.process(tgbotapi.Update{Message: &tgbotapi.Message{MessageID: len(u.c.bot.sent) + 1, From: &u.user, Chat: &chat, Text: text}})
+	if len(sent) != 1 || sent[0].chatID != u.chatID() {
+		u.c.t.Fatalf("%q: expected a single reply, the bot sent %+v", text, sent)
+	}
+	return sent[0]
+}
+
+// press presses the inline button of the last reply and returns the edited message
+func (u *chatUser) press(buttonText string) sentMessage {
+	u.c.t.Helper()
+
+	var data string
+	for _, button := range u.lastReply.buttons {
+		if button.Text == buttonText && button.CallbackData != nil {
+			data = *button.CallbackData
+		}
+	}
+	if data == "" {
+		u.c.t.Fatalf("no %q button in %+v", buttonText, u.lastReply)
+	}
+
+	chat := u.chat
+	message := &tgbotapi.Message{MessageID: u.lastReply.messageID, Chat: &chat}
+	sent := u.process(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{ID: "query", From: &u.user, Message: message, Data: data}})
+	if len(sent) != 1 || !sent[0].edited {
+		u.c.t.Fatalf("%q: expected the message to be edited, the bot sent %+v", buttonText, sent)
+	}
+	return sent[0]
+}
+
+// step is a message of a scripted conversation and the beginning of the reply the bot is expected to give
+type step struct {
+	send   string
+	expect string
+}
+
+// script sends the messages one after another and stops at the first unexpected reply
+func (u *chatUser) script(steps ...step) {
+	u.c.t.Helper()
+
+	for _, s := range steps {
+		if reply := u.send(s.send); !strings.HasPrefix(reply.text, s.expect) {
+			u.c.t.Fatalf("%q: got reply %q, expected it to start with %q", s.send, reply.text, s.expect)
+		}
+	}
+}
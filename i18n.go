@@ -0,0 +1,192 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Messages are written in English in string_constants.go, and the English text is the key of the translations.
+// This keeps the subhandlers returning the constants as they are, while the reply is translated before it is sent.
+// Replies composed of several messages translate every part with ChatStatus.tr instead
+
+const defaultLanguage = "en"
+
+type language struct {
+	code string
+	// How the language calls itself, offered by /language
+	name string
+
+	// Returns the index of the plural form to use for the count, the forms in plurals are listed in this order
+	pluralForm func(n int) int
+	// Translations of the messages, by the English message
+	messages map[string]string
+	// Forms of the messages that contain a count, by the English message. English has them too
+	plurals map[string][]string
+
+	decimalSeparator   string
+	thousandsSeparator string
+	dateLayout         string
+	dateTimeLayout     string
+}
+
+var languages = []*language{englishLanguage, germanLanguage, russianLanguage}
+
+var englishLanguage = &language{
+	code:       "en",
+	name:       "English",
+	pluralForm: oneOtherPluralForm,
+	plurals: map[string][]string{
+		MESSAGE_LIST_SHEETS_INTRO:       {"Your user is a member of the following %d sheet:", MESSAGE_LIST_SHEETS_INTRO},
+		MESSAGE_SUCCESS_EXPORT:          {"Exported %d payment", MESSAGE_SUCCESS_EXPORT},
+		MESSAGE_IMPORT_PREVIEW_INTRO:    {"%d payment will be imported, %d already existing ones will be skipped, %d rows could not be read:", MESSAGE_IMPORT_PREVIEW_INTRO},
+		MESSAGE_SUCCESS_IMPORT:          {"Imported %d payment", MESSAGE_SUCCESS_IMPORT},
+		MESSAGE_LIST_CATEGORIES_INTRO:   {"This sheet has the following %d category:", MESSAGE_LIST_CATEGORIES_INTRO},
+		MESSAGE_LIST_RULES_INTRO:        {"This sheet has the following %d categorization rule:", MESSAGE_LIST_RULES_INTRO},
+		MESSAGE_LIST_MEMBERS_INTRO:      {"This sheet has the following %d member:", MESSAGE_LIST_MEMBERS_INTRO},
+		MESSAGE_LIST_INVITES_INTRO:      {"This sheet has the following %d active invite:", MESSAGE_LIST_INVITES_INTRO},
+		MESSAGE_SUCCESS_CREATE_PAYMENTS: {"Successfully created %d payment record:", MESSAGE_SUCCESS_CREATE_PAYMENTS},
+		MESSAGE_FAILURE_CREATE_PAYMENTS: {"%d line could not be read, so none of the payments are saved. Please fix it and send the message again:", MESSAGE_FAILURE_CREATE_PAYMENTS},
+	},
+	decimalSeparator:   ".",
+	thousandsSeparator: ",",
+	dateLayout:         "2006-01-02",
+	dateTimeLayout:     "2006-01-02 15:04",
+}
+
+func oneOtherPluralForm(n int) int {
+	if n == 1 {
+		return 0
+	}
+	return 1
+}
+
+// findLanguage accepts a language code, also with a region as Telegram sends it, e.g. "de-AT", or the name of a language.
+// Returns nil for unsupported languages
+func findLanguage(text string) *language {
+	text = normalizeText(text)
+	for _, l := range languages {
+		if text == l.code || strings.HasPrefix(text, l.code+"-") || text == normalizeText(l.name) {
+			return l
+		}
+	}
+	return nil
+}
+
+func getLanguage(code string) *language {
+	if l := findLanguage(code); l != nil {
+		return l
+	}
+	return englishLanguage
+}
+
+func languageNames() []string {
+	names := make([]string, len(languages))
+	for i, l := range languages {
+		names[i] = l.name
+	}
+	return names
+}
+
+// translate returns the message itself if it has no translation
+func translate(code string, message string) string {
+	if translation, ok := getLanguage(code).messages[message]; ok {
+		return translation
+	}
+	return message
+}
+
+// translatePlural returns the form of the message for the count, which is still to be formatted in
+func translatePlural(code string, message string, n int) string {
+	for _, l := range []*language{getLanguage(code), englishLanguage} {
+		if forms, ok := l.plurals[message]; ok {
+			return forms[l.pluralForm(n)]
+		}
+	}
+	return message
+}
+
+// formatAmount formats an amount stored in hundredths with the separators of the language, e.g. "1.234,56" in German
+func (l *language) formatAmount(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	whole := strconv.FormatInt(amount/100, 10)
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(l.thousandsSeparator)
+		}
+		grouped.WriteRune(digit)
+	}
+
+	return sign + grouped.String() + l.decimalSeparator + strconv.FormatInt(100+amount%100, 10)[1:]
+}
+
+func (c *ChatStatus) tr(message string) string {
+	return translate(c.language, message)
+}
+
+func (c *ChatStatus) trn(message string, n int) string {
+	return translatePlural(c.language, message, n)
+}
+
+func (c *ChatStatus) localAmount(amount int64) string {
+	return getLanguage(c.language).formatAmount(amount)
+}
+
+func (c *ChatStatus) localDate(t time.Time) string {
+	return t.Format(getLanguage(c.language).dateLayout)
+}
+
+func (c *ChatStatus) localDateTime(t time.Time) string {
+	return t.Format(getLanguage(c.language).dateTimeLayout)
+}
+
+func (c *ChatStatus) roleName(role Role) string {
+	switch role {
+	case RoleViewer:
+		return c.tr(MESSAGE_ROLE_VIEWER)
+	case RoleEditor:
+		return c.tr(MESSAGE_ROLE_EDITOR)
+	case RoleOwner:
+		return c.tr(MESSAGE_ROLE_OWNER)
+	}
+	return role.String()
+}
+
+// localizeReplyExtras translates the buttons and the reply options. The options are remembered,
+// so that choosing a translated one is understood as the English option the subhandlers expect
+func (c *ChatStatus) localizeReplyExtras(replyExtras *ReplyExtras) {
+	c.offeredOptions = nil
+	// The options may be shared by all the chats, e.g. dateRangeOptions, so they are not translated in place
+	options := make([]string, len(replyExtras.ReplyOptions))
+	for i, option := range replyExtras.ReplyOptions {
+		options[i] = c.tr(option)
+		if options[i] != option {
+			if c.offeredOptions == nil {
+				c.offeredOptions = make(map[string]string)
+			}
+			c.offeredOptions[normalizeText(options[i])] = option
+		}
+	}
+	if len(options) > 0 {
+		replyExtras.ReplyOptions = options
+	}
+	for _, row := range replyExtras.InlineButtons {
+		for i := range row {
+			row[i].Text = c.tr(row[i].Text)
+		}
+	}
+}
+
+// delocalizeOption turns a translated reply option back into the English one
+func (c *ChatStatus) delocalizeOption(text string) string {
+	if option, ok := c.offeredOptions[normalizeText(text)]; ok {
+		return option
+	}
+	return text
+}
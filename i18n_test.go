@@ -0,0 +1,170 @@
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var formatVerbRegexp = regexp.MustCompile(`%[-+# 0]*[0-9]*(\.[0-9]+)?[a-zA-Z%]`)
+
+// botMessages type-checks the package to get the values of all the message constants, including the concatenated ones
+func botMessages(t *testing.T) map[string]string {
+	t.Helper()
+
+	entries, err := os.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	// The dependencies may be missing from the importer, that doesn't keep the constants from being evaluated
+	config := types.Config{Importer: importer.Default(), Error: func(error) {}}
+	pkg, _ := config.Check("main", fset, files, nil)
+
+	messages := make(map[string]string)
+	for _, name := range pkg.Scope().Names() {
+		if !strings.HasPrefix(name, "MESSAGE_") && !strings.HasPrefix(name, "MESAGE_") {
+			continue
+		}
+		if c, ok := pkg.Scope().Lookup(name).(*types.Const); ok && c.Val().Kind() == constant.String {
+			messages[constant.StringVal(c.Val())] = name
+		}
+	}
+	if len(messages) == 0 {
+		t.Fatal("no messages found")
+	}
+	return messages
+}
+
+func pluralFormCount(l *language) int {
+	count := 0
+	for n := 0; n < 1000; n++ {
+		if form := l.pluralForm(n) + 1; form > count {
+			count = form
+		}
+	}
+	return count
+}
+
+func checkFormatVerbs(t *testing.T, l *language, name string, message string, translation string) {
+	t.Helper()
+	if expected, actual := formatVerbRegexp.FindAllString(message, -1), formatVerbRegexp.FindAllString(translation, -1); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("%s: %s has format verbs %v, expected %v", l.code, name, actual, expected)
+	}
+}
+
+func TestTranslationsComplete(t *testing.T) {
+	messages := botMessages(t)
+
+	for _, l := range languages {
+		formCount := pluralFormCount(l)
+		for message, name := range messages {
+			if _, isPlural := englishLanguage.plurals[message]; isPlural {
+				forms, ok := l.plurals[message]
+				if !ok {
+					t.Errorf("%s: missing plural forms of %s", l.code, name)
+					continue
+				}
+				if len(forms) != formCount {
+					t.Errorf("%s: %s has %d plural forms, expected %d", l.code, name, len(forms), formCount)
+				}
+				for _, form := range forms {
+					checkFormatVerbs(t, l, name, message, form)
+				}
+				continue
+			}
+
+			if l == englishLanguage {
+				continue
+			}
+			translation, ok := l.messages[message]
+			if !ok {
+				t.Errorf("%s: missing translation of %s", l.code, name)
+				continue
+			}
+			checkFormatVerbs(t, l, name, message, translation)
+		}
+
+		for message := range l.messages {
+			if _, ok := messages[message]; !ok {
+				t.Errorf("%s: translation of an unknown message %q", l.code, message)
+			}
+		}
+		for message := range l.plurals {
+			if _, ok := messages[message]; !ok {
+				t.Errorf("%s: plural forms of an unknown message %q", l.code, message)
+			}
+		}
+	}
+}
+
+func TestRussianPluralForm(t *testing.T) {
+	tests := map[int]int{0: 2, 1: 0, 2: 1, 4: 1, 5: 2, 11: 2, 12: 2, 14: 2, 21: 0, 22: 1, 25: 2, 101: 0, 111: 2, 112: 2, 122: 1}
+	for n, expected := range tests {
+		if actual := russianPluralForm(n); actual != expected {
+			t.Errorf("russianPluralForm(%d) = %d, expected %d", n, actual, expected)
+		}
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		language *language
+		amount   int64
+		expected string
+	}{
+		{englishLanguage, 123456789, "1,234,567.89"},
+		{englishLanguage, -5, "-0.05"},
+		{germanLanguage, 123456, "1.234,56"},
+		{russianLanguage, 100000000, "1\u00a0000\u00a0000,00"},
+	}
+	for _, test := range tests {
+		if actual := test.language.formatAmount(test.amount); actual != test.expected {
+			t.Errorf("%s: formatAmount(%d) = %q, expected %q", test.language.code, test.amount, actual, test.expected)
+		}
+	}
+}
+
+func TestRoleNameAndDateTime(t *testing.T) {
+	madeTime := time.Date(2024, 3, 9, 14, 5, 0, 0, time.UTC)
+	tests := []struct {
+		language string
+		role     string
+		dateTime string
+	}{
+		{"en", "editor", "2024-03-09 14:05"},
+		{"de", "Bearbeiter", "09.03.2024 14:05"},
+		{"ru", "редактор", "09.03.2024 14:05"},
+	}
+	for _, test := range tests {
+		chatStatus := &ChatStatus{language: test.language}
+		if actual := chatStatus.roleName(RoleEditor); actual != test.role {
+			t.Errorf("%s: roleName(RoleEditor) = %q, expected %q", test.language, actual, test.role)
+		}
+		if actual := chatStatus.localDateTime(madeTime); actual != test.dateTime {
+			t.Errorf("%s: localDateTime = %q, expected %q", test.language, actual, test.dateTime)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Payments which don't match any category are imported into this one, it is created when needed
+const uncategorizedCategoryName = "uncategorized"
+
+// statementLocation is the time zone of the statement dates, which have no zone of their own.
+// Stored payments come back from the database in UTC, so they are converted to it to compare the days
+var statementLocation = time.Local
+
+// ImportProfile describes how to read the bank statements of a sheet. It is saved after the first import,
+// so that statements of the same bank are imported without asking again
+type ImportProfile struct {
+	dateColumn        string
+	amountColumn      string
+	descriptionColumn string
+	dateLayout        string
+	// Banks usually show spending as negative amounts, while in a sheet it is positive
+	expensesNegative bool
+}
+
+// matches reports whether the statement has all the columns the profile needs
+func (p *ImportProfile) matches(statement *csvStatement) bool {
+	return statement.column(p.dateColumn) >= 0 && statement.column(p.amountColumn) >= 0 && statement.column(p.descriptionColumn) >= 0
+}
+
+type csvStatement struct {
+	header []string
+	rows   [][]string
+}
+
+type importedPayment struct {
+	madeTime     time.Time
+	amount       int64
+	description  string
+	categoryName string
+	duplicate    bool
+}
+
+var csvDelimiters = []rune{',', ';', '\t'}
+
+// parseCSVStatement reads a CSV file with a header row. The delimiter is guessed from the header
+func parseCSVStatement(content []byte) (*csvStatement, error) {
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+
+	firstLine := string(content)
+	if i := strings.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+	delimiter := csvDelimiters[0]
+	for _, d := range csvDelimiters {
+		if strings.Count(firstLine, string(d)) > strings.Count(firstLine, string(delimiter)) {
+			delimiter = d
+		}
+	}
+
+	r := csv.NewReader(bytes.NewReader(content))
+	r.Comma = delimiter
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 {
+		return nil, errors.New("statement has no rows")
+	}
+
+	header := make([]string, len(records[0]))
+	for i, name := range records[0] {
+		header[i] = strings.TrimSpace(name)
+	}
+	return &csvStatement{header: header, rows: records[1:]}, nil
+}
+
+// column returns the index of the column with the given name or -1
+func (s *csvStatement) column(name string) int {
+	for i, h := range s.header {
+		if name != "" && normalizeText(h) == normalizeText(name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *csvStatement) values(column int) []string {
+	var values []string
+	for _, row := range s.rows {
+		if column < len(row) {
+			values = append(values, row[column])
+		}
+	}
+	return values
+}
+
+// Tried in this order, so day-first layouts win over month-first ones when both fit all the dates
+var statementDateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"02.01.2006",
+	"02.01.2006 15:04",
+	"02.01.06",
+	"02/01/2006",
+	"01/02/2006",
+	"2006/01/02",
+	"02-01-2006",
+	"2 Jan 2006",
+	"Jan 2, 2006",
+}
+
+// detectDateLayout returns the first of statementDateLayouts which all the dates can be parsed with, or an empty string
+func detectDateLayout(dates []string) string {
+	for _, layout := range statementDateLayouts {
+		fits := true
+		for _, date := range dates {
+			if date = strings.TrimSpace(date); date == "" {
+				continue
+			}
+			if _, err := time.ParseInLocation(layout, date, statementLocation); err != nil {
+				fits = false
+				break
+			}
+		}
+		if fits {
+			return layout
+		}
+	}
+	return ""
+}
+
+// parseStatementAmount parses amounts the way banks write them, e.g. "-1,234.56", "1.234,56 EUR" or "(12.50)",
+// into hundredths. A separator followed by exactly three digits is treated as a thousands separator
+func parseStatementAmount(s string) (int64, error) {
+	negative := false
+	var digits strings.Builder
+	for _, r := range strings.TrimSpace(s) {
+		switch {
+		case unicode.IsDigit(r), r == '.', r == ',':
+			digits.WriteRune(r)
+		case r == '-', r == '(', r == '−':
+			negative = true
+		}
+	}
+	number := digits.String()
+	if number == "" {
+		return 0, fmt.Errorf("no amount in %q", s)
+	}
+
+	integer, fraction := number, ""
+	if i := strings.LastIndexAny(number, ".,"); i >= 0 && len(number)-i-1 != 3 {
+		integer, fraction = number[:i], number[i+1:]
+	}
+	integer = strings.NewReplacer(".", "", ",", "").Replace(integer)
+	if integer == "" {
+		integer = "0"
+	}
+	if len(fraction) > 2 || strings.ContainsAny(fraction, ".,") {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	fraction += strings.Repeat("0", 2-len(fraction))
+
+	amount, err := strconv.ParseInt(integer+fraction, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// readPayments converts the statement rows into payments. Rows which can't be parsed are skipped and counted
+func (p *ImportProfile) readPayments(statement *csvStatement) ([]importedPayment, int) {
+	dateColumn := statement.column(p.dateColumn)
+	amountColumn := statement.column(p.amountColumn)
+	descriptionColumn := statement.column(p.descriptionColumn)
+
+	var payments []importedPayment
+	skipped := 0
+	for _, row := range statement.rows {
+		if dateColumn >= len(row) || amountColumn >= len(row) || descriptionColumn >= len(row) {
+			skipped++
+			continue
+		}
+		madeTime, err := time.ParseInLocation(p.dateLayout, strings.TrimSpace(row[dateColumn]), statementLocation)
+		if err != nil {
+			skipped++
+			continue
+		}
+		amount, err := parseStatementAmount(row[amountColumn])
+		if err != nil {
+			skipped++
+			continue
+		}
+		if p.expensesNegative {
+			amount = -amount
+		}
+		payments = append(payments, importedPayment{
+			madeTime:    madeTime,
+			amount:      amount,
+			description: truncateComment(strings.TrimSpace(row[descriptionColumn])),
+		})
+	}
+	return payments, skipped
+}
+
+// Payment comments are stored in a varchar(100) column
+const maxCommentLength = 100
+
+func truncateComment(comment string) string {
+	if runes := []rune(comment); len(runes) > maxCommentLength {
+		return string(runes[:maxCommentLength])
+	}
+	return comment
+}
+
+// categorizeByName picks the longest category name found in the description, or an empty string
+func categorizeByName(categories []string, description string) string {
+	description = normalizeText(description)
+	best := ""
+	for _, category := range categories {
+		if len(category) > len(best) && strings.Contains(description, normalizeText(category)) {
+			best = category
+		}
+	}
+	return best
+}
+
+// categorizeImportedPayments tries the sheet rules first and then the category names
+func categorizeImportedPayments(payments []importedPayment, rules []*CategorizationRule, categories []string) {
+	for i := range payments {
+		if payments[i].categoryName == "" {
+			payments[i].categoryName = categorizeByRules(rules, payments[i].description, payments[i].amount)
+		}
+		if payments[i].categoryName == "" {
+			payments[i].categoryName = categorizeByName(categories, payments[i].description)
+		}
+		if payments[i].categoryName == "" {
+			payments[i].categoryName = uncategorizedCategoryName
+		}
+	}
+}
+
+// markDuplicates marks the payments already stored with the same day, amount and comment.
+// Each stored payment only cancels out one imported payment, so repeated identical purchases are kept
+func markDuplicates(payments []importedPayment, existing []Payment) {
+	key := func(madeTime time.Time, amount int64, comment string) string {
+		return fmt.Sprintf("%s|%d|%s", madeTime.In(statementLocation).Format("2006-01-02"), amount, normalizeText(comment))
+	}
+
+	counts := make(map[string]int)
+	for _, payment := range existing {
+		counts[key(payment.madeTime, payment.amount, payment.comment)]++
+	}
+	for i := range payments {
+		k := key(payments[i].madeTime, payments[i].amount, payments[i].description)
+		if counts[k] > 0 {
+			counts[k]--
+			payments[i].duplicate = true
+		}
+	}
+}
+
+// importedPaymentsRange returns the [from, to) range of days covering all the payments
+func importedPaymentsRange(payments []importedPayment) (time.Time, time.Time) {
+	from, to := payments[0].madeTime, payments[0].madeTime
+	for _, payment := range payments[1:] {
+		if payment.madeTime.Before(from) {
+			from = payment.madeTime
+		}
+		if payment.madeTime.After(to) {
+			to = payment.madeTime
+		}
+	}
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, to.Location()).AddDate(0, 0, 1)
+	return from, to
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMarkDuplicatesInOtherLocation(t *testing.T) {
+	location := statementLocation
+	defer func() { statementLocation = location }()
+	// Midnight there is the evening before in UTC
+	statementLocation = time.FixedZone("UTC+3", 3*60*60)
+
+	profile := &ImportProfile{dateColumn: "Date", amountColumn: "Amount", descriptionColumn: "Description", dateLayout: "2006-01-02"}
+	statement := &csvStatement{
+		header: []string{"Date", "Amount", "Description"},
+		rows: [][]string{
+			{"2024-01-05", "12.50", "Bakery"},
+			{"2024-01-05", "12.50", "Bakery"},
+			{"2024-01-06", "3.00", "Coffee"},
+		},
+	}
+	payments, skipped := profile.readPayments(statement)
+	if skipped != 0 || len(payments) != 3 {
+		t.Fatalf("read %d payments and skipped %d", len(payments), skipped)
+	}
+
+	// What the database returns for the payments imported from the same statement before
+	existing := []Payment{
+		{amount: 1250, comment: "Bakery", madeTime: payments[0].madeTime.UTC()},
+		{amount: 300, comment: "Coffee", madeTime: payments[2].madeTime.UTC()},
+	}
+	markDuplicates(payments, existing)
+	for i, expected := range []bool{true, false, true} {
+		if payments[i].duplicate != expected {
+			t.Errorf("payment %d duplicate = %v, expected %v", i, payments[i].duplicate, expected)
+		}
+	}
+}
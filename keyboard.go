@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// InlineButton is a button under a message, its callback data is "<action>:<argument>".
+// Telegram limits the data to 64 bytes, so the argument should be an ID or a page number, not a whole text
+type InlineButton struct {
+	Text string
+	Data string
+}
+
+const callbackDataSeparator = ":"
+
+// How many items a page of a paginated list shows
+const pageSize = 8
+
+func callbackData(action string, argument string) string {
+	return action + callbackDataSeparator + argument
+}
+
+func parseCallbackData(data string) (action string, argument string) {
+	parts := strings.SplitN(data, callbackDataSeparator, 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+func inlineKeyboardMarkup(buttons [][]InlineButton) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, len(buttons))
+	for i, row := range buttons {
+		for _, button := range row {
+			rows[i] = append(rows[i], tgbotapi.NewInlineKeyboardButtonData(button.Text, button.Data))
+		}
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// paginate returns the bounds of the page items, the page is clamped to the existing ones
+func paginate(total int, page int) (from int, to int, clampedPage int) {
+	pages := pageCount(total)
+	if page >= pages {
+		page = pages - 1
+	}
+	if page < 0 {
+		page = 0
+	}
+	from = page * pageSize
+	to = from + pageSize
+	if to > total {
+		to = total
+	}
+	return from, to, page
+}
+
+func pageCount(total int) int {
+	if total == 0 {
+		return 1
+	}
+	return (total + pageSize - 1) / pageSize
+}
+
+// pageNavigation returns the row with the previous and the next page buttons, or nil if everything fits one page
+func pageNavigation(total int, page int, action string) []InlineButton {
+	var row []InlineButton
+	if page > 0 {
+		row = append(row, InlineButton{Text: MESSAGE_BUTTON_PREVIOUS_PAGE, Data: callbackData(action, strconv.Itoa(page-1))})
+	}
+	if page < pageCount(total)-1 {
+		row = append(row, InlineButton{Text: MESSAGE_BUTTON_NEXT_PAGE, Data: callbackData(action, strconv.Itoa(page+1))})
+	}
+	return row
+}
+
+func parsePage(argument string) int {
+	page, err := strconv.Atoi(argument)
+	if err != nil {
+		return 0
+	}
+	return page
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Payments are spending, so categories map to accounts under this root
+const expensesAccountRoot = "Expenses"
+
+var (
+	// Both ledger and beancount accept accounts like this, beancount requires the root to be one of its five types
+	fundingAccountRe   = regexp.MustCompile(`^(Assets|Liabilities|Equity|Income):[\p{Lu}\d][\p{L}\d-]*(:[\p{Lu}\d][\p{L}\d-]*)*$`)
+	currencyRe         = regexp.MustCompile(`^[A-Z][A-Z0-9]{1,9}$`)
+	accountComponentRe = regexp.MustCompile(`[^\p{L}\d]+`)
+)
+
+func validateFundingAccount(account string) bool {
+	return fundingAccountRe.MatchString(account)
+}
+
+func validateCurrency(currency string) bool {
+	return currencyRe.MatchString(currency)
+}
+
+// categoryAccount maps a category to an expenses account, e.g. "eating out" to Expenses:Eating-Out.
+// Only letters and digits are kept, so the account needs no escaping, unlike the payee, see ledgerValue
+func categoryAccount(categoryName string) string {
+	words := accountComponentRe.Split(categoryName, -1)
+	var component []string
+	for _, word := range words {
+		if word == "" {
+			continue
+		}
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		component = append(component, string(runes))
+	}
+	if len(component) == 0 {
+		component = []string{"Uncategorized"}
+	}
+	return expensesAccountRoot + ":" + strings.Join(component, "-")
+}
+
+// writeLedger writes every payment as a ledger transaction, the funding account posting balances it implicitly
+func writeLedger(w io.Writer, data *exportData) error {
+	bw := bufio.NewWriter(w)
+	for _, payment := range data.payments {
+		payee := payment.comment
+		if payee == "" {
+			payee = payment.categoryName
+		}
+		fmt.Fprintf(bw, "%s * %s\n", payment.madeTime.Format("2006/01/02"), ledgerValue(payee))
+		fmt.Fprintf(bw, "    ; payment_id: %s\n", payment.id)
+		if payment.author.name != "" {
+			fmt.Fprintf(bw, "    ; author: %s\n", ledgerValue(payment.author.name))
+		}
+		fmt.Fprintf(bw, "    %-40s  %s %s\n", categoryAccount(payment.categoryName), formatAmount(payment.amount), payment.currency)
+		fmt.Fprintf(bw, "    %s\n\n", data.settings.fundingAccount)
+	}
+	return bw.Flush()
+}
+
+// ledgerValue keeps a payee or a note from being read as more than text: ";" starts a note, ":" makes a tag of it,
+// two spaces end the payee, and a leading "(" or "[" is a transaction code or a virtual account
+func ledgerValue(value string) string {
+	value = strings.NewReplacer(";", ",", ":", "-").Replace(value)
+	return strings.TrimLeft(strings.Join(strings.Fields(value), " "), "([ ")
+}
+
+// writeBeancount writes every payment as a beancount transaction, preceded by the directives opening all the accounts used
+func writeBeancount(w io.Writer, data *exportData) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "option \"operating_currency\" %s\n\n", beancountString(data.settings.currency))
+
+	if len(data.payments) > 0 {
+		accounts := map[string]bool{data.settings.fundingAccount: true}
+		for _, payment := range data.payments {
+			accounts[categoryAccount(payment.categoryName)] = true
+		}
+		sortedAccounts := make([]string, 0, len(accounts))
+		for account := range accounts {
+			sortedAccounts = append(sortedAccounts, account)
+		}
+		sort.Strings(sortedAccounts)
+
+		openDate := data.payments[0].madeTime.Format("2006-01-02")
+		for _, account := range sortedAccounts {
+			fmt.Fprintf(bw, "%s open %s\n", openDate, account)
+		}
+		fmt.Fprintln(bw)
+	}
+
+	for _, payment := range data.payments {
+		fmt.Fprintf(bw, "%s * %s\n", payment.madeTime.Format("2006-01-02"), beancountString(payment.comment))
+		fmt.Fprintf(bw, "  payment_id: %s\n", beancountString(payment.id))
+		if payment.author.name != "" {
+			fmt.Fprintf(bw, "  author: %s\n", beancountString(payment.author.name))
+		}
+		fmt.Fprintf(bw, "  %-40s  %s %s\n", categoryAccount(payment.categoryName), formatAmount(payment.amount), payment.currency)
+		fmt.Fprintf(bw, "  %s\n\n", data.settings.fundingAccount)
+	}
+	return bw.Flush()
+}
+
+func beancountString(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ").Replace(value) + `"`
+}
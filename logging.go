@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// The log is structured, so that the entries of a chat, a user or a subhandler can be found.
+// What users type is logged as well, except passwords and invite tokens
+
+// newLogger writes entries of the level and above, e.g. "debug" or "warn", either as text or as JSON
+func newLogger(w io.Writer, format string, level string) (*slog.Logger, error) {
+	var minLevel slog.Level
+	if level != "" {
+		if err := minLevel.UnmarshalText([]byte(level)); err != nil {
+			return nil, err
+		}
+	}
+
+	options := &slog.HandlerOptions{Level: minLevel}
+	switch strings.ToLower(format) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q, expected text or json", format)
+}
+
+// secretStages expect input which must never end up in the log
+var secretStages = map[ChatStage]bool{
+	CreateSheetInputPassword:         true,
+	ConnectToSheetInputPassword:      true,
+	ChangeSheetPasswordInputPassword: true,
+	RevokeInviteInputToken:           true,
+}
+
+const redactedInput = "[redacted]"
+
+// loggedInput is the text as it may be logged. The stage the chat is in is checked as well as the stage
+// of the subhandler, so that a command typed instead of the password doesn't reveal the password either.
+// Commands which handle their arguments themselves get them from invite links, so only the command is kept
+func loggedInput(stage ChatStage, sh Subhandler, text string) string {
+	if secretStages[stage] || secretStages[sh.expectedStage] {
+		return redactedInput
+	}
+	if cmd, ok := parseCommand(text); ok && sh.withPayload && cmd.arguments != "" {
+		return cmd.name + " " + redactedInput
+	}
+	return text
+}
+
+// name identifies the subhandler in the log, it is checked in the same order the subhandler is chosen in
+func (sh Subhandler) name() string {
+	switch {
+	case sh.expectedCallback != "":
+		return "callback:" + sh.expectedCallback
+	case sh.expectedDocument:
+		return "document"
+	case sh.expectedText != "":
+		return sh.expectedText
+	case sh.expectedStage != None:
+		return sh.expectedStage.String()
+	}
+	return "default"
+}
+
+// chatLogger adds the chat and the user to the entries
+func chatLogger(key chatStatusKey) *slog.Logger {
+	return slog.With("chat_id", key.chatID, "user_id", key.userID)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureLog makes the handler log JSON into the returned buffer until the test ends
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buffer bytes.Buffer
+	logger, err := newLogger(&buffer, "json", "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buffer
+}
+
+func TestPasswordsNotLogged(t *testing.T) {
+	logged := captureLog(t)
+	c := newConversation(t)
+	alice := c.privateChat(100, "Alice")
+	bob := c.privateChat(200, "Bob")
+
+	sheetID := createSheet(alice, "Home", "first-password")
+	alice.script(step{"/changePassword second-password", MESSAGE_SUCCESS_CHANGE_SHEET_PASSWORD})
+	bob.script(
+		step{"/connectSheet " + sheetID, MESSAGE_INPUT_SHEET_PASSWORD},
+		step{"second-password", MESSAGE_SUCCESS_CONNECT_TO_SHEET},
+	)
+
+	if strings.Contains(logged.String(), "first-password") || strings.Contains(logged.String(), "second-password") {
+		t.Errorf("a password is logged:\n%s", logged)
+	}
+
+	redacted := 0
+	for _, line := range strings.Split(strings.TrimSpace(logged.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("%q is not JSON: %v", line, err)
+		}
+		if entry["input"] == redactedInput {
+			redacted++
+			if _, ok := entry["subhandler"]; !ok || entry["chat_id"] == nil || entry["user_id"] == nil {
+				t.Errorf("%q has no subhandler, chat or user", line)
+			}
+		}
+	}
+	if redacted != 3 {
+		t.Errorf("%d inputs are redacted, expected 3:\n%s", redacted, logged)
+	}
+}
+
+func TestInviteTokensNotLogged(t *testing.T) {
+	logged := captureLog(t)
+	c := newConversation(t)
+	alice := c.privateChat(100, "Alice")
+	bob := c.privateChat(200, "Bob")
+	sheetID := createSheet(alice, "Home", "secret")
+
+	token, err := generateInviteToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.storage.InsertNewInvite(context.Background(), sheetID, alice.chatID(), token, RoleEditor, 2, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	bob.script(step{"/start " + token, MESSAGE_SUCCESS_CONNECT_TO_SHEET})
+	alice.script(
+		step{"/revokeInvite", MESSAGE_INPUT_INVITE_TOKEN},
+		step{token, MESSAGE_SUCCESS_REVOKE_INVITE},
+	)
+
+	if strings.Contains(logged.String(), token) {
+		t.Errorf("the invite token is logged:\n%s", logged)
+	}
+	if !strings.Contains(logged.String(), `"input":"/start `+redactedInput+`"`) {
+		t.Errorf("the command of the invite link is not logged:\n%s", logged)
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	var buffer bytes.Buffer
+	logger, err := newLogger(&buffer, "text", "warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown", "chat_id", 1)
+	if logged := buffer.String(); strings.Contains(logged, "hidden") || !strings.Contains(logged, "msg=shown chat_id=1") {
+		t.Errorf("got log %q", logged)
+	}
+
+	if _, err := newLogger(&buffer, "xml", ""); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+	if _, err := newLogger(&buffer, "json", "loud"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"log"
+	"log/slog"
+	"os"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 )
 
@@ -16,6 +18,17 @@ func main() {
 		log.Panic(err)
 	}
 
+	logLevel := conf.LogLevel
+	if logLevel == "" && conf.Debug {
+		logLevel = "debug"
+	}
+	logger, err := newLogger(os.Stderr, conf.LogFormat, logLevel)
+	if err != nil {
+		log.Panic(err)
+	}
+	// The standard logger, used by the libraries as well, writes through it too
+	slog.SetDefault(logger)
+
 	bot, err := tgbotapi.NewBotAPI(conf.TelegramBotKey)
 	if err != nil {
 		log.Panic(err)
@@ -25,19 +38,21 @@ func main() {
 	if err != nil {
 		log.Panic(err)
 	}
+	if conf.MetricsAddress != "" {
+		storage = newMeteredStorage(storage)
+		go serveMetrics(conf.MetricsAddress)
+	}
 
 	bot.Debug = conf.Debug
 
-	if conf.Debug {
-		log.Printf("Authorized on account %s", bot.Self.UserName)
-	}
+	slog.Debug("authorized", "bot_user_name", bot.Self.UserName)
 
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 
 	updates, err := bot.GetUpdatesChan(u)
 
-	handler := CreateHandler(storage, bot)
+	handler := CreateHandler(storage, bot, bot.Self)
 	for update := range updates {
 		handler.ProcessUpdate(&update)
 	}
@@ -46,7 +61,14 @@ func main() {
 type Conf struct {
 	SQLConnection  string
 	TelegramBotKey string
-	Debug          bool
+	// Also makes the Telegram library log the raw updates, which contain everything users type, passwords included
+	Debug bool
+	// "text" or "json", text by default
+	LogFormat string
+	// "debug", "info", "warn" or "error", info by default or debug with Debug on
+	LogLevel string
+	// E.g. ":9090", Prometheus metrics are served at /metrics on it. No metrics are served if it is empty
+	MetricsAddress string
 }
 
 func readConfiguration() (*Conf, error) {
@@ -64,11 +86,18 @@ func readConfiguration() (*Conf, error) {
 	return &conf, nil
 }
 
-func initStorage(conf *Conf) (*Storage, error) {
-	db, err := sql.Open("mysql", conf.SQLConnection)
+func initStorage(conf *Conf) (Storage, error) {
+	mysqlConf, err := mysql.ParseDSN(conf.SQLConnection)
+	if err != nil {
+		return nil, err
+	}
+	// DATETIME columns are scanned into time.Time
+	mysqlConf.ParseTime = true
+
+	db, err := sql.Open("mysql", mysqlConf.FormatDSN())
 	if err != nil {
 		return nil, err
 	}
 
-	return &Storage{db: db}, nil
+	return newMySQLStorage(db), nil
 }
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The bot exports its metrics in the Prometheus text format at /metrics, if MetricsAddress is configured.
+// The few metric types it needs are implemented here, so that the bot doesn't depend on the Prometheus client
+
+// botMetrics is updated by the handler while the HTTP listener reads it, so every metric has a lock
+var botMetrics = newMetrics()
+
+type metrics struct {
+	updates          *valueVec
+	replyDuration    *histogramVec
+	storageDuration  *histogramVec
+	storageErrors    *valueVec
+	activeFlows      *valueVec
+	paymentsRecorded *valueVec
+	telegramErrors   *valueVec
+	lastUpdate       *valueVec
+
+	startTime time.Time
+}
+
+// Seconds, from a cached query to a reply which waited for several retries
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+func newMetrics() *metrics {
+	return &metrics{
+		updates: newValueVec("budgli_updates_total", "counter",
+			"Messages and button presses handled, by subhandler and result", "subhandler", "result"),
+		replyDuration: newHistogramVec("budgli_reply_duration_seconds",
+			"Time from receiving an update to sending the reply, by update type", latencyBuckets, "update"),
+		storageDuration: newHistogramVec("budgli_storage_duration_seconds",
+			"Duration of the storage calls, by Storage method", latencyBuckets, "method"),
+		storageErrors: newValueVec("budgli_storage_errors_total", "counter",
+			"Failed storage calls, by Storage method and error kind", "method", "kind"),
+		activeFlows: newValueVec("budgli_active_flows", "gauge",
+			"Users in the middle of a multi-step flow, by the stage they are at", "stage"),
+		paymentsRecorded: newValueVec("budgli_payments_recorded_total", "counter",
+			"Payments saved, by the way they were entered", "source"),
+		telegramErrors: newValueVec("budgli_telegram_errors_total", "counter",
+			"Failed calls to the Telegram bot API, by call", "call"),
+		lastUpdate: newValueVec("budgli_last_update_timestamp_seconds", "gauge",
+			"Unix time the last update was handled at"),
+		startTime: time.Now(),
+	}
+}
+
+// valueVec is a counter or a gauge with a value for every combination of the label values
+type valueVec struct {
+	name       string
+	metricType string
+	help       string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newValueVec(name string, metricType string, help string, labels ...string) *valueVec {
+	return &valueVec{name: name, metricType: metricType, help: help, labels: labels, values: make(map[string]float64)}
+}
+
+// Label values are joined into a map key with a character they can't contain in practice
+const labelValueSeparator = "\x00"
+
+func (v *valueVec) add(delta float64, labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.values[strings.Join(labelValues, labelValueSeparator)] += delta
+}
+
+func (v *valueVec) set(value float64, labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.values[strings.Join(labelValues, labelValueSeparator)] = value
+}
+
+func (v *valueVec) get(labelValues ...string) float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.values[strings.Join(labelValues, labelValueSeparator)]
+}
+
+// replace sets the values by the only label, the values which are not given become zero
+func (v *valueVec) replace(values map[string]float64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for key := range v.values {
+		v.values[key] = 0
+	}
+	for key, value := range values {
+		v.values[key] = value
+	}
+}
+
+func (v *valueVec) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	writeHeader(w, v.name, v.metricType, v.help)
+	for _, key := range sortedKeys(v.values) {
+		fmt.Fprintf(w, "%s%s %s\n", v.name, formatLabels(v.labels, key, ""), formatValue(v.values[key]))
+	}
+}
+
+type histogramVec struct {
+	name    string
+	help    string
+	buckets []float64
+	labels  []string
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+type histogram struct {
+	// Not cumulative, the observations in (buckets[i-1], buckets[i]], the last one is +Inf
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogramVec(name string, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, buckets: buckets, labels: labels, series: make(map[string]*histogram)}
+}
+
+func (h *histogramVec) observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := strings.Join(labelValues, labelValueSeparator)
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogram{counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = series
+	}
+	series.counts[sort.SearchFloat64s(h.buckets, value)]++
+	series.sum += value
+	series.count++
+}
+
+func (h *histogramVec) count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if series, ok := h.series[strings.Join(labelValues, labelValueSeparator)]; ok {
+		return series.count
+	}
+	return 0
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeHeader(w, h.name, "histogram", h.help)
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		series := h.series[key]
+		var cumulative uint64
+		for i, count := range series.counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(h.buckets) {
+				le = formatValue(h.buckets[i])
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, le), cumulative)
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, key, ""), formatValue(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, key, ""), series.count)
+	}
+}
+
+func writeHeader(w io.Writer, name string, metricType string, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels formats the labels with the values joined in the key, and the histogram bucket if le is set
+func formatLabels(labels []string, key string, le string) string {
+	var pairs []string
+	if len(labels) > 0 {
+		for i, value := range strings.SplitN(key, labelValueSeparator, len(labels)) {
+			pairs = append(pairs, labels[i]+`="`+labelValueReplacer.Replace(value)+`"`)
+		}
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// writeProcessMetrics adds the health of the process itself, under the names the Prometheus client uses
+func (m *metrics) writeProcessMetrics(w io.Writer) {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	writeHeader(w, "process_start_time_seconds", "gauge", "Start time of the process since unix epoch in seconds")
+	fmt.Fprintf(w, "process_start_time_seconds %d\n", m.startTime.Unix())
+	writeHeader(w, "go_goroutines", "gauge", "Number of goroutines that currently exist")
+	fmt.Fprintf(w, "go_goroutines %d\n", runtime.NumGoroutine())
+	writeHeader(w, "go_memstats_heap_alloc_bytes", "gauge", "Number of heap bytes allocated and still in use")
+	fmt.Fprintf(w, "go_memstats_heap_alloc_bytes %d\n", memStats.HeapAlloc)
+	writeHeader(w, "go_memstats_sys_bytes", "gauge", "Number of bytes obtained from system")
+	fmt.Fprintf(w, "go_memstats_sys_bytes %d\n", memStats.Sys)
+	writeHeader(w, "go_gc_cycles_total", "counter", "Number of completed GC cycles")
+	fmt.Fprintf(w, "go_gc_cycles_total %d\n", memStats.NumGC)
+}
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	buffered := bufio.NewWriter(w)
+	m.updates.write(buffered)
+	m.replyDuration.write(buffered)
+	m.storageDuration.write(buffered)
+	m.storageErrors.write(buffered)
+	m.activeFlows.write(buffered)
+	m.paymentsRecorded.write(buffered)
+	m.telegramErrors.write(buffered)
+	m.lastUpdate.write(buffered)
+	m.writeProcessMetrics(buffered)
+	buffered.Flush()
+}
+
+// serveMetrics runs the HTTP listener for Prometheus, a failure to listen doesn't stop the bot
+func serveMetrics(address string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", botMetrics)
+	slog.Info("serving metrics", "address", address)
+	if err := http.ListenAndServe(address, mux); err != nil {
+		slog.Error("metrics listener stopped", "address", address, "error", err)
+	}
+}
+
+// recordActiveFlows counts the users by the stage of the flow they are in, it is called by the handler
+// after every update, since the chat statuses may only be read in between the updates
+func recordActiveFlows() {
+	flows := make(map[string]float64)
+	for _, status := range chatStatuses {
+		if status.stage != None {
+			flows[status.stage.String()]++
+		}
+	}
+	botMetrics.activeFlows.replace(flows)
+	botMetrics.lastUpdate.set(float64(time.Now().Unix()))
+}
+
+// storageErrorKind names the kind of the error as a label value
+func storageErrorKind(err error) string {
+	switch {
+	case errors.Is(err, errNotFound):
+		return "not_found"
+	case errors.Is(err, errDuplicate):
+		return "duplicate"
+	case errors.Is(err, errConstraint):
+		return "constraint"
+	case errors.Is(err, errTransient):
+		return "transient"
+	}
+	return "other"
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsFormat(t *testing.T) {
+	var out strings.Builder
+	values := newValueVec("test_total", "counter", "Test counter", "name", "result")
+	values.add(1, `say "hi"`, "ok")
+	values.add(2, `say "hi"`, "ok")
+	values.add(1, "a\\b", "failure")
+	values.write(&out)
+
+	histogram := newHistogramVec("test_seconds", "Test histogram", []float64{0.1, 1}, "update")
+	histogram.observe(0.05, "message")
+	histogram.observe(0.5, "message")
+	histogram.observe(5, "message")
+	histogram.write(&out)
+
+	expected := `# HELP test_total Test counter
+# TYPE test_total counter
+test_total{name="a\\b",result="failure"} 1
+test_total{name="say \"hi\"",result="ok"} 3
+# HELP test_seconds Test histogram
+# TYPE test_seconds histogram
+test_seconds_bucket{update="message",le="0.1"} 1
+test_seconds_bucket{update="message",le="1"} 2
+test_seconds_bucket{update="message",le="+Inf"} 3
+test_seconds_sum{update="message"} 5.55
+test_seconds_count{update="message"} 3
+`
+	if out.String() != expected {
+		t.Errorf("got\n%s\nexpected\n%s", out.String(), expected)
+	}
+}
+
+func TestMetricsRecorded(t *testing.T) {
+	c := newConversation(t)
+	c.handler.storage = newMeteredStorage(c.storage)
+	alice := c.privateChat(100, "Alice")
+	createSheet(alice, "Home", "secret")
+	alice.script(
+		step{"/createCategory", MESSAGE_INPUT_CATEGORY_NAME},
+		step{"Food", MESSAGE_SUCCESS_CREATE_CATEGORY},
+	)
+
+	payments := botMetrics.paymentsRecorded.get("message")
+	updates := botMetrics.updates.get("default", "ok")
+	inserts := botMetrics.storageDuration.count("InsertNewPayment")
+	replies := botMetrics.replyDuration.count("message")
+
+	alice.script(step{"12.50 food", MESAGE_SUCCESS_CREATE_PAYMENT})
+	if got := botMetrics.paymentsRecorded.get("message") - payments; got != 1 {
+		t.Errorf("%v payments recorded, expected 1", got)
+	}
+	if got := botMetrics.updates.get("default", "ok") - updates; got != 1 {
+		t.Errorf("%v updates counted, expected 1", got)
+	}
+	if got := botMetrics.storageDuration.count("InsertNewPayment") - inserts; got != 1 {
+		t.Errorf("%v inserts timed, expected 1", got)
+	}
+	if got := botMetrics.replyDuration.count("message") - replies; got != 1 {
+		t.Errorf("%v replies timed, expected 1", got)
+	}
+
+	recorder := httptest.NewRecorder()
+	botMetrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	for _, name := range []string{"budgli_updates_total{", "budgli_payments_recorded_total{", "budgli_storage_duration_seconds_bucket{", "go_goroutines "} {
+		if !strings.Contains(recorder.Body.String(), name) {
+			t.Errorf("%s is not exported", name)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ofxTransactionRe = regexp.MustCompile(`(?is)<STMTTRN>(.*?)</STMTTRN>`)
+	// OFX 1.x is SGML where leaf elements are not closed, so a value ends at the next tag or line break
+	ofxFieldRe = regexp.MustCompile(`(?i)<(DTPOSTED|TRNAMT|NAME|MEMO)>([^<\r\n]*)`)
+)
+
+// parseOFX reads the bank transactions of both SGML (1.x) and XML (2.x) OFX files.
+// Transactions which can't be parsed are skipped and counted
+func parseOFX(content []byte) ([]importedPayment, int) {
+	var payments []importedPayment
+	skipped := 0
+
+	for _, transaction := range ofxTransactionRe.FindAllStringSubmatch(string(content), -1) {
+		fields := make(map[string]string)
+		for _, field := range ofxFieldRe.FindAllStringSubmatch(transaction[1], -1) {
+			fields[strings.ToUpper(field[1])] = strings.TrimSpace(ofxUnescape(field[2]))
+		}
+
+		madeTime, ok := parseOFXDate(fields["DTPOSTED"])
+		if !ok {
+			skipped++
+			continue
+		}
+		amount, err := parseStatementAmount(fields["TRNAMT"])
+		if err != nil {
+			skipped++
+			continue
+		}
+		description := fields["NAME"]
+		if memo := fields["MEMO"]; memo != "" && memo != description {
+			description = strings.TrimSpace(description + " " + memo)
+		}
+
+		payments = append(payments, importedPayment{
+			madeTime: madeTime,
+			// Debits are negative in OFX, while spending is positive in a sheet
+			amount:      -amount,
+			description: truncateComment(description),
+		})
+	}
+
+	return payments, skipped
+}
+
+// parseOFXDate reads the date part of YYYYMMDD[HHMMSS[.XXX][[TZ]]], the time of a payment is not that precise anyway
+func parseOFXDate(date string) (time.Time, bool) {
+	if len(date) < 8 {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation("20060102", date[:8], statementLocation)
+	return t, err == nil
+}
+
+func ofxUnescape(value string) string {
+	return strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'").Replace(value)
+}
+
+func ofxEscape(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
+
+// mixedCurrenciesError is returned by writeOFX for payments in several currencies. A statement only has one,
+// and the rates to convert the rest to it are not known
+type mixedCurrenciesError struct {
+	currencies []string
+}
+
+func (e *mixedCurrenciesError) Error() string {
+	return "payments in several currencies: " + strings.Join(e.currencies, ", ")
+}
+
+// writeOFX writes the payments as an OFX 2 bank statement in their currency, spending becomes debits.
+// Without payments the statement is in the sheet currency
+func writeOFX(w io.Writer, data *exportData) error {
+	const dateLayout = "20060102150405"
+
+	currency := data.settings.currency
+	seen := make(map[string]bool)
+	var currencies []string
+	for _, payment := range data.payments {
+		if !seen[payment.currency] {
+			seen[payment.currency] = true
+			currencies = append(currencies, payment.currency)
+		}
+	}
+	if len(currencies) > 1 {
+		sort.Strings(currencies)
+		return &mixedCurrenciesError{currencies: currencies}
+	}
+	if len(currencies) == 1 {
+		currency = currencies[0]
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, `<?xml version="1.0" encoding="UTF-8" standalone="no"?>`)
+	fmt.Fprintln(bw, `<?OFX OFXHEADER="200" VERSION="211" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>`)
+	fmt.Fprintln(bw, "<OFX>")
+	fmt.Fprintln(bw, "<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>")
+	fmt.Fprintf(bw, "<DTSERVER>%s</DTSERVER><LANGUAGE>ENG</LANGUAGE></SONRS></SIGNONMSGSRSV1>\n", time.Now().Format(dateLayout))
+	fmt.Fprintln(bw, "<BANKMSGSRSV1><STMTTRNRS><TRNUID>0</TRNUID><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>")
+	fmt.Fprintf(bw, "<STMTRS><CURDEF>%s</CURDEF>\n", ofxEscape(currency))
+	fmt.Fprintf(bw, "<BANKACCTFROM><BANKID>budgli</BANKID><ACCTID>%s</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE></BANKACCTFROM>\n", data.sheetID)
+	fmt.Fprintf(bw, "<BANKTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>\n", data.from.Format(dateLayout), data.to.Format(dateLayout))
+	for _, payment := range data.payments {
+		transactionType := "DEBIT"
+		if payment.amount < 0 {
+			transactionType = "CREDIT"
+		}
+		fmt.Fprintf(bw, "<STMTTRN><TRNTYPE>%s</TRNTYPE><DTPOSTED>%s</DTPOSTED><TRNAMT>%s</TRNAMT><FITID>%s</FITID>",
+			transactionType, payment.madeTime.Format(dateLayout), formatAmount(-payment.amount), payment.id)
+		fmt.Fprintf(bw, "<NAME>%s</NAME><MEMO>%s</MEMO></STMTTRN>\n", ofxEscape(payment.categoryName), ofxEscape(payment.comment))
+	}
+	fmt.Fprintln(bw, "</BANKTRANLIST>")
+	fmt.Fprintln(bw, "<LEDGERBAL><BALAMT>0.00</BALAMT>")
+	fmt.Fprintf(bw, "<DTASOF>%s</DTASOF></LEDGERBAL>\n", data.to.Format(dateLayout))
+	fmt.Fprintln(bw, "</STMTRS></STMTTRNRS></BANKMSGSRSV1>")
+	fmt.Fprintln(bw, "</OFX>")
+	return bw.Flush()
+}
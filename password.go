@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcrypt ignores everything after the first 72 bytes, so longer passwords are rejected instead
+const maxPasswordBytes = 72
+
+// Used to spend the same time checking a password of a sheet that doesn't exist
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func checkPasswordHash(hash string, password string) bool {
+	if isLegacyPasswordHash(hash) {
+		return checkLegacyPasswordHash(hash, password)
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// isLegacyPasswordHash detects hashes made by the MySQL PASSWORD() function: "*" followed by 40 hex digits
+func isLegacyPasswordHash(hash string) bool {
+	if len(hash) != 41 || hash[0] != '*' {
+		return false
+	}
+	_, err := hex.DecodeString(hash[1:])
+	return err == nil
+}
+
+// checkLegacyPasswordHash mirrors MySQL PASSWORD(), which is "*" + UPPER(SHA1(UNHEX(SHA1(password))))
+func checkLegacyPasswordHash(hash string, password string) bool {
+	first := sha1.Sum([]byte(password))
+	second := sha1.Sum(first[:])
+	expected := "*" + strings.ToUpper(hex.EncodeToString(second[:]))
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToUpper(hash))) == 1
+}
@@ -0,0 +1,166 @@
+package main
+
+import "time"
+
+// attemptPolicy describes how failed password attempts are throttled: the first freeAttempts failures are not
+// throttled, each further one doubles the delay before the next attempt is allowed, and once maxAttempts is
+// reached all attempts are rejected for lockout. Failures are forgotten after a quiet period of forgetAfter
+type attemptPolicy struct {
+	freeAttempts int
+	baseDelay    time.Duration
+	maxAttempts  int
+	lockout      time.Duration
+	forgetAfter  time.Duration
+}
+
+var (
+	chatAttemptPolicy = attemptPolicy{
+		freeAttempts: 3,
+		baseDelay:    5 * time.Second,
+		maxAttempts:  10,
+		lockout:      time.Hour,
+		forgetAfter:  24 * time.Hour,
+	}
+	// A sheet may legitimately get wrong passwords from several chats, so it is throttled less aggressively
+	sheetAttemptPolicy = attemptPolicy{
+		freeAttempts: 10,
+		baseDelay:    5 * time.Second,
+		maxAttempts:  30,
+		lockout:      time.Hour,
+		forgetAfter:  24 * time.Hour,
+	}
+)
+
+// The sheet owner is notified every this many failed attempts on their sheet
+const notifyOwnerEveryFailedAttempts = 5
+
+type attemptCounter struct {
+	failures    int
+	lastFailure time.Time
+	blockedTill time.Time
+}
+
+// Counters are only kept for chats and sheets with recent failures, the expired ones are dropped this often
+const attemptsSweepInterval = time.Hour
+
+type passwordAttempts struct {
+	byChat  map[int64]*attemptCounter
+	bySheet map[string]*attemptCounter
+	// When the chats last entered the right password of the sheet, by sheet. Kept for forgetAfter of the sheet policy
+	succeeded map[string]map[int64]time.Time
+	lastSweep time.Time
+}
+
+func newPasswordAttempts() *passwordAttempts {
+	return &passwordAttempts{
+		byChat:    make(map[int64]*attemptCounter),
+		bySheet:   make(map[string]*attemptCounter),
+		succeeded: make(map[string]map[int64]time.Time),
+	}
+}
+
+// blockedTill returns the time before which password attempts from the chat to the sheet are rejected, or the zero
+// time if they aren't. The lockout of the sheet doesn't apply to the chats which have entered its password recently,
+// otherwise anyone guessing the password could lock them out
+func (a *passwordAttempts) blockedTill(chatID int64, sheetID string, now time.Time) time.Time {
+	var till time.Time
+	if c, ok := a.byChat[chatID]; ok && c.blockedTill.After(now) {
+		till = c.blockedTill
+	}
+	if _, ok := a.succeeded[sheetID][chatID]; ok {
+		return till
+	}
+	if c, ok := a.bySheet[sheetID]; ok && c.blockedTill.After(now) && c.blockedTill.After(till) {
+		till = c.blockedTill
+	}
+	return till
+}
+
+// recordFailure returns the number of recent failed attempts on the sheet
+func (a *passwordAttempts) recordFailure(chatID int64, sheetID string, now time.Time) int {
+	a.sweep(now)
+	a.chatCounter(chatID, now).fail(chatAttemptPolicy, now)
+
+	sheetCounter := a.sheetCounter(sheetID, now)
+	sheetCounter.fail(sheetAttemptPolicy, now)
+	return sheetCounter.failures
+}
+
+// recordSuccess only forgets the failures of the chat. The failures on the sheet stay, otherwise any member
+// connecting would let someone guessing the password of a shared sheet start over
+func (a *passwordAttempts) recordSuccess(chatID int64, sheetID string, now time.Time) {
+	a.sweep(now)
+	delete(a.byChat, chatID)
+
+	if a.succeeded[sheetID] == nil {
+		a.succeeded[sheetID] = make(map[int64]time.Time)
+	}
+	a.succeeded[sheetID][chatID] = now
+}
+
+// sweep drops the counters which have expired
+func (a *passwordAttempts) sweep(now time.Time) {
+	if now.Sub(a.lastSweep) < attemptsSweepInterval {
+		return
+	}
+	a.lastSweep = now
+
+	for chatID, c := range a.byChat {
+		if c.expired(chatAttemptPolicy, now) {
+			delete(a.byChat, chatID)
+		}
+	}
+	for sheetID, c := range a.bySheet {
+		if c.expired(sheetAttemptPolicy, now) {
+			delete(a.bySheet, sheetID)
+		}
+	}
+	for sheetID, chats := range a.succeeded {
+		for chatID, succeededAt := range chats {
+			if now.Sub(succeededAt) > sheetAttemptPolicy.forgetAfter {
+				delete(chats, chatID)
+			}
+		}
+		if len(chats) == 0 {
+			delete(a.succeeded, sheetID)
+		}
+	}
+}
+
+func (a *passwordAttempts) chatCounter(chatID int64, now time.Time) *attemptCounter {
+	c, ok := a.byChat[chatID]
+	if !ok || c.expired(chatAttemptPolicy, now) {
+		c = &attemptCounter{}
+		a.byChat[chatID] = c
+	}
+	return c
+}
+
+func (a *passwordAttempts) sheetCounter(sheetID string, now time.Time) *attemptCounter {
+	c, ok := a.bySheet[sheetID]
+	if !ok || c.expired(sheetAttemptPolicy, now) {
+		c = &attemptCounter{}
+		a.bySheet[sheetID] = c
+	}
+	return c
+}
+
+func (c *attemptCounter) expired(policy attemptPolicy, now time.Time) bool {
+	return c.failures > 0 && now.After(c.blockedTill) && now.Sub(c.lastFailure) > policy.forgetAfter
+}
+
+func (c *attemptCounter) fail(policy attemptPolicy, now time.Time) {
+	c.failures++
+	c.lastFailure = now
+
+	switch {
+	case c.failures >= policy.maxAttempts:
+		c.blockedTill = now.Add(policy.lockout)
+	case c.failures > policy.freeAttempts:
+		delay := policy.baseDelay << uint(c.failures-policy.freeAttempts-1)
+		if delay > policy.lockout {
+			delay = policy.lockout
+		}
+		c.blockedTill = now.Add(delay)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSuccessKeepsSheetFailures(t *testing.T) {
+	attempts := newPasswordAttempts()
+	now := time.Now()
+	for i := 0; i < sheetAttemptPolicy.maxAttempts-1; i++ {
+		// Each guess comes from another chat, so that only the sheet gets throttled
+		attempts.recordFailure(int64(i), "sheet", now)
+	}
+
+	attempts.recordSuccess(1000, "sheet", now)
+	if failures := attempts.recordFailure(2000, "sheet", now); failures != sheetAttemptPolicy.maxAttempts {
+		t.Fatalf("got %d failures on the sheet, expected %d", failures, sheetAttemptPolicy.maxAttempts)
+	}
+	if till := attempts.blockedTill(3000, "sheet", now); !till.Equal(now.Add(sheetAttemptPolicy.lockout)) {
+		t.Errorf("sheet is blocked till %v, expected the lockout", till)
+	}
+
+	attempts.recordSuccess(2000, "sheet", now)
+	if _, ok := attempts.byChat[2000]; ok {
+		t.Error("the failures of the chat are kept after a success")
+	}
+}
+
+func TestSheetLockoutSparesKnownChats(t *testing.T) {
+	attempts := newPasswordAttempts()
+	now := time.Now()
+	attempts.recordSuccess(1, "sheet", now)
+	for i := 0; i < sheetAttemptPolicy.maxAttempts; i++ {
+		attempts.recordFailure(int64(100+i), "sheet", now)
+	}
+
+	if till := attempts.blockedTill(2, "sheet", now); !till.Equal(now.Add(sheetAttemptPolicy.lockout)) {
+		t.Errorf("a new chat is blocked till %v, expected the lockout", till)
+	}
+	if till := attempts.blockedTill(1, "sheet", now); !till.IsZero() {
+		t.Errorf("the chat which has entered the password is blocked till %v", till)
+	}
+	if till := attempts.blockedTill(2, "sheet", now.Add(sheetAttemptPolicy.lockout+time.Second)); !till.IsZero() {
+		t.Errorf("the lockout is over, yet the chat is blocked till %v", till)
+	}
+}
+
+func TestExpiredAttemptsEvicted(t *testing.T) {
+	attempts := newPasswordAttempts()
+	now := time.Now()
+	attempts.recordFailure(1, "old", now)
+	if till := attempts.blockedTill(2, "other", now); !till.IsZero() || len(attempts.byChat) != 1 || len(attempts.bySheet) != 1 {
+		t.Fatalf("checking a chat and a sheet without failures added counters, blocked till %v", till)
+	}
+
+	later := now.Add(sheetAttemptPolicy.forgetAfter + attemptsSweepInterval + time.Minute)
+	attempts.recordFailure(3, "new", later)
+	if _, ok := attempts.byChat[1]; ok {
+		t.Error("the expired chat counter is kept")
+	}
+	if _, ok := attempts.bySheet["old"]; ok {
+		t.Error("the expired sheet counter is kept")
+	}
+	if len(attempts.byChat) != 1 || len(attempts.bySheet) != 1 {
+		t.Errorf("got %d chat and %d sheet counters, expected only the new ones", len(attempts.byChat), len(attempts.bySheet))
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// QIF writes dates month first, older tools use an apostrophe before a two-digit year, e.g. 1/31'24
+var qifDateLayouts = []string{"1/2/2006", "1/2/06", "1/2'06", "1-2-2006", "2006-01-02", "2.1.2006"}
+
+// parseQIF reads the transactions of a QIF file. Transactions which can't be parsed are skipped and counted.
+// The category of a transaction (the L field) is kept only if the sheet has a category with this name
+func parseQIF(content []byte, categories []string) ([]importedPayment, int) {
+	var payments []importedPayment
+	skipped := 0
+
+	var date, amount, payee, memo, category string
+	flush := func() {
+		defer func() { date, amount, payee, memo, category = "", "", "", "", "" }()
+		if date == "" && amount == "" {
+			return
+		}
+
+		madeTime, ok := parseQIFDate(date)
+		if !ok {
+			skipped++
+			return
+		}
+		parsedAmount, err := parseStatementAmount(amount)
+		if err != nil {
+			skipped++
+			return
+		}
+		description := payee
+		if memo != "" && memo != payee {
+			description = strings.TrimSpace(description + " " + memo)
+		}
+
+		payment := importedPayment{
+			madeTime: madeTime,
+			// Withdrawals are negative in QIF, while spending is positive in a sheet
+			amount:      -parsedAmount,
+			description: truncateComment(description),
+		}
+		for _, c := range categories {
+			if normalizeText(c) == normalizeText(category) {
+				payment.categoryName = c
+			}
+		}
+		payments = append(payments, payment)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		value := strings.TrimSpace(line[1:])
+		switch line[0] {
+		case 'D':
+			date = value
+		case 'T', 'U':
+			amount = value
+		case 'P':
+			payee = value
+		case 'M':
+			memo = value
+		case 'L':
+			category = value
+		case '^':
+			flush()
+		}
+	}
+	flush()
+
+	return payments, skipped
+}
+
+func parseQIFDate(date string) (time.Time, bool) {
+	date = strings.Replace(strings.TrimSpace(date), " ", "", -1)
+	for _, layout := range qifDateLayouts {
+		if t, err := time.ParseInLocation(layout, date, statementLocation); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// writeQIF writes the payments as a bank account register, spending becomes withdrawals
+func writeQIF(w io.Writer, payments []Payment) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "!Type:Bank")
+	for _, payment := range payments {
+		fmt.Fprintf(bw, "D%s\n", payment.madeTime.Format("01/02/2006"))
+		fmt.Fprintf(bw, "T%s\n", formatAmount(-payment.amount))
+		if payment.comment != "" {
+			fmt.Fprintf(bw, "P%s\n", qifValue(payment.comment))
+		}
+		if payment.categoryName != "" {
+			fmt.Fprintf(bw, "L%s\n", qifValue(payment.categoryName))
+		}
+		fmt.Fprintln(bw, "^")
+	}
+	return bw.Flush()
+}
+
+// qifValue keeps a value on a single line, as QIF has no escaping
+func qifValue(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// A rule is written as "<condition> [and <condition>...] -> <category>", where a condition is one of
+//
+//	description contains <text>
+//	description matches /<regexp>/
+//	amount <op> <number>, op being one of > >= < <= =
+//
+// The "description" word can be omitted. A text with " and " in it has to be quoted, e.g. contains "bread and butter"
+var (
+	ruleArrowRe        = regexp.MustCompile(`\s*(?:->|→)\s*`)
+	ruleAndRe          = regexp.MustCompile(`(?i)^\s+and\s+`)
+	ruleContainsRe     = regexp.MustCompile(`(?i)^(?:description\s+)?contains\s+(.+)$`)
+	ruleMatchesRe      = regexp.MustCompile(`(?i)^(?:description\s+)?matches\s+/(.+)/$`)
+	ruleAmountRe       = regexp.MustCompile(`(?i)^amount\s*(>=|<=|>|<|=)\s*(.+)$`)
+	errRuleFormat      = errors.New("rule should be written as <condition> -> <category>")
+	errRuleCondition   = errors.New("unknown rule condition")
+	errRuleRegexp      = errors.New("invalid regular expression in rule")
+	errRuleAmount      = errors.New("invalid amount in rule")
+	errRuleNoCondition = errors.New("rule has no conditions")
+)
+
+type ruleCondition func(description string, amount int64) bool
+
+// CategorizationRule assigns a category to a payment when all of its conditions hold
+type CategorizationRule struct {
+	id       string
+	position int
+	// The part of the rule before the arrow, the category is stored separately so that renaming it doesn't break the rule
+	conditionsText string
+	categoryName   string
+
+	conditions []ruleCondition
+}
+
+// parseRule parses the text of a rule, the category is returned as written and is not checked
+func parseRule(text string) (*CategorizationRule, error) {
+	parts := ruleArrowRe.Split(strings.TrimSpace(text), -1)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, errRuleFormat
+	}
+
+	rule := &CategorizationRule{conditionsText: parts[0], categoryName: parts[1]}
+	if err := rule.parseConditions(); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *CategorizationRule) parseConditions() error {
+	if r.conditionsText == "" {
+		return errRuleNoCondition
+	}
+
+	for _, condition := range splitConditions(r.conditionsText) {
+		if matches := ruleContainsRe.FindStringSubmatch(condition); matches != nil {
+			needle := normalizeText(strings.Trim(matches[1], `'"`))
+			r.conditions = append(r.conditions, func(description string, _ int64) bool {
+				return strings.Contains(normalizeText(description), needle)
+			})
+		} else if matches := ruleMatchesRe.FindStringSubmatch(condition); matches != nil {
+			re, err := regexp.Compile("(?i)" + matches[1])
+			if err != nil {
+				return errRuleRegexp
+			}
+			r.conditions = append(r.conditions, func(description string, _ int64) bool {
+				return re.MatchString(description)
+			})
+		} else if matches := ruleAmountRe.FindStringSubmatch(condition); matches != nil {
+			threshold, err := parseStatementAmount(matches[2])
+			if err != nil {
+				return errRuleAmount
+			}
+			op := matches[1]
+			r.conditions = append(r.conditions, func(_ string, amount int64) bool {
+				switch op {
+				case ">":
+					return amount > threshold
+				case ">=":
+					return amount >= threshold
+				case "<":
+					return amount < threshold
+				case "<=":
+					return amount <= threshold
+				}
+				return amount == threshold
+			})
+		} else {
+			return errRuleCondition
+		}
+	}
+	return nil
+}
+
+// splitConditions splits the text on "and", except where it is a part of a quoted text or a regexp.
+// An operand is quoted when it starts with a quote or a slash, and ends with the same one before a space
+func splitConditions(text string) []string {
+	var conditions []string
+	start := 0
+	// The quote or the slash which ends the operand being read, zero outside of one
+	var closing byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case closing != 0:
+			if c == '\\' && closing == '/' {
+				i++
+			} else if c == closing && (i+1 == len(text) || isSpaceByte(text[i+1])) {
+				closing = 0
+			}
+		case (c == '"' || c == '\'' || c == '/') && (i == 0 || isSpaceByte(text[i-1])):
+			closing = c
+		case isSpaceByte(c):
+			if loc := ruleAndRe.FindStringIndex(text[i:]); loc != nil {
+				conditions = append(conditions, text[start:i])
+				start = i + loc[1]
+				i = start - 1
+			}
+		}
+	}
+	return append(conditions, text[start:])
+}
+
+func isSpaceByte(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func (r *CategorizationRule) String() string {
+	return r.conditionsText + " -> " + r.categoryName
+}
+
+func (r *CategorizationRule) matches(description string, amount int64) bool {
+	for _, condition := range r.conditions {
+		if !condition(description, amount) {
+			return false
+		}
+	}
+	return true
+}
+
+// categorizeByRules returns the category of the first matching rule, or an empty string
+func categorizeByRules(rules []*CategorizationRule, description string, amount int64) string {
+	for _, rule := range rules {
+		if rule.matches(description, amount) {
+			return rule.categoryName
+		}
+	}
+	return ""
+}
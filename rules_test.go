@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		text        string
+		description string
+		amount      int64
+		matches     bool
+		err         error
+	}{
+		{`contains bread -> food`, "Fresh bread", 100, true, nil},
+		{`contains bread and amount > 5 -> food`, "Fresh bread", 100, false, nil},
+		{`contains bread AND amount < 5 -> food`, "Fresh bread", 100, true, nil},
+		// "and" inside a quoted text or a regexp doesn't separate conditions
+		{`contains "bread and butter" -> food`, "Bread and butter", 100, true, nil},
+		{`contains "bread and butter" -> food`, "Bread", 100, false, nil},
+		{`description contains 'rock and roll' and amount >= 1 -> fun`, "Rock and roll hall", 100, true, nil},
+		{`matches /salt and (pepper|vinegar)/ -> food`, "Salt and vinegar chips", 100, true, nil},
+		{`matches /a\/b and c/ and amount = 1 -> food`, "a/b and c", 100, true, nil},
+		{`contains don't and amount > 0 -> food`, "Don't stop", 100, true, nil},
+		{`contains bread and butter -> food`, "", 0, false, errRuleCondition},
+		{`matches /(/ -> food`, "", 0, false, errRuleRegexp},
+		{`amount > x -> food`, "", 0, false, errRuleAmount},
+		{`contains bread`, "", 0, false, errRuleFormat},
+		{` -> food`, "", 0, false, errRuleNoCondition},
+	}
+	for _, test := range tests {
+		rule, err := parseRule(test.text)
+		if err != test.err {
+			t.Errorf("parseRule(%q) = %v, expected %v", test.text, err, test.err)
+			continue
+		}
+		if err == nil && rule.matches(test.description, test.amount) != test.matches {
+			t.Errorf("rule %q matches %q, %d: %v, expected %v", test.text, test.description, test.amount, !test.matches, test.matches)
+		}
+	}
+}
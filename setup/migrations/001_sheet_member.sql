@@ -0,0 +1,18 @@
+-- Introduces sheet members with roles. Sheet owners become owners, every other chat
+-- currently connected to a sheet becomes an editor, which is what the password used to give.
+
+USE `budgli`;
+
+CREATE TABLE `sheet_member` (
+  `sheet_id` varchar(36) NOT NULL,
+  `chat_id` bigint(20) NOT NULL,
+  `role` varchar(10) NOT NULL,
+  PRIMARY KEY (`sheet_id`, `chat_id`),
+  KEY `sheet_member_chat_id_IDX` (`chat_id`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+INSERT INTO `sheet_member` (`sheet_id`, `chat_id`, `role`)
+SELECT `sheet_id`, `owner_chat_id`, 'owner' FROM `sheet`;
+
+INSERT IGNORE INTO `sheet_member` (`sheet_id`, `chat_id`, `role`)
+SELECT `sheet_id`, `chat_id`, 'editor' FROM `current_sheet`;
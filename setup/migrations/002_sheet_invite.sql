@@ -0,0 +1,14 @@
+-- Introduces one-time invite links to sheets.
+
+USE `budgli`;
+
+CREATE TABLE `sheet_invite` (
+  `token` varchar(64) NOT NULL,
+  `sheet_id` varchar(36) NOT NULL,
+  `created_by_chat_id` bigint(20) NOT NULL,
+  `role` varchar(10) NOT NULL,
+  `uses_left` int(11) NOT NULL,
+  `expires_at` datetime NOT NULL,
+  PRIMARY KEY (`token`),
+  KEY `sheet_invite_sheet_id_IDX` (`sheet_id`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,6 @@
+-- Makes room for bcrypt password hashes. Hashes made by the MySQL PASSWORD() function
+-- keep working and are replaced by the bot on the next successful password check.
+
+USE `budgli`;
+
+ALTER TABLE `sheet` MODIFY `password` varchar(255) NOT NULL;
@@ -0,0 +1,13 @@
+-- Introduces saved column mappings for bank statement imports.
+
+USE `budgli`;
+
+CREATE TABLE `import_profile` (
+  `sheet_id` varchar(36) NOT NULL,
+  `date_column` varchar(100) NOT NULL,
+  `amount_column` varchar(100) NOT NULL,
+  `description_column` varchar(100) NOT NULL,
+  `date_layout` varchar(30) NOT NULL,
+  `expenses_negative` tinyint(1) NOT NULL,
+  PRIMARY KEY (`sheet_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,10 @@
+-- Introduces the sheet currency and the account payments are funded from in plain-text accounting exports.
+-- Payments may override the sheet currency.
+
+USE `budgli`;
+
+ALTER TABLE `sheet`
+  ADD `currency` varchar(10) NOT NULL DEFAULT 'EUR',
+  ADD `funding_account` varchar(100) NOT NULL DEFAULT 'Assets:Cash';
+
+ALTER TABLE `payment` ADD `currency` varchar(10) DEFAULT NULL AFTER `amount`;
@@ -0,0 +1,13 @@
+-- Introduces per-sheet rules assigning categories to imported and free-text payments.
+
+USE `budgli`;
+
+CREATE TABLE `categorization_rule` (
+  `rule_id` varchar(36) NOT NULL,
+  `sheet_id` varchar(36) NOT NULL,
+  `position` int(11) NOT NULL,
+  `conditions` varchar(255) NOT NULL,
+  `category_id` varchar(36) NOT NULL,
+  PRIMARY KEY (`rule_id`),
+  KEY `categorization_rule_sheet_id_IDX` (`sheet_id`, `position`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
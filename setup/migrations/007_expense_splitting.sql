@@ -0,0 +1,25 @@
+-- Introduces shared payments split between sheet members, and settlements between them.
+
+USE `budgli`;
+
+ALTER TABLE `payment` ADD `paid_by_chat_id` bigint(20) DEFAULT NULL;
+
+CREATE TABLE `payment_split` (
+  `payment_id` varchar(36) NOT NULL,
+  `sheet_id` varchar(36) NOT NULL,
+  `chat_id` bigint(20) NOT NULL,
+  `amount` bigint(20) NOT NULL,
+  PRIMARY KEY (`payment_id`, `chat_id`),
+  KEY `payment_split_sheet_id_IDX` (`sheet_id`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `settlement` (
+  `settlement_id` varchar(36) NOT NULL,
+  `sheet_id` varchar(36) NOT NULL,
+  `from_chat_id` bigint(20) NOT NULL,
+  `to_chat_id` bigint(20) NOT NULL,
+  `amount` bigint(20) NOT NULL,
+  `settlement_time` datetime NOT NULL,
+  PRIMARY KEY (`settlement_id`),
+  KEY `settlement_sheet_id_IDX` (`sheet_id`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
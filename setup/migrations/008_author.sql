@@ -0,0 +1,12 @@
+-- Records the Telegram user who made each payment and created each category.
+-- Rows created before this migration have no author.
+
+USE `budgli`;
+
+ALTER TABLE `payment`
+  ADD `author_user_id` bigint(20) DEFAULT NULL,
+  ADD `author_name` varchar(100) DEFAULT NULL;
+
+ALTER TABLE `category`
+  ADD `author_user_id` bigint(20) DEFAULT NULL,
+  ADD `author_name` varchar(100) DEFAULT NULL;
@@ -0,0 +1,9 @@
+-- Introduces the language chosen for a chat, chats without one follow the language of the user's Telegram app.
+
+USE `budgli`;
+
+CREATE TABLE `chat_language` (
+  `chat_id` bigint(20) NOT NULL,
+  `language` varchar(10) NOT NULL,
+  PRIMARY KEY (`chat_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
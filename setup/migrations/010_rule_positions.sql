@@ -0,0 +1,13 @@
+-- Closes the gaps deleted rules used to leave in the positions, so that they match the numbers /listRules shows.
+-- The rules are numbered with user variables rather than ROW_NUMBER(), which needs MySQL 8.
+-- The assignments of a single-table UPDATE are evaluated left to right, so @sheet is the sheet of the previous rule
+-- while the position is computed.
+
+USE `budgli`;
+
+SET @sheet := NULL, @position := 0;
+
+UPDATE `categorization_rule`
+SET `position` = IF(@sheet = `sheet_id`, @position := @position + 1, @position := 1),
+  `sheet_id` = (@sheet := `sheet_id`)
+ORDER BY `sheet_id`, `position`;
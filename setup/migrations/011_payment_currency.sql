@@ -0,0 +1,9 @@
+-- Payments now keep the sheet currency they were made in. Older payments take the current sheet currency,
+-- the one they have been shown in so far.
+
+USE `budgli`;
+
+UPDATE `payment` p
+JOIN `sheet` s ON s.`sheet_id` = p.`sheet_id`
+SET p.`currency` = s.`currency`
+WHERE p.`currency` IS NULL;
@@ -0,0 +1,29 @@
+-- Shows the members by name, and splits the shared payments between users instead of chats:
+-- all the users of a group share its chat ID.
+-- In private chats the user ID is the chat ID, so their members become participants and their shares are kept.
+-- The shares recorded for a group before can't be told apart by user and stay with the group chat ID.
+
+USE `budgli`;
+
+ALTER TABLE `sheet_member` ADD `name` varchar(100) DEFAULT NULL;
+
+CREATE TABLE `sheet_participant` (
+  `sheet_id` varchar(36) NOT NULL,
+  `user_id` bigint(20) NOT NULL,
+  `chat_id` bigint(20) NOT NULL,
+  `name` varchar(100) NOT NULL,
+  PRIMARY KEY (`sheet_id`, `user_id`),
+  KEY `sheet_participant_chat_id_IDX` (`sheet_id`, `chat_id`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Private chats have positive IDs, groups negative ones
+INSERT INTO `sheet_participant` (`sheet_id`, `user_id`, `chat_id`, `name`)
+SELECT `sheet_id`, `chat_id`, `chat_id`, '' FROM `sheet_member` WHERE `chat_id` > 0;
+
+ALTER TABLE `payment` CHANGE `paid_by_chat_id` `paid_by_user_id` bigint(20) DEFAULT NULL;
+
+ALTER TABLE `payment_split` CHANGE `chat_id` `user_id` bigint(20) NOT NULL;
+
+ALTER TABLE `settlement`
+  CHANGE `from_chat_id` `from_user_id` bigint(20) NOT NULL,
+  CHANGE `to_chat_id` `to_user_id` bigint(20) NOT NULL;
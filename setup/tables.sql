@@ -11,18 +11,67 @@ CREATE TABLE `current_sheet` (
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
 
+-- budgli.chat_language definition
+
+CREATE TABLE `chat_language` (
+  `chat_id` bigint(20) NOT NULL,
+  `language` varchar(10) NOT NULL,
+  PRIMARY KEY (`chat_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+
 -- budgli.sheet definition
 
 CREATE TABLE `sheet` (
   `sheet_id` varchar(36) NOT NULL,
   `owner_chat_id` bigint(20) NOT NULL,
   `name` varchar(100) NOT NULL,
-  `password` varchar(50) NOT NULL,
+  `password` varchar(255) NOT NULL,
+  `currency` varchar(10) NOT NULL DEFAULT 'EUR',
+  `funding_account` varchar(100) NOT NULL DEFAULT 'Assets:Cash',
   PRIMARY KEY (`sheet_id`),
   KEY `sheet_owner_chat_id_IDX` (`owner_chat_id`) USING BTREE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
 
+-- budgli.sheet_member definition
+
+CREATE TABLE `sheet_member` (
+  `sheet_id` varchar(36) NOT NULL,
+  `chat_id` bigint(20) NOT NULL,
+  `role` varchar(10) NOT NULL,
+  `name` varchar(100) DEFAULT NULL,
+  PRIMARY KEY (`sheet_id`, `chat_id`),
+  KEY `sheet_member_chat_id_IDX` (`chat_id`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+
+-- budgli.sheet_participant definition
+
+CREATE TABLE `sheet_participant` (
+  `sheet_id` varchar(36) NOT NULL,
+  `user_id` bigint(20) NOT NULL,
+  `chat_id` bigint(20) NOT NULL,
+  `name` varchar(100) NOT NULL,
+  PRIMARY KEY (`sheet_id`, `user_id`),
+  KEY `sheet_participant_chat_id_IDX` (`sheet_id`, `chat_id`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+
+-- budgli.sheet_invite definition
+
+CREATE TABLE `sheet_invite` (
+  `token` varchar(64) NOT NULL,
+  `sheet_id` varchar(36) NOT NULL,
+  `created_by_chat_id` bigint(20) NOT NULL,
+  `role` varchar(10) NOT NULL,
+  `uses_left` int(11) NOT NULL,
+  `expires_at` datetime NOT NULL,
+  PRIMARY KEY (`token`),
+  KEY `sheet_invite_sheet_id_IDX` (`sheet_id`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+
 -- budgli.payment definition
 
 CREATE TABLE `payment` (
@@ -30,8 +79,12 @@ CREATE TABLE `payment` (
   `sheet_id` varchar(36) NOT NULL,
   `category_id` varchar(36) NOT NULL,
   `amount` bigint(20) NOT NULL,
+  `currency` varchar(10) DEFAULT NULL,
   `comment` varchar(100) DEFAULT NULL,
   `payment_made_time` datetime NOT NULL,
+  `paid_by_user_id` bigint(20) DEFAULT NULL,
+  `author_user_id` bigint(20) DEFAULT NULL,
+  `author_name` varchar(100) DEFAULT NULL,
   PRIMARY KEY (`payment_id`),
   KEY `payment_sheet_id_IDX` (`sheet_id`) USING BTREE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -43,6 +96,60 @@ CREATE TABLE `category` (
   `category_id` varchar(36) NOT NULL,
   `sheet_id` varchar(36) DEFAULT NULL,
   `name` varchar(100) DEFAULT NULL,
+  `author_user_id` bigint(20) DEFAULT NULL,
+  `author_name` varchar(100) DEFAULT NULL,
   PRIMARY KEY (`category_id`),
   KEY `category_sheet_id_IDX` (`sheet_id`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+
+-- budgli.import_profile definition
+
+CREATE TABLE `import_profile` (
+  `sheet_id` varchar(36) NOT NULL,
+  `date_column` varchar(100) NOT NULL,
+  `amount_column` varchar(100) NOT NULL,
+  `description_column` varchar(100) NOT NULL,
+  `date_layout` varchar(30) NOT NULL,
+  `expenses_negative` tinyint(1) NOT NULL,
+  PRIMARY KEY (`sheet_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+
+-- budgli.categorization_rule definition
+
+CREATE TABLE `categorization_rule` (
+  `rule_id` varchar(36) NOT NULL,
+  `sheet_id` varchar(36) NOT NULL,
+  `position` int(11) NOT NULL,
+  `conditions` varchar(255) NOT NULL,
+  `category_id` varchar(36) NOT NULL,
+  PRIMARY KEY (`rule_id`),
+  KEY `categorization_rule_sheet_id_IDX` (`sheet_id`, `position`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+
+-- budgli.payment_split definition
+
+CREATE TABLE `payment_split` (
+  `payment_id` varchar(36) NOT NULL,
+  `sheet_id` varchar(36) NOT NULL,
+  `user_id` bigint(20) NOT NULL,
+  `amount` bigint(20) NOT NULL,
+  PRIMARY KEY (`payment_id`, `user_id`),
+  KEY `payment_split_sheet_id_IDX` (`sheet_id`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+
+-- budgli.settlement definition
+
+CREATE TABLE `settlement` (
+  `settlement_id` varchar(36) NOT NULL,
+  `sheet_id` varchar(36) NOT NULL,
+  `from_user_id` bigint(20) NOT NULL,
+  `to_user_id` bigint(20) NOT NULL,
+  `amount` bigint(20) NOT NULL,
+  `settlement_time` datetime NOT NULL,
+  PRIMARY KEY (`settlement_id`),
+  KEY `settlement_sheet_id_IDX` (`sheet_id`) USING BTREE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
\ No newline at end of file
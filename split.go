@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Transfer is money one participant owes to another one to settle up
+type Transfer struct {
+	fromUserID int64
+	toUserID   int64
+	amount     int64
+}
+
+var (
+	errSplitFormat   = errors.New(`expected "<member>:<value>" pairs separated by spaces`)
+	errSplitMember   = errors.New("unknown member")
+	errSplitValue    = errors.New("invalid value")
+	errSplitNoShares = errors.New("at least one member should have a share")
+	errSplitSum      = errors.New("amounts don't add up to the payment amount")
+)
+
+// splitEqually divides the amount between the members, the hundredths which can't be divided go to the first members
+func splitEqually(amount int64, members []int64) map[int64]int64 {
+	shares := make(map[int64]int64, len(members))
+	for _, member := range members {
+		shares[member] = 1
+	}
+	split, _ := splitByShares(amount, shares)
+	return split
+}
+
+// splitByShares divides the amount proportionally to the shares. The hundredths which are left after rounding down
+// go to the members with the largest remainders, so that the parts always add up to the amount
+func splitByShares(amount int64, shares map[int64]int64) (map[int64]int64, error) {
+	var totalShares int64
+	members := make([]int64, 0, len(shares))
+	for member, share := range shares {
+		totalShares += share
+		members = append(members, member)
+	}
+	if totalShares <= 0 {
+		return nil, errSplitNoShares
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i] < members[j] })
+
+	sign := int64(1)
+	if amount < 0 {
+		sign, amount = -1, -amount
+	}
+
+	split := make(map[int64]int64, len(shares))
+	remainders := make(map[int64]int64, len(shares))
+	left := amount
+	for _, member := range members {
+		// The product may not fit into 64 bits, the quotient does since the share is at most the total
+		hi, lo := bits.Mul64(uint64(amount), uint64(shares[member]))
+		quotient, remainder := bits.Div64(hi, lo, uint64(totalShares))
+		split[member] = int64(quotient)
+		remainders[member] = int64(remainder)
+		left -= split[member]
+	}
+	sort.SliceStable(members, func(i, j int) bool { return remainders[members[i]] > remainders[members[j]] })
+	for i := 0; left > 0; i++ {
+		split[members[i%len(members)]]++
+		left--
+	}
+
+	for member := range split {
+		split[member] *= sign
+	}
+	return split, nil
+}
+
+// parseSplitValues parses "<member>:<value>" pairs. Members are user IDs, "me" stands for the current user
+func parseSplitValues(text string, currentUserID int64, members []int64, parseValue func(string) (int64, error)) (map[int64]int64, error) {
+	isMember := make(map[int64]bool, len(members))
+	for _, member := range members {
+		isMember[member] = true
+	}
+
+	values := make(map[int64]int64)
+	for _, pair := range strings.Fields(text) {
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			return nil, errSplitFormat
+		}
+
+		member := currentUserID
+		if normalizeText(parts[0]) != "me" {
+			var err error
+			if member, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+				return nil, errSplitFormat
+			}
+		}
+		if !isMember[member] {
+			return nil, errSplitMember
+		}
+
+		value, err := parseValue(parts[1])
+		if err == errSplitShare {
+			return nil, err
+		}
+		if err != nil || value < 0 {
+			return nil, errSplitValue
+		}
+		values[member] += value
+	}
+	if len(values) == 0 {
+		return nil, errSplitFormat
+	}
+	return values, nil
+}
+
+// Shares are small weights, anything above it is most likely an amount typed by mistake
+const maxShare = 1000
+
+var errSplitShare = fmt.Errorf("shares should be from 1 to %d", maxShare)
+
+func parseShare(value string) (int64, error) {
+	share, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if share < 1 || share > maxShare {
+		return 0, errSplitShare
+	}
+	return share, nil
+}
+
+// checkExactSplit makes sure the exact amounts cover the whole payment
+func checkExactSplit(amount int64, split map[int64]int64) error {
+	var total int64
+	for _, part := range split {
+		total += part
+	}
+	if total != amount {
+		return errSplitSum
+	}
+	return nil
+}
+
+// settleUp returns the transfers which bring all the balances to zero. Balances are positive for the members
+// who are owed money. The largest debtor always pays the largest creditor, which needs at most one transfer
+// less than there are members with a non-zero balance
+func settleUp(balances map[int64]int64) []Transfer {
+	type balance struct {
+		userID int64
+		amount int64
+	}
+	var creditors, debtors []*balance
+	for userID, amount := range balances {
+		if amount > 0 {
+			creditors = append(creditors, &balance{userID, amount})
+		} else if amount < 0 {
+			debtors = append(debtors, &balance{userID, -amount})
+		}
+	}
+	byAmount := func(b []*balance) func(i, j int) bool {
+		return func(i, j int) bool {
+			if b[i].amount != b[j].amount {
+				return b[i].amount > b[j].amount
+			}
+			return b[i].userID < b[j].userID
+		}
+	}
+
+	var transfers []Transfer
+	for len(creditors) > 0 && len(debtors) > 0 {
+		sort.Slice(creditors, byAmount(creditors))
+		sort.Slice(debtors, byAmount(debtors))
+		creditor, debtor := creditors[0], debtors[0]
+
+		amount := creditor.amount
+		if debtor.amount < amount {
+			amount = debtor.amount
+		}
+		transfers = append(transfers, Transfer{fromUserID: debtor.userID, toUserID: creditor.userID, amount: amount})
+
+		creditor.amount -= amount
+		debtor.amount -= amount
+		if creditor.amount == 0 {
+			creditors = creditors[1:]
+		}
+		if debtor.amount == 0 {
+			debtors = debtors[1:]
+		}
+	}
+	return transfers
+}
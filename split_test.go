@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitByShares(t *testing.T) {
+	tests := []struct {
+		amount   int64
+		shares   map[int64]int64
+		expected map[int64]int64
+	}{
+		{100, map[int64]int64{1: 1, 2: 1, 3: 1}, map[int64]int64{1: 34, 2: 33, 3: 33}},
+		{-100, map[int64]int64{1: 1, 2: 1, 3: 1}, map[int64]int64{1: -34, 2: -33, 3: -33}},
+		{1000, map[int64]int64{1: 2, 2: 1}, map[int64]int64{1: 667, 2: 333}},
+		// The hundredth left goes to the larger remainder, not to the first member
+		{100000, map[int64]int64{1: maxShare, 2: 1}, map[int64]int64{1: 99900, 2: 100}},
+		// amount * share doesn't fit into int64
+		{9000000000000000000, map[int64]int64{1: maxShare, 2: maxShare}, map[int64]int64{1: 4500000000000000000, 2: 4500000000000000000}},
+		{9000000000000000001, map[int64]int64{1: 3, 2: 1}, map[int64]int64{1: 6750000000000000001, 2: 2250000000000000000}},
+	}
+	for _, test := range tests {
+		split, err := splitByShares(test.amount, test.shares)
+		if err != nil || !reflect.DeepEqual(split, test.expected) {
+			t.Errorf("splitByShares(%d, %v) = %v, %v, expected %v", test.amount, test.shares, split, err, test.expected)
+		}
+	}
+
+	if _, err := splitByShares(100, map[int64]int64{1: 0}); err != errSplitNoShares {
+		t.Errorf("got %v for zero shares, expected %v", err, errSplitNoShares)
+	}
+}
+
+func TestParseShares(t *testing.T) {
+	members := []int64{1, 2}
+	tests := []struct {
+		text     string
+		expected map[int64]int64
+		err      error
+	}{
+		{"me:2 2:1", map[int64]int64{1: 2, 2: 1}, nil},
+		{"me:1000 2:1", map[int64]int64{1: 1000, 2: 1}, nil},
+		{"me:1001 2:1", nil, errSplitShare},
+		{"me:9000000000000000000 2:1", nil, errSplitShare},
+		{"me:0 2:1", nil, errSplitShare},
+		{"me:-1 2:1", nil, errSplitShare},
+		{"me:x 2:1", nil, errSplitValue},
+		{"3:1", nil, errSplitMember},
+	}
+	for _, test := range tests {
+		shares, err := parseSplitValues(test.text, 1, members, parseShare)
+		if err != test.err || !reflect.DeepEqual(shares, test.expected) {
+			t.Errorf("parseSplitValues(%q) = %v, %v, expected %v, %v", test.text, shares, err, test.expected, test.err)
+		}
+	}
+}
+
+func TestSettleUp(t *testing.T) {
+	tests := []struct {
+		balances map[int64]int64
+		expected []Transfer
+	}{
+		{nil, nil},
+		{map[int64]int64{1: 0, 2: 0}, nil},
+		{map[int64]int64{1: 50, 2: -30, 3: -20}, []Transfer{{2, 1, 30}, {3, 1, 20}}},
+		// Uneven amounts, the last debtor pays two creditors
+		{map[int64]int64{1: 100, 2: 1, 3: -34, 4: -67}, []Transfer{{4, 1, 67}, {3, 1, 33}, {3, 2, 1}}},
+	}
+	for _, test := range tests {
+		transfers := settleUp(test.balances)
+		if !reflect.DeepEqual(transfers, test.expected) {
+			t.Errorf("settleUp(%v) = %v, expected %v", test.balances, transfers, test.expected)
+		}
+
+		// Everyone ends up even
+		left := make(map[int64]int64)
+		for userID, amount := range test.balances {
+			left[userID] = amount
+		}
+		for _, transfer := range transfers {
+			left[transfer.fromUserID] += transfer.amount
+			left[transfer.toUserID] -= transfer.amount
+		}
+		for userID, amount := range left {
+			if amount != 0 {
+				t.Errorf("settleUp(%v) leaves %d with %d", test.balances, userID, amount)
+			}
+		}
+	}
+}
@@ -1,39 +1,218 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"time"
+
+	"github.com/google/uuid"
 )
 
-type Storage struct {
+// Storage keeps the sheets and everything in them. Its methods take the context of the update they are called for,
+// which limits how long they may take
+type Storage interface {
+	// WithTx runs fn with a storage whose methods all run in one transaction, which is committed if fn returns nil
+	// and rolled back otherwise. Called on the storage of a transaction, fn simply becomes a part of it
+	WithTx(ctx context.Context, fn func(tx Storage) error) error
+
+	InsertNewPayment(ctx context.Context, sheetID *string, categoryID string, id string, amount int64, comment string, time time.Time, author Author) error
+	ListCategoryIDs(ctx context.Context, sheetID string) (map[string]string, error)
+	FindCategory(ctx context.Context, sheetID *string, categoryName string) (string, error)
+	InsertNewCategory(ctx context.Context, sheetID string, id string, name string, author Author) error
+	ListCategories(ctx context.Context, sheetID string) ([]string, error)
+	CheckPassword(ctx context.Context, sheetID string, password string) (bool, error)
+	UpdatePassword(ctx context.Context, sheetID string, password string) error
+	InsertNewSheet(ctx context.Context, chatID int64, id string, name string, password string) error
+	ConnectToSheet(ctx context.Context, chatID int64, sheetID string) error
+	FetchCurrentSheetFromDB(ctx context.Context, chatID int64) (*string, error)
+	DisconnectFromSheet(ctx context.Context, chatID int64) error
+	ListSheets(ctx context.Context, chatID int64) ([]Sheet, error)
+	GetSheetName(ctx context.Context, sheetID string) (string, error)
+	GetSheetSettings(ctx context.Context, sheetID string) (*SheetSettings, error)
+	UpdateSheetCurrency(ctx context.Context, sheetID string, currency string) error
+	UpdateSheetFundingAccount(ctx context.Context, sheetID string, fundingAccount string) error
+	RenameSheet(ctx context.Context, sheetID string, name string) error
+	TransferOwnership(ctx context.Context, sheetID string, ownerChatID int64, newOwnerChatID int64) error
+	DeleteSheet(ctx context.Context, sheetID string) error
+	GetSheetOwnerChatID(ctx context.Context, sheetID string) (int64, error)
+	AddSheetMember(ctx context.Context, sheetID string, chatID int64, role Role) error
+	GetSheetMemberRole(ctx context.Context, sheetID string, chatID int64) (Role, error)
+	ListSheetMembers(ctx context.Context, sheetID string) ([]SheetMember, error)
+	UpdateSheetMemberRole(ctx context.Context, sheetID string, chatID int64, role Role) error
+	RemoveSheetMember(ctx context.Context, sheetID string, chatID int64) error
+	RecordSheetParticipant(ctx context.Context, sheetID string, chatID int64, chatName string, user Author) error
+	ListSheetParticipants(ctx context.Context, sheetID string) ([]SheetParticipant, error)
+	InsertNewInvite(ctx context.Context, sheetID string, chatID int64, token string, role Role, uses int, expiresAt time.Time) error
+	ListInvites(ctx context.Context, sheetID string, now time.Time) ([]SheetInvite, error)
+	RedeemInvite(ctx context.Context, token string, now time.Time) (string, Role, error)
+	PeekInvite(ctx context.Context, token string, now time.Time) (string, error)
+	RevokeInvite(ctx context.Context, sheetID string, token string) (bool, error)
+	GetPayment(ctx context.Context, sheetID string, paymentID string) (*Payment, error)
+	DeletePayment(ctx context.Context, sheetID string, paymentID string) error
+	UpdatePaymentCategory(ctx context.Context, sheetID string, paymentID string, categoryID string) error
+	UpdatePaymentAmount(ctx context.Context, sheetID string, paymentID string, amount int64) error
+	ListPayments(ctx context.Context, sheetID string, from time.Time, to time.Time) ([]Payment, error)
+	InsertPayments(ctx context.Context, sheetID string, payments []Payment) error
+	GetImportProfile(ctx context.Context, sheetID string) (*ImportProfile, error)
+	SaveImportProfile(ctx context.Context, sheetID string, profile *ImportProfile) error
+	ListRules(ctx context.Context, sheetID string) ([]*CategorizationRule, error)
+	InsertNewRule(ctx context.Context, sheetID string, id string, conditions string, categoryID string) error
+	MoveRule(ctx context.Context, sheetID string, from int, to int) error
+	DeleteRule(ctx context.Context, sheetID string, position int) (bool, error)
+	InsertSharedPayment(ctx context.Context, sheetID string, payment Payment, paidByUserID int64, split map[int64]int64) error
+	GetBalances(ctx context.Context, sheetID string) (map[int64]int64, error)
+	InsertSettlements(ctx context.Context, sheetID string, transfers []Transfer, madeTime time.Time) error
+	GetChatLanguage(ctx context.Context, chatID int64) (string, error)
+	SetChatLanguage(ctx context.Context, chatID int64, language string) error
+}
+
+// mysqlStorage is the Storage the bot runs with
+type mysqlStorage struct {
 	db *sql.DB
+	// The database itself, or the transaction in the storage withTx passes on
+	conn dbConn
+}
+
+// dbConn is what the queries need, both *sql.DB and *sql.Tx have it
+type dbConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func newMySQLStorage(db *sql.DB) *mysqlStorage {
+	return &mysqlStorage{db: db, conn: db}
+}
+
+func (s *mysqlStorage) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	return s.withTx(ctx, func(tx *mysqlStorage) error {
+		return fn(tx)
+	})
+}
+
+func (s *mysqlStorage) inTx() bool {
+	_, inTx := s.conn.(*sql.Tx)
+	return inTx
+}
+
+// withTx is WithTx for the methods which need the connection of the transaction.
+// A transaction which fails with a transient error is run again from the start
+func (s *mysqlStorage) withTx(ctx context.Context, fn func(tx *mysqlStorage) error) error {
+	if s.inTx() {
+		return fn(s)
+	}
+
+	return retryTransient(ctx, func() error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return classifyMySQLError(err)
+		}
+		defer tx.Rollback()
+
+		if err := fn(&mysqlStorage{db: s.db, conn: tx}); err != nil {
+			return err
+		}
+		return classifyMySQLError(tx.Commit())
+	})
+}
+
+// retry runs the query again on retryable transient failures. In a transaction the query is not retried on its own,
+// since the transaction is usually rolled back by then, withTx runs the whole transaction again instead
+func (s *mysqlStorage) retry(ctx context.Context, query func() error) error {
+	if s.inTx() {
+		return classifyMySQLError(query())
+	}
+	return retryTransient(ctx, func() error {
+		return classifyMySQLError(query())
+	})
+}
+
+func (s *mysqlStorage) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var res sql.Result
+	err := s.retry(ctx, func() (err error) {
+		res, err = s.conn.ExecContext(ctx, query, args...)
+		return err
+	})
+	return res, err
+}
+
+func (s *mysqlStorage) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := s.retry(ctx, func() (err error) {
+		rows, err = s.conn.QueryContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+// queryRow is QueryRowContext with the query run by Scan, which makes it possible to retry it
+func (s *mysqlStorage) queryRow(ctx context.Context, query string, args ...interface{}) *storageRow {
+	return &storageRow{s: s, ctx: ctx, query: query, args: args}
+}
+
+type storageRow struct {
+	s     *mysqlStorage
+	ctx   context.Context
+	query string
+	args  []interface{}
+}
+
+// Scan returns an errNotFound error instead of sql.ErrNoRows
+func (r *storageRow) Scan(dest ...interface{}) error {
+	return r.s.retry(r.ctx, func() error {
+		return r.s.conn.QueryRowContext(r.ctx, r.query, r.args...).Scan(dest...)
+	})
 }
 
-func (s *Storage) InsertNewPayment(sheetID *string, categoryID string, id string, amount int64, comment string, time time.Time) error {
-	_, err := s.db.Exec("INSERT INTO `payment` (`payment_id`, `sheet_id`, `category_id`, `amount`, `comment`, `payment_made_time`) VALUES (?, ?, ?, ?, ?, ?)",
-		id, sheetID, categoryID, amount, comment, time)
+// paymentCurrency is the currency of the sheet, which the payments keep when the sheet currency is changed later
+const paymentCurrency = "(SELECT `currency` FROM `sheet` WHERE `sheet_id` = ?)"
+
+func (s *mysqlStorage) InsertNewPayment(ctx context.Context, sheetID *string, categoryID string, id string, amount int64, comment string, time time.Time, author Author) error {
+	_, err := s.exec(ctx, "INSERT INTO `payment` (`payment_id`, `sheet_id`, `category_id`, `amount`, `currency`, `comment`, `payment_made_time`, `author_user_id`, `author_name`) VALUES (?, ?, ?, ?, "+paymentCurrency+", ?, ?, ?, ?)",
+		id, sheetID, categoryID, amount, sheetID, comment, time, author.userID, author.name)
 	return err
 }
 
-func (s *Storage) FindCategory(sheetID *string, categoryName string) (string, error) {
+// ListCategoryIDs returns the IDs of the sheet categories by their names
+func (s *mysqlStorage) ListCategoryIDs(ctx context.Context, sheetID string) (map[string]string, error) {
+	rows, err := s.query(ctx, "SELECT `category_id`, `name` FROM `category` WHERE `sheet_id` = ?", sheetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categoryIDs := make(map[string]string)
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		categoryIDs[name] = id
+	}
+	return categoryIDs, rows.Err()
+}
+
+func (s *mysqlStorage) FindCategory(ctx context.Context, sheetID *string, categoryName string) (string, error) {
 	var categoryID string
 
-	err := s.db.QueryRow("SELECT `category_id` FROM `category` WHERE `sheet_id` = ? AND `name` = ?", sheetID, categoryName).
+	err := s.queryRow(ctx, "SELECT `category_id` FROM `category` WHERE `sheet_id` = ? AND `name` = ?", sheetID, categoryName).
 		Scan(&categoryID)
-	if err == sql.ErrNoRows {
+	if errors.Is(err, errNotFound) {
 		err = nil
 	}
 
 	return categoryID, err
 }
 
-func (s *Storage) InsertNewCategory(sheetID string, id string, name string) error {
-	_, err := s.db.Exec("INSERT INTO `category` (`category_id`, `sheet_id`, `name`) VALUES (?, ?, ?)", id, sheetID, name)
+func (s *mysqlStorage) InsertNewCategory(ctx context.Context, sheetID string, id string, name string, author Author) error {
+	_, err := s.exec(ctx, "INSERT INTO `category` (`category_id`, `sheet_id`, `name`, `author_user_id`, `author_name`) VALUES (?, ?, ?, ?, ?)",
+		id, sheetID, name, author.userID, author.name)
 	return err
 }
 
-func (s *Storage) ListCategories(sheetID string) ([]string, error) {
-	rows, err := s.db.Query("SELECT `name` FROM `category` WHERE `sheet_id` = ?", sheetID)
+func (s *mysqlStorage) ListCategories(ctx context.Context, sheetID string) ([]string, error) {
+	rows, err := s.query(ctx, "SELECT `name` FROM `category` WHERE `sheet_id` = ?", sheetID)
 	if err != nil {
 		return nil, err
 	}
@@ -50,32 +229,66 @@ func (s *Storage) ListCategories(sheetID string) ([]string, error) {
 	return categories, nil
 }
 
-func (s *Storage) CheckPassword(sheetID string, password string) bool {
-	var unused int
+// CheckPassword returns false if the sheet doesn't exist or the password doesn't match.
+// Hashes left from the MySQL PASSWORD() function are replaced with bcrypt ones on the first successful check
+func (s *mysqlStorage) CheckPassword(ctx context.Context, sheetID string, password string) (bool, error) {
+	var hash string
+
+	err := s.queryRow(ctx, "SELECT `password` FROM `sheet` WHERE `sheet_id` = ?", sheetID).Scan(&hash)
+	if errors.Is(err, errNotFound) {
+		checkPasswordHash(string(dummyPasswordHash), password)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if !checkPasswordHash(hash, password) {
+		return false, nil
+	}
+
+	if isLegacyPasswordHash(hash) {
+		if err := s.UpdatePassword(ctx, sheetID, password); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
 
-	err := s.db.QueryRow("SELECT 1 FROM `sheet` WHERE `sheet_id` = ? AND `password` = PASSWORD(?)", sheetID, password).Scan(&unused)
+func (s *mysqlStorage) UpdatePassword(ctx context.Context, sheetID string, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
 
-	return err == nil
+	_, err = s.exec(ctx, "UPDATE `sheet` SET `password` = ? WHERE `sheet_id` = ?", hash, sheetID)
+	return err
 }
 
-func (s *Storage) InsertNewSheet(chatID int64, id string, name string, password string) error {
-	_, err := s.db.Exec("INSERT INTO `sheet` (`sheet_id`, `owner_chat_id`, `name`, `password`) VALUES (?, ?, ?, PASSWORD(?))",
-		id, chatID, name, password)
+func (s *mysqlStorage) InsertNewSheet(ctx context.Context, chatID int64, id string, name string, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(ctx, "INSERT INTO `sheet` (`sheet_id`, `owner_chat_id`, `name`, `password`) VALUES (?, ?, ?, ?)",
+		id, chatID, name, hash)
 	return err
 }
 
-func (s *Storage) ConnectToSheet(chatID int64, sheetID string) error {
-	_, err := s.db.Exec("INSERT INTO `current_sheet` (`chat_id`, `sheet_id`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `sheet_id` = ?", chatID, sheetID, sheetID)
+func (s *mysqlStorage) ConnectToSheet(ctx context.Context, chatID int64, sheetID string) error {
+	_, err := s.exec(ctx, "INSERT INTO `current_sheet` (`chat_id`, `sheet_id`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `sheet_id` = ?", chatID, sheetID, sheetID)
 	return err
 }
 
-func (s *Storage) FetchCurrentSheetFromDB(chatID int64) (*string, error) {
+func (s *mysqlStorage) FetchCurrentSheetFromDB(ctx context.Context, chatID int64) (*string, error) {
 	var currentSheet string
 
-	err := s.db.QueryRow("SELECT `sheet_id` FROM `current_sheet` WHERE `chat_id` = ?", chatID).Scan(&currentSheet)
+	err := s.queryRow(ctx, "SELECT `sheet_id` FROM `current_sheet` WHERE `chat_id` = ?", chatID).Scan(&currentSheet)
 
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, errNotFound) {
 			return nil, nil
 		}
 
@@ -85,8 +298,8 @@ func (s *Storage) FetchCurrentSheetFromDB(chatID int64) (*string, error) {
 	}
 }
 
-func (s *Storage) DisconnectFromSheet(chatID int64) error {
-	_, err := s.db.Exec("DELETE FROM `current_sheet` WHERE `chat_id` = ?", chatID)
+func (s *mysqlStorage) DisconnectFromSheet(ctx context.Context, chatID int64) error {
+	_, err := s.exec(ctx, "DELETE FROM `current_sheet` WHERE `chat_id` = ?", chatID)
 
 	return err
 }
@@ -94,10 +307,12 @@ func (s *Storage) DisconnectFromSheet(chatID int64) error {
 type Sheet struct {
 	id   string
 	name string
+	role Role
 }
 
-func (s *Storage) ListSheets(chatID int64) ([]Sheet, error) {
-	rows, err := s.db.Query("SELECT `sheet_id`, `name` FROM `sheet` WHERE `owner_chat_id` = ?", chatID)
+// ListSheets returns all the sheets the chat is a member of, together with its role in each of them
+func (s *mysqlStorage) ListSheets(ctx context.Context, chatID int64) ([]Sheet, error) {
+	rows, err := s.query(ctx, "SELECT s.`sheet_id`, s.`name`, m.`role` FROM `sheet` s JOIN `sheet_member` m ON m.`sheet_id` = s.`sheet_id` WHERE m.`chat_id` = ?", chatID)
 	if err != nil {
 		return nil, err
 	}
@@ -106,20 +321,622 @@ func (s *Storage) ListSheets(chatID int64) ([]Sheet, error) {
 	var sheets []Sheet
 	for rows.Next() {
 		var sheet Sheet
-		if err := rows.Scan(&sheet.id, &sheet.name); err != nil {
+		var role string
+		if err := rows.Scan(&sheet.id, &sheet.name, &role); err != nil {
 			return nil, err
 		}
+		sheet.role = parseRole(role)
 		sheets = append(sheets, sheet)
 	}
 	return sheets, nil
 }
 
-func (s *Storage) GetSheetOwnerChatID(sheetID string) (int64, error) {
+func (s *mysqlStorage) GetSheetName(ctx context.Context, sheetID string) (string, error) {
+	var name string
+
+	if err := s.queryRow(ctx, "SELECT `name` FROM `sheet` WHERE `sheet_id` = ?", sheetID).Scan(&name); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+type SheetSettings struct {
+	currency       string
+	fundingAccount string
+}
+
+func (s *mysqlStorage) GetSheetSettings(ctx context.Context, sheetID string) (*SheetSettings, error) {
+	var settings SheetSettings
+
+	err := s.queryRow(ctx, "SELECT `currency`, `funding_account` FROM `sheet` WHERE `sheet_id` = ?", sheetID).
+		Scan(&settings.currency, &settings.fundingAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+func (s *mysqlStorage) UpdateSheetCurrency(ctx context.Context, sheetID string, currency string) error {
+	_, err := s.exec(ctx, "UPDATE `sheet` SET `currency` = ? WHERE `sheet_id` = ?", currency, sheetID)
+	return err
+}
+
+func (s *mysqlStorage) UpdateSheetFundingAccount(ctx context.Context, sheetID string, fundingAccount string) error {
+	_, err := s.exec(ctx, "UPDATE `sheet` SET `funding_account` = ? WHERE `sheet_id` = ?", fundingAccount, sheetID)
+	return err
+}
+
+func (s *mysqlStorage) RenameSheet(ctx context.Context, sheetID string, name string) error {
+	_, err := s.exec(ctx, "UPDATE `sheet` SET `name` = ? WHERE `sheet_id` = ?", name, sheetID)
+	return err
+}
+
+// TransferOwnership makes the new owner the only owner of the sheet, the previous owner becomes an editor
+func (s *mysqlStorage) TransferOwnership(ctx context.Context, sheetID string, ownerChatID int64, newOwnerChatID int64) error {
+	return s.withTx(ctx, func(tx *mysqlStorage) error {
+		if _, err := tx.exec(ctx, "UPDATE `sheet` SET `owner_chat_id` = ? WHERE `sheet_id` = ?", newOwnerChatID, sheetID); err != nil {
+			return err
+		}
+		if _, err := tx.exec(ctx, "UPDATE `sheet_member` SET `role` = ? WHERE `sheet_id` = ? AND `chat_id` = ?", RoleOwner.String(), sheetID, newOwnerChatID); err != nil {
+			return err
+		}
+		if _, err := tx.exec(ctx, "UPDATE `sheet_member` SET `role` = ? WHERE `sheet_id` = ? AND `chat_id` = ?", RoleEditor.String(), sheetID, ownerChatID); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// DeleteSheet deletes the sheet together with everything that belongs to it
+func (s *mysqlStorage) DeleteSheet(ctx context.Context, sheetID string) error {
+	return s.withTx(ctx, func(tx *mysqlStorage) error {
+		for _, table := range []string{"settlement", "payment_split", "payment", "categorization_rule", "category", "import_profile", "current_sheet", "sheet_invite", "sheet_participant", "sheet_member", "sheet"} {
+			if _, err := tx.exec(ctx, "DELETE FROM `"+table+"` WHERE `sheet_id` = ?", sheetID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *mysqlStorage) GetSheetOwnerChatID(ctx context.Context, sheetID string) (int64, error) {
 	var ownerChatID int64
 
-	if err := s.db.QueryRow("SELECT `owner_chat_id` FROM `sheet` WHERE `sheet_id` = ?", sheetID).Scan(&ownerChatID); err != nil {
+	if err := s.queryRow(ctx, "SELECT `owner_chat_id` FROM `sheet` WHERE `sheet_id` = ?", sheetID).Scan(&ownerChatID); err != nil {
 		return 0, err
 	}
 
 	return ownerChatID, nil
 }
+
+// Role defines what a sheet member is allowed to do. Roles are ordered, so a higher role includes all the permissions of the lower ones.
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleViewer
+	RoleEditor
+	RoleOwner
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleEditor:
+		return "editor"
+	case RoleOwner:
+		return "owner"
+	}
+	return ""
+}
+
+func parseRole(role string) Role {
+	switch normalizeText(role) {
+	case "viewer":
+		return RoleViewer
+	case "editor":
+		return RoleEditor
+	case "owner":
+		return RoleOwner
+	}
+	return RoleNone
+}
+
+type SheetMember struct {
+	chatID int64
+	role   Role
+	// Title of the group or name of the user of the private chat, empty until the chat writes to the sheet
+	name string
+}
+
+// SheetParticipant is a user the shared payments of the sheet are split between. In a private chat the member
+// chat is the user, in a group every user of the group who writes to the sheet is a participant of their own
+type SheetParticipant struct {
+	userID int64
+	// The member chat the user last wrote from
+	chatID int64
+	name   string
+}
+
+// AddSheetMember adds the chat to the sheet members. If the chat is already a member, its role is left untouched
+func (s *mysqlStorage) AddSheetMember(ctx context.Context, sheetID string, chatID int64, role Role) error {
+	_, err := s.exec(ctx, "INSERT IGNORE INTO `sheet_member` (`sheet_id`, `chat_id`, `role`) VALUES (?, ?, ?)", sheetID, chatID, role.String())
+	return err
+}
+
+// GetSheetMemberRole returns RoleNone if the chat is not a member of the sheet
+func (s *mysqlStorage) GetSheetMemberRole(ctx context.Context, sheetID string, chatID int64) (Role, error) {
+	var role string
+
+	err := s.queryRow(ctx, "SELECT `role` FROM `sheet_member` WHERE `sheet_id` = ? AND `chat_id` = ?", sheetID, chatID).Scan(&role)
+	if errors.Is(err, errNotFound) {
+		return RoleNone, nil
+	}
+	if err != nil {
+		return RoleNone, err
+	}
+
+	return parseRole(role), nil
+}
+
+func (s *mysqlStorage) ListSheetMembers(ctx context.Context, sheetID string) ([]SheetMember, error) {
+	rows, err := s.query(ctx, "SELECT `chat_id`, `role`, COALESCE(`name`, '') FROM `sheet_member` WHERE `sheet_id` = ? ORDER BY `chat_id`", sheetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []SheetMember
+	for rows.Next() {
+		var member SheetMember
+		var role string
+		if err := rows.Scan(&member.chatID, &role, &member.name); err != nil {
+			return nil, err
+		}
+		member.role = parseRole(role)
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func (s *mysqlStorage) UpdateSheetMemberRole(ctx context.Context, sheetID string, chatID int64, role Role) error {
+	_, err := s.exec(ctx, "UPDATE `sheet_member` SET `role` = ? WHERE `sheet_id` = ? AND `chat_id` = ?", role.String(), sheetID, chatID)
+	return err
+}
+
+// RemoveSheetMember also disconnects the chat from the sheet if it is currently connected to it
+func (s *mysqlStorage) RemoveSheetMember(ctx context.Context, sheetID string, chatID int64) error {
+	return s.withTx(ctx, func(tx *mysqlStorage) error {
+		if _, err := tx.exec(ctx, "DELETE FROM `sheet_member` WHERE `sheet_id` = ? AND `chat_id` = ?", sheetID, chatID); err != nil {
+			return err
+		}
+		if _, err := tx.exec(ctx, "DELETE FROM `sheet_participant` WHERE `sheet_id` = ? AND `chat_id` = ?", sheetID, chatID); err != nil {
+			return err
+		}
+
+		_, err := tx.exec(ctx, "DELETE FROM `current_sheet` WHERE `chat_id` = ? AND `sheet_id` = ?", chatID, sheetID)
+		return err
+	})
+}
+
+// RecordSheetParticipant keeps the name of the member chat up to date and records the user as a participant
+// of the sheet. Nothing is recorded if the chat is not a member of the sheet
+func (s *mysqlStorage) RecordSheetParticipant(ctx context.Context, sheetID string, chatID int64, chatName string, user Author) error {
+	return s.withTx(ctx, func(tx *mysqlStorage) error {
+		role, err := tx.GetSheetMemberRole(ctx, sheetID, chatID)
+		if err != nil || role == RoleNone {
+			return err
+		}
+		if _, err := tx.exec(ctx, "UPDATE `sheet_member` SET `name` = ? WHERE `sheet_id` = ? AND `chat_id` = ?", chatName, sheetID, chatID); err != nil {
+			return err
+		}
+
+		_, err = tx.exec(ctx, "INSERT INTO `sheet_participant` (`sheet_id`, `user_id`, `chat_id`, `name`) VALUES (?, ?, ?, ?) "+
+			"ON DUPLICATE KEY UPDATE `chat_id` = VALUES(`chat_id`), `name` = VALUES(`name`)", sheetID, user.userID, chatID, user.name)
+		return err
+	})
+}
+
+func (s *mysqlStorage) ListSheetParticipants(ctx context.Context, sheetID string) ([]SheetParticipant, error) {
+	rows, err := s.query(ctx, "SELECT `user_id`, `chat_id`, `name` FROM `sheet_participant` WHERE `sheet_id` = ? ORDER BY `user_id`", sheetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var participants []SheetParticipant
+	for rows.Next() {
+		var participant SheetParticipant
+		if err := rows.Scan(&participant.userID, &participant.chatID, &participant.name); err != nil {
+			return nil, err
+		}
+		participants = append(participants, participant)
+	}
+	return participants, rows.Err()
+}
+
+type SheetInvite struct {
+	token     string
+	role      Role
+	usesLeft  int
+	expiresAt time.Time
+}
+
+func (s *mysqlStorage) InsertNewInvite(ctx context.Context, sheetID string, chatID int64, token string, role Role, uses int, expiresAt time.Time) error {
+	_, err := s.exec(ctx, "INSERT INTO `sheet_invite` (`token`, `sheet_id`, `created_by_chat_id`, `role`, `uses_left`, `expires_at`) VALUES (?, ?, ?, ?, ?, ?)",
+		token, sheetID, chatID, role.String(), uses, expiresAt)
+	return err
+}
+
+// ListInvites returns the invites of the sheet that can still be redeemed
+func (s *mysqlStorage) ListInvites(ctx context.Context, sheetID string, now time.Time) ([]SheetInvite, error) {
+	rows, err := s.query(ctx, "SELECT `token`, `role`, `uses_left`, `expires_at` FROM `sheet_invite` WHERE `sheet_id` = ? AND `uses_left` > 0 AND `expires_at` > ? ORDER BY `expires_at`",
+		sheetID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invites []SheetInvite
+	for rows.Next() {
+		var invite SheetInvite
+		var role string
+		if err := rows.Scan(&invite.token, &role, &invite.usesLeft, &invite.expiresAt); err != nil {
+			return nil, err
+		}
+		invite.role = parseRole(role)
+		invites = append(invites, invite)
+	}
+	return invites, nil
+}
+
+// RedeemInvite uses up one use of the invite and returns the sheet and the role it grants.
+// An empty sheet ID is returned if the invite doesn't exist, has expired or has no uses left
+func (s *mysqlStorage) RedeemInvite(ctx context.Context, token string, now time.Time) (string, Role, error) {
+	var sheetID, role string
+	err := s.withTx(ctx, func(tx *mysqlStorage) error {
+		sheetID, role = "", ""
+		err := tx.queryRow(ctx, "SELECT `sheet_id`, `role` FROM `sheet_invite` WHERE `token` = ? AND `uses_left` > 0 AND `expires_at` > ? FOR UPDATE", token, now).
+			Scan(&sheetID, &role)
+		if errors.Is(err, errNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.exec(ctx, "UPDATE `sheet_invite` SET `uses_left` = `uses_left` - 1 WHERE `token` = ?", token)
+		return err
+	})
+	if err != nil || sheetID == "" {
+		return "", RoleNone, err
+	}
+
+	return sheetID, parseRole(role), nil
+}
+
+// PeekInvite returns the sheet the invite leads to without using it up, or an empty string if it can't be redeemed
+func (s *mysqlStorage) PeekInvite(ctx context.Context, token string, now time.Time) (string, error) {
+	var sheetID string
+
+	err := s.queryRow(ctx, "SELECT `sheet_id` FROM `sheet_invite` WHERE `token` = ? AND `uses_left` > 0 AND `expires_at` > ?", token, now).Scan(&sheetID)
+	if errors.Is(err, errNotFound) {
+		err = nil
+	}
+
+	return sheetID, err
+}
+
+// RevokeInvite returns false if the sheet has no such invite
+func (s *mysqlStorage) RevokeInvite(ctx context.Context, sheetID string, token string) (bool, error) {
+	res, err := s.exec(ctx, "DELETE FROM `sheet_invite` WHERE `sheet_id` = ? AND `token` = ?", sheetID, token)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+type Payment struct {
+	id           string
+	categoryID   string
+	categoryName string
+	amount       int64
+	// The sheet currency when the payment was made. Payments made before it was recorded take the current one
+	currency string
+	comment  string
+	madeTime time.Time
+	// Payments made before authors were recorded have a zero author
+	author Author
+	// Zero unless the payment is shared between the sheet participants
+	paidByUserID int64
+}
+
+// GetPayment returns nil if the sheet has no such payment
+func (s *mysqlStorage) GetPayment(ctx context.Context, sheetID string, paymentID string) (*Payment, error) {
+	var payment Payment
+	var categoryName, comment sql.NullString
+	var paidByUserID sql.NullInt64
+	err := s.queryRow(ctx, "SELECT p.`payment_id`, p.`category_id`, c.`name`, p.`amount`, p.`comment`, p.`payment_made_time`, p.`paid_by_user_id` FROM `payment` p "+
+		"LEFT JOIN `category` c ON c.`category_id` = p.`category_id` "+
+		"WHERE p.`sheet_id` = ? AND p.`payment_id` = ?", sheetID, paymentID).
+		Scan(&payment.id, &payment.categoryID, &categoryName, &payment.amount, &comment, &payment.madeTime, &paidByUserID)
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	payment.categoryName = categoryName.String
+	payment.comment = comment.String
+	payment.paidByUserID = paidByUserID.Int64
+	return &payment, nil
+}
+
+// DeletePayment deletes the payment together with its split, if it is shared
+func (s *mysqlStorage) DeletePayment(ctx context.Context, sheetID string, paymentID string) error {
+	return s.withTx(ctx, func(tx *mysqlStorage) error {
+		if _, err := tx.exec(ctx, "DELETE FROM `payment_split` WHERE `sheet_id` = ? AND `payment_id` = ?", sheetID, paymentID); err != nil {
+			return err
+		}
+		if _, err := tx.exec(ctx, "DELETE FROM `payment` WHERE `sheet_id` = ? AND `payment_id` = ?", sheetID, paymentID); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *mysqlStorage) UpdatePaymentCategory(ctx context.Context, sheetID string, paymentID string, categoryID string) error {
+	_, err := s.exec(ctx, "UPDATE `payment` SET `category_id` = ? WHERE `sheet_id` = ? AND `payment_id` = ?", categoryID, sheetID, paymentID)
+	return err
+}
+
+func (s *mysqlStorage) UpdatePaymentAmount(ctx context.Context, sheetID string, paymentID string, amount int64) error {
+	_, err := s.exec(ctx, "UPDATE `payment` SET `amount` = ? WHERE `sheet_id` = ? AND `payment_id` = ?", amount, sheetID, paymentID)
+	return err
+}
+
+// ListPayments returns the payments of the sheet made in [from, to), oldest first
+func (s *mysqlStorage) ListPayments(ctx context.Context, sheetID string, from time.Time, to time.Time) ([]Payment, error) {
+	rows, err := s.query(ctx, "SELECT p.`payment_id`, c.`name`, p.`amount`, COALESCE(p.`currency`, s.`currency`), p.`comment`, p.`payment_made_time`, p.`author_user_id`, p.`author_name` FROM `payment` p "+
+		"JOIN `sheet` s ON s.`sheet_id` = p.`sheet_id` "+
+		"LEFT JOIN `category` c ON c.`category_id` = p.`category_id` "+
+		"WHERE p.`sheet_id` = ? AND p.`payment_made_time` >= ? AND p.`payment_made_time` < ? ORDER BY p.`payment_made_time`",
+		sheetID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []Payment
+	for rows.Next() {
+		var payment Payment
+		var categoryName, comment, authorName sql.NullString
+		var authorUserID sql.NullInt64
+		if err := rows.Scan(&payment.id, &categoryName, &payment.amount, &payment.currency, &comment, &payment.madeTime, &authorUserID, &authorName); err != nil {
+			return nil, err
+		}
+		payment.categoryName = categoryName.String
+		payment.comment = comment.String
+		payment.author = Author{userID: authorUserID.Int64, name: authorName.String}
+		payments = append(payments, payment)
+	}
+	return payments, rows.Err()
+}
+
+// InsertPayments inserts either all of the payments or none of them
+func (s *mysqlStorage) InsertPayments(ctx context.Context, sheetID string, payments []Payment) error {
+	return s.withTx(ctx, func(tx *mysqlStorage) error {
+		for _, payment := range payments {
+			_, err := tx.exec(ctx, "INSERT INTO `payment` (`payment_id`, `sheet_id`, `category_id`, `amount`, `currency`, `comment`, `payment_made_time`, `author_user_id`, `author_name`) VALUES (?, ?, ?, ?, "+paymentCurrency+", ?, ?, ?, ?)",
+				payment.id, sheetID, payment.categoryID, payment.amount, sheetID, payment.comment, payment.madeTime, payment.author.userID, payment.author.name)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetImportProfile returns nil if the sheet has no import profile saved yet
+func (s *mysqlStorage) GetImportProfile(ctx context.Context, sheetID string) (*ImportProfile, error) {
+	var profile ImportProfile
+
+	err := s.queryRow(ctx, "SELECT `date_column`, `amount_column`, `description_column`, `date_layout`, `expenses_negative` FROM `import_profile` WHERE `sheet_id` = ?", sheetID).
+		Scan(&profile.dateColumn, &profile.amountColumn, &profile.descriptionColumn, &profile.dateLayout, &profile.expensesNegative)
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+func (s *mysqlStorage) SaveImportProfile(ctx context.Context, sheetID string, profile *ImportProfile) error {
+	_, err := s.exec(ctx, "INSERT INTO `import_profile` (`sheet_id`, `date_column`, `amount_column`, `description_column`, `date_layout`, `expenses_negative`) VALUES (?, ?, ?, ?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE `date_column` = VALUES(`date_column`), `amount_column` = VALUES(`amount_column`), `description_column` = VALUES(`description_column`), "+
+		"`date_layout` = VALUES(`date_layout`), `expenses_negative` = VALUES(`expenses_negative`)",
+		sheetID, profile.dateColumn, profile.amountColumn, profile.descriptionColumn, profile.dateLayout, profile.expensesNegative)
+	return err
+}
+
+// ListRules returns the categorization rules of the sheet in the order they are applied
+func (s *mysqlStorage) ListRules(ctx context.Context, sheetID string) ([]*CategorizationRule, error) {
+	rows, err := s.query(ctx, "SELECT r.`rule_id`, r.`position`, r.`conditions`, c.`name` FROM `categorization_rule` r "+
+		"JOIN `category` c ON c.`category_id` = r.`category_id` WHERE r.`sheet_id` = ? ORDER BY r.`position`", sheetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []*CategorizationRule
+	for rows.Next() {
+		var rule CategorizationRule
+		if err := rows.Scan(&rule.id, &rule.position, &rule.conditionsText, &rule.categoryName); err != nil {
+			return nil, err
+		}
+		if err := rule.parseConditions(); err != nil {
+			return nil, err
+		}
+		rules = append(rules, &rule)
+	}
+	return rules, rows.Err()
+}
+
+// InsertNewRule adds the rule after all the existing rules of the sheet
+func (s *mysqlStorage) InsertNewRule(ctx context.Context, sheetID string, id string, conditions string, categoryID string) error {
+	_, err := s.exec(ctx, "INSERT INTO `categorization_rule` (`rule_id`, `sheet_id`, `position`, `conditions`, `category_id`) "+
+		"SELECT ?, ?, COALESCE(MAX(`position`), 0) + 1, ?, ? FROM `categorization_rule` WHERE `sheet_id` = ?",
+		id, sheetID, conditions, categoryID, sheetID)
+	return err
+}
+
+// MoveRule moves the rule at one position to another, shifting the rules in between. Positions start with 1
+func (s *mysqlStorage) MoveRule(ctx context.Context, sheetID string, from int, to int) error {
+	return s.withTx(ctx, func(tx *mysqlStorage) error {
+		rows, err := tx.query(ctx, "SELECT `rule_id` FROM `categorization_rule` WHERE `sheet_id` = ? ORDER BY `position` FOR UPDATE", sheetID)
+		if err != nil {
+			return err
+		}
+		var ruleIDs []string
+		for rows.Next() {
+			var ruleID string
+			if err := rows.Scan(&ruleID); err != nil {
+				rows.Close()
+				return err
+			}
+			ruleIDs = append(ruleIDs, ruleID)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if from < 1 || from > len(ruleIDs) || to < 1 || to > len(ruleIDs) {
+			return errNotFound
+		}
+
+		moved := ruleIDs[from-1]
+		ruleIDs = append(ruleIDs[:from-1], ruleIDs[from:]...)
+		ruleIDs = append(ruleIDs[:to-1], append([]string{moved}, ruleIDs[to-1:]...)...)
+		for i, ruleID := range ruleIDs {
+			if _, err := tx.exec(ctx, "UPDATE `categorization_rule` SET `position` = ? WHERE `rule_id` = ?", i+1, ruleID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// DeleteRule returns false if the sheet has no rule at this position. The rules after it move up,
+// so that the positions stay the ones /listRules shows
+func (s *mysqlStorage) DeleteRule(ctx context.Context, sheetID string, position int) (bool, error) {
+	deleted := false
+	err := s.withTx(ctx, func(tx *mysqlStorage) error {
+		res, err := tx.exec(ctx, "DELETE FROM `categorization_rule` WHERE `sheet_id` = ? AND `position` = ?", sheetID, position)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		deleted = affected > 0
+		if !deleted {
+			return nil
+		}
+
+		_, err = tx.exec(ctx, "UPDATE `categorization_rule` SET `position` = `position` - 1 WHERE `sheet_id` = ? AND `position` > ?", sheetID, position)
+		return err
+	})
+	return deleted, err
+}
+
+// InsertSharedPayment stores the payment together with who paid it and how it is split between the participants
+func (s *mysqlStorage) InsertSharedPayment(ctx context.Context, sheetID string, payment Payment, paidByUserID int64, split map[int64]int64) error {
+	return s.withTx(ctx, func(tx *mysqlStorage) error {
+		_, err := tx.exec(ctx, "INSERT INTO `payment` (`payment_id`, `sheet_id`, `category_id`, `amount`, `currency`, `comment`, `payment_made_time`, `paid_by_user_id`, `author_user_id`, `author_name`) VALUES (?, ?, ?, ?, "+paymentCurrency+", ?, ?, ?, ?, ?)",
+			payment.id, sheetID, payment.categoryID, payment.amount, sheetID, payment.comment, payment.madeTime, paidByUserID, payment.author.userID, payment.author.name)
+		if err != nil {
+			return err
+		}
+		for userID, amount := range split {
+			_, err := tx.exec(ctx, "INSERT INTO `payment_split` (`payment_id`, `sheet_id`, `user_id`, `amount`) VALUES (?, ?, ?, ?)", payment.id, sheetID, userID, amount)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetBalances returns how much each participant is owed (positive) or owes (negative) in the shared payments of the sheet
+func (s *mysqlStorage) GetBalances(ctx context.Context, sheetID string) (map[int64]int64, error) {
+	balances := make(map[int64]int64)
+	queries := []string{
+		"SELECT `paid_by_user_id`, SUM(`amount`) FROM `payment` WHERE `sheet_id` = ? AND `paid_by_user_id` IS NOT NULL GROUP BY `paid_by_user_id`",
+		"SELECT `user_id`, -SUM(`amount`) FROM `payment_split` WHERE `sheet_id` = ? GROUP BY `user_id`",
+		"SELECT `from_user_id`, SUM(`amount`) FROM `settlement` WHERE `sheet_id` = ? GROUP BY `from_user_id`",
+		"SELECT `to_user_id`, -SUM(`amount`) FROM `settlement` WHERE `sheet_id` = ? GROUP BY `to_user_id`",
+	}
+	for _, query := range queries {
+		rows, err := s.query(ctx, query, sheetID)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var userID, amount int64
+			if err := rows.Scan(&userID, &amount); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			balances[userID] += amount
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return balances, nil
+}
+
+// InsertSettlements records the transfers made to settle up the balances
+func (s *mysqlStorage) InsertSettlements(ctx context.Context, sheetID string, transfers []Transfer, madeTime time.Time) error {
+	return s.withTx(ctx, func(tx *mysqlStorage) error {
+		for _, transfer := range transfers {
+			_, err := tx.exec(ctx, "INSERT INTO `settlement` (`settlement_id`, `sheet_id`, `from_user_id`, `to_user_id`, `amount`, `settlement_time`) VALUES (?, ?, ?, ?, ?, ?)",
+				uuid.New().String(), sheetID, transfer.fromUserID, transfer.toUserID, transfer.amount, madeTime)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetChatLanguage returns an empty string if the chat hasn't chosen a language
+func (s *mysqlStorage) GetChatLanguage(ctx context.Context, chatID int64) (string, error) {
+	var language string
+	err := s.queryRow(ctx, "SELECT `language` FROM `chat_language` WHERE `chat_id` = ?", chatID).Scan(&language)
+	if errors.Is(err, errNotFound) {
+		return "", nil
+	}
+	return language, err
+}
+
+func (s *mysqlStorage) SetChatLanguage(ctx context.Context, chatID int64, language string) error {
+	_, err := s.exec(ctx, "INSERT INTO `chat_language` (`chat_id`, `language`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `language` = VALUES(`language`)", chatID, language)
+	return err
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Kinds of storage failures, the errors Storage returns are checked against them with errors.Is
+var (
+	errNotFound = errors.New("not found")
+	// E.g. a second sheet with the same ID
+	errDuplicate = errors.New("duplicate")
+	// E.g. a name too long for its column
+	errConstraint = errors.New("constraint violation")
+	// E.g. a deadlock or a lost connection, which may well not happen again
+	errTransient = errors.New("transient failure")
+)
+
+// storageError keeps the error of the database together with its kind
+type storageError struct {
+	kind error
+	err  error
+}
+
+func (e *storageError) Error() string {
+	return e.kind.Error() + ": " + e.err.Error()
+}
+
+func (e *storageError) Unwrap() error {
+	return e.err
+}
+
+func (e *storageError) Is(target error) bool {
+	return target == e.kind
+}
+
+// classifyMySQLError wraps the error with its kind, errors of an unknown kind are returned as they are
+func classifyMySQLError(err error) error {
+	var classified *storageError
+	if err == nil || errors.As(err, &classified) {
+		return err
+	}
+	if kind := mysqlErrorKind(err); kind != nil {
+		return &storageError{kind: kind, err: err}
+	}
+	return err
+}
+
+func mysqlErrorKind(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return errNotFound
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, context.DeadlineExceeded) {
+		return errTransient
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1062: // ER_DUP_ENTRY
+			return errDuplicate
+		case 1048, 1406, 1451, 1452, 3819: // ER_BAD_NULL_ERROR, ER_DATA_TOO_LONG, ER_ROW_IS_REFERENCED_2, ER_NO_REFERENCED_ROW_2, ER_CHECK_CONSTRAINT_VIOLATED
+			return errConstraint
+		case 1040, 1205, 1213: // ER_CON_COUNT_ERROR, ER_LOCK_WAIT_TIMEOUT, ER_LOCK_DEADLOCK
+			return errTransient
+		}
+		return nil
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return errTransient
+	}
+	return nil
+}
+
+const (
+	// Transient failures are retried until there have been this many attempts
+	storageAttempts = 3
+	// The wait before the next attempt grows by this much after every attempt
+	storageRetryDelay = 100 * time.Millisecond
+)
+
+// retryable tells whether the failure is known to have happened before the statement was applied, so that running
+// it again can't apply it twice. A connection lost or a deadline passed midway may have been after the commit
+func retryable(err error) bool {
+	// The driver only returns it when the statement wasn't sent, database/sql retries it once itself
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1040, 1205, 1213: // ER_CON_COUNT_ERROR, ER_LOCK_WAIT_TIMEOUT, ER_LOCK_DEADLOCK
+			return true
+		}
+	}
+	return false
+}
+
+// retryTransient runs fn again while it fails with a transient error that is retryable, as long as the context allows
+func retryTransient(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if !errors.Is(err, errTransient) || !retryable(err) || attempt == storageAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * storageRetryDelay):
+		}
+	}
+}
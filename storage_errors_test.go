@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestClassifyMySQLError(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind error
+	}{
+		{sql.ErrNoRows, errNotFound},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, errDuplicate},
+		{&mysql.MySQLError{Number: 1406, Message: "Data too long"}, errConstraint},
+		{&mysql.MySQLError{Number: 1213, Message: "Deadlock found"}, errTransient},
+		{fmt.Errorf("query: %w", mysql.ErrInvalidConn), errTransient},
+		{context.DeadlineExceeded, errTransient},
+	}
+	for _, test := range tests {
+		err := classifyMySQLError(test.err)
+		if !errors.Is(err, test.kind) {
+			t.Errorf("classifyMySQLError(%v) = %v, expected it to be %v", test.err, err, test.kind)
+		}
+		if !errors.Is(err, test.err) {
+			t.Errorf("classifyMySQLError(%v) = %v, expected it to wrap the original error", test.err, err)
+		}
+	}
+
+	other := &mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax"}
+	if err := classifyMySQLError(other); err != other {
+		t.Errorf("classifyMySQLError(%v) = %v, expected the error as it is", other, err)
+	}
+}
+
+func TestRetryTransient(t *testing.T) {
+	attempts := 0
+	err := retryTransient(context.Background(), func() error {
+		attempts++
+		if attempts < storageAttempts {
+			return classifyMySQLError(&mysql.MySQLError{Number: 1213, Message: "Deadlock found"})
+		}
+		return nil
+	})
+	if err != nil || attempts != storageAttempts {
+		t.Errorf("got %v after %d attempts, expected success after %d", err, attempts, storageAttempts)
+	}
+
+	attempts = 0
+	err = retryTransient(context.Background(), func() error {
+		attempts++
+		return &storageError{kind: errDuplicate, err: errors.New("duplicate entry")}
+	})
+	if !errors.Is(err, errDuplicate) || attempts != 1 {
+		t.Errorf("got %v after %d attempts, expected the duplicate error without retries", err, attempts)
+	}
+
+	// The statement may have been applied before the connection was lost
+	attempts = 0
+	err = retryTransient(context.Background(), func() error {
+		attempts++
+		return classifyMySQLError(fmt.Errorf("exec: %w", mysql.ErrInvalidConn))
+	})
+	if !errors.Is(err, errTransient) || attempts != 1 {
+		t.Errorf("got %v after %d attempts, expected the lost connection without retries", err, attempts)
+	}
+}
@@ -0,0 +1,713 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryStorage keeps everything in memory, so that the handler can run without a database, e.g. in tests.
+// It behaves like mysqlStorage, down to the case insensitive category names. Like the handler itself,
+// it isn't meant to be used from several goroutines at once
+type memoryStorage struct {
+	data *memoryData
+	// Set in the storage WithTx passes on
+	inTx bool
+}
+
+type memoryData struct {
+	sheets         map[string]memorySheet
+	currentSheets  map[int64]string
+	members        map[string]map[int64]Role
+	memberNames    map[string]map[int64]string
+	participants   map[string]map[int64]SheetParticipant
+	invites        map[string]memoryInvite
+	categories     []memoryCategory
+	payments       []memoryPayment
+	importProfiles map[string]ImportProfile
+	rules          []memoryRule
+	settlements    []memorySettlement
+	chatLanguages  map[int64]string
+}
+
+type memorySheet struct {
+	ownerChatID    int64
+	name           string
+	passwordHash   string
+	currency       string
+	fundingAccount string
+}
+
+type memoryInvite struct {
+	sheetID         string
+	createdByChatID int64
+	invite          SheetInvite
+}
+
+type memoryCategory struct {
+	id      string
+	sheetID string
+	name    string
+	author  Author
+}
+
+type memoryPayment struct {
+	sheetID string
+	payment Payment
+	// Nil unless the payment is shared, never changed once inserted
+	split map[int64]int64
+}
+
+type memoryRule struct {
+	id         string
+	sheetID    string
+	position   int
+	conditions string
+	categoryID string
+}
+
+type memorySettlement struct {
+	id       string
+	sheetID  string
+	transfer Transfer
+	madeTime time.Time
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{data: &memoryData{
+		sheets:         make(map[string]memorySheet),
+		currentSheets:  make(map[int64]string),
+		members:        make(map[string]map[int64]Role),
+		memberNames:    make(map[string]map[int64]string),
+		participants:   make(map[string]map[int64]SheetParticipant),
+		invites:        make(map[string]memoryInvite),
+		importProfiles: make(map[string]ImportProfile),
+		chatLanguages:  make(map[int64]string),
+	}}
+}
+
+// clone copies the data deep enough for the copy to be restored when a transaction is rolled back
+func (d *memoryData) clone() *memoryData {
+	c := &memoryData{
+		sheets:         make(map[string]memorySheet, len(d.sheets)),
+		currentSheets:  make(map[int64]string, len(d.currentSheets)),
+		members:        make(map[string]map[int64]Role, len(d.members)),
+		memberNames:    make(map[string]map[int64]string, len(d.memberNames)),
+		participants:   make(map[string]map[int64]SheetParticipant, len(d.participants)),
+		invites:        make(map[string]memoryInvite, len(d.invites)),
+		categories:     append([]memoryCategory(nil), d.categories...),
+		payments:       append([]memoryPayment(nil), d.payments...),
+		importProfiles: make(map[string]ImportProfile, len(d.importProfiles)),
+		rules:          append([]memoryRule(nil), d.rules...),
+		settlements:    append([]memorySettlement(nil), d.settlements...),
+		chatLanguages:  make(map[int64]string, len(d.chatLanguages)),
+	}
+	for id, sheet := range d.sheets {
+		c.sheets[id] = sheet
+	}
+	for chatID, sheetID := range d.currentSheets {
+		c.currentSheets[chatID] = sheetID
+	}
+	for sheetID, members := range d.members {
+		c.members[sheetID] = make(map[int64]Role, len(members))
+		for chatID, role := range members {
+			c.members[sheetID][chatID] = role
+		}
+	}
+	for sheetID, names := range d.memberNames {
+		c.memberNames[sheetID] = make(map[int64]string, len(names))
+		for chatID, name := range names {
+			c.memberNames[sheetID][chatID] = name
+		}
+	}
+	for sheetID, participants := range d.participants {
+		c.participants[sheetID] = make(map[int64]SheetParticipant, len(participants))
+		for userID, participant := range participants {
+			c.participants[sheetID][userID] = participant
+		}
+	}
+	for token, invite := range d.invites {
+		c.invites[token] = invite
+	}
+	for sheetID, profile := range d.importProfiles {
+		c.importProfiles[sheetID] = profile
+	}
+	for chatID, language := range d.chatLanguages {
+		c.chatLanguages[chatID] = language
+	}
+	return c
+}
+
+// WithTx restores the data as it was before fn if fn fails
+func (s *memoryStorage) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+
+	snapshot := s.data.clone()
+	if err := fn(&memoryStorage{data: s.data, inTx: true}); err != nil {
+		*s.data = *snapshot
+		return err
+	}
+	return nil
+}
+
+func (s *memoryStorage) InsertNewPayment(ctx context.Context, sheetID *string, categoryID string, id string, amount int64, comment string, time time.Time, author Author) error {
+	var sheet string
+	if sheetID != nil {
+		sheet = *sheetID
+	}
+	s.data.payments = append(s.data.payments, memoryPayment{sheetID: sheet, payment: Payment{
+		id: id, categoryID: categoryID, amount: amount, currency: s.data.sheets[sheet].currency, comment: comment, madeTime: time, author: author,
+	}})
+	return nil
+}
+
+func (s *memoryStorage) ListCategoryIDs(ctx context.Context, sheetID string) (map[string]string, error) {
+	categoryIDs := make(map[string]string)
+	for _, category := range s.data.categories {
+		if category.sheetID == sheetID {
+			categoryIDs[category.name] = category.id
+		}
+	}
+	return categoryIDs, nil
+}
+
+func (s *memoryStorage) FindCategory(ctx context.Context, sheetID *string, categoryName string) (string, error) {
+	if sheetID == nil {
+		return "", nil
+	}
+	for _, category := range s.data.categories {
+		if category.sheetID == *sheetID && strings.EqualFold(category.name, categoryName) {
+			return category.id, nil
+		}
+	}
+	return "", nil
+}
+
+func (s *memoryStorage) InsertNewCategory(ctx context.Context, sheetID string, id string, name string, author Author) error {
+	s.data.categories = append(s.data.categories, memoryCategory{id: id, sheetID: sheetID, name: name, author: author})
+	return nil
+}
+
+func (s *memoryStorage) ListCategories(ctx context.Context, sheetID string) ([]string, error) {
+	var categories []string
+	for _, category := range s.data.categories {
+		if category.sheetID == sheetID {
+			categories = append(categories, category.name)
+		}
+	}
+	return categories, nil
+}
+
+func (s *memoryStorage) categoryName(categoryID string) (string, bool) {
+	for _, category := range s.data.categories {
+		if category.id == categoryID {
+			return category.name, true
+		}
+	}
+	return "", false
+}
+
+func (s *memoryStorage) CheckPassword(ctx context.Context, sheetID string, password string) (bool, error) {
+	sheet, ok := s.data.sheets[sheetID]
+	return ok && checkPasswordHash(sheet.passwordHash, password), nil
+}
+
+func (s *memoryStorage) UpdatePassword(ctx context.Context, sheetID string, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	if sheet, ok := s.data.sheets[sheetID]; ok {
+		sheet.passwordHash = hash
+		s.data.sheets[sheetID] = sheet
+	}
+	return nil
+}
+
+func (s *memoryStorage) InsertNewSheet(ctx context.Context, chatID int64, id string, name string, password string) error {
+	if _, exists := s.data.sheets[id]; exists {
+		return errDuplicate
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	s.data.sheets[id] = memorySheet{ownerChatID: chatID, name: name, passwordHash: hash, currency: "EUR", fundingAccount: "Assets:Cash"}
+	return nil
+}
+
+func (s *memoryStorage) ConnectToSheet(ctx context.Context, chatID int64, sheetID string) error {
+	s.data.currentSheets[chatID] = sheetID
+	return nil
+}
+
+func (s *memoryStorage) FetchCurrentSheetFromDB(ctx context.Context, chatID int64) (*string, error) {
+	if sheetID, ok := s.data.currentSheets[chatID]; ok {
+		return &sheetID, nil
+	}
+	return nil, nil
+}
+
+func (s *memoryStorage) DisconnectFromSheet(ctx context.Context, chatID int64) error {
+	delete(s.data.currentSheets, chatID)
+	return nil
+}
+
+// ListSheets orders the sheets by name, the order of the MySQL one is whatever the database returns
+func (s *memoryStorage) ListSheets(ctx context.Context, chatID int64) ([]Sheet, error) {
+	var sheets []Sheet
+	for sheetID, members := range s.data.members {
+		if role, ok := members[chatID]; ok {
+			sheets = append(sheets, Sheet{id: sheetID, name: s.data.sheets[sheetID].name, role: role})
+		}
+	}
+	sort.Slice(sheets, func(i, j int) bool {
+		return sheets[i].name < sheets[j].name
+	})
+	return sheets, nil
+}
+
+func (s *memoryStorage) GetSheetName(ctx context.Context, sheetID string) (string, error) {
+	sheet, ok := s.data.sheets[sheetID]
+	if !ok {
+		return "", errNotFound
+	}
+	return sheet.name, nil
+}
+
+func (s *memoryStorage) GetSheetSettings(ctx context.Context, sheetID string) (*SheetSettings, error) {
+	sheet, ok := s.data.sheets[sheetID]
+	if !ok {
+		return nil, errNotFound
+	}
+	return &SheetSettings{currency: sheet.currency, fundingAccount: sheet.fundingAccount}, nil
+}
+
+// updateSheet changes the sheet if it exists, like an UPDATE which matches no rows
+func (s *memoryStorage) updateSheet(sheetID string, update func(sheet *memorySheet)) {
+	if sheet, ok := s.data.sheets[sheetID]; ok {
+		update(&sheet)
+		s.data.sheets[sheetID] = sheet
+	}
+}
+
+func (s *memoryStorage) UpdateSheetCurrency(ctx context.Context, sheetID string, currency string) error {
+	s.updateSheet(sheetID, func(sheet *memorySheet) { sheet.currency = currency })
+	return nil
+}
+
+func (s *memoryStorage) UpdateSheetFundingAccount(ctx context.Context, sheetID string, fundingAccount string) error {
+	s.updateSheet(sheetID, func(sheet *memorySheet) { sheet.fundingAccount = fundingAccount })
+	return nil
+}
+
+func (s *memoryStorage) RenameSheet(ctx context.Context, sheetID string, name string) error {
+	s.updateSheet(sheetID, func(sheet *memorySheet) { sheet.name = name })
+	return nil
+}
+
+func (s *memoryStorage) TransferOwnership(ctx context.Context, sheetID string, ownerChatID int64, newOwnerChatID int64) error {
+	s.updateSheet(sheetID, func(sheet *memorySheet) { sheet.ownerChatID = newOwnerChatID })
+	if err := s.UpdateSheetMemberRole(ctx, sheetID, newOwnerChatID, RoleOwner); err != nil {
+		return err
+	}
+	return s.UpdateSheetMemberRole(ctx, sheetID, ownerChatID, RoleEditor)
+}
+
+func (s *memoryStorage) DeleteSheet(ctx context.Context, sheetID string) error {
+	d := s.data
+	delete(d.sheets, sheetID)
+	delete(d.members, sheetID)
+	delete(d.memberNames, sheetID)
+	delete(d.participants, sheetID)
+	delete(d.importProfiles, sheetID)
+	for chatID, currentSheetID := range d.currentSheets {
+		if currentSheetID == sheetID {
+			delete(d.currentSheets, chatID)
+		}
+	}
+	for token, invite := range d.invites {
+		if invite.sheetID == sheetID {
+			delete(d.invites, token)
+		}
+	}
+
+	var categories []memoryCategory
+	for _, category := range d.categories {
+		if category.sheetID != sheetID {
+			categories = append(categories, category)
+		}
+	}
+	d.categories = categories
+	var payments []memoryPayment
+	for _, payment := range d.payments {
+		if payment.sheetID != sheetID {
+			payments = append(payments, payment)
+		}
+	}
+	d.payments = payments
+	var rules []memoryRule
+	for _, rule := range d.rules {
+		if rule.sheetID != sheetID {
+			rules = append(rules, rule)
+		}
+	}
+	d.rules = rules
+	var settlements []memorySettlement
+	for _, settlement := range d.settlements {
+		if settlement.sheetID != sheetID {
+			settlements = append(settlements, settlement)
+		}
+	}
+	d.settlements = settlements
+	return nil
+}
+
+func (s *memoryStorage) GetSheetOwnerChatID(ctx context.Context, sheetID string) (int64, error) {
+	sheet, ok := s.data.sheets[sheetID]
+	if !ok {
+		return 0, errNotFound
+	}
+	return sheet.ownerChatID, nil
+}
+
+func (s *memoryStorage) AddSheetMember(ctx context.Context, sheetID string, chatID int64, role Role) error {
+	members, ok := s.data.members[sheetID]
+	if !ok {
+		members = make(map[int64]Role)
+		s.data.members[sheetID] = members
+	}
+	if _, isMember := members[chatID]; !isMember {
+		members[chatID] = role
+	}
+	return nil
+}
+
+func (s *memoryStorage) GetSheetMemberRole(ctx context.Context, sheetID string, chatID int64) (Role, error) {
+	return s.data.members[sheetID][chatID], nil
+}
+
+func (s *memoryStorage) ListSheetMembers(ctx context.Context, sheetID string) ([]SheetMember, error) {
+	var members []SheetMember
+	for chatID, role := range s.data.members[sheetID] {
+		members = append(members, SheetMember{chatID: chatID, role: role, name: s.data.memberNames[sheetID][chatID]})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].chatID < members[j].chatID
+	})
+	return members, nil
+}
+
+func (s *memoryStorage) UpdateSheetMemberRole(ctx context.Context, sheetID string, chatID int64, role Role) error {
+	if _, ok := s.data.members[sheetID][chatID]; ok {
+		s.data.members[sheetID][chatID] = role
+	}
+	return nil
+}
+
+func (s *memoryStorage) RemoveSheetMember(ctx context.Context, sheetID string, chatID int64) error {
+	delete(s.data.members[sheetID], chatID)
+	delete(s.data.memberNames[sheetID], chatID)
+	for userID, participant := range s.data.participants[sheetID] {
+		if participant.chatID == chatID {
+			delete(s.data.participants[sheetID], userID)
+		}
+	}
+	if s.data.currentSheets[chatID] == sheetID {
+		delete(s.data.currentSheets, chatID)
+	}
+	return nil
+}
+
+func (s *memoryStorage) RecordSheetParticipant(ctx context.Context, sheetID string, chatID int64, chatName string, user Author) error {
+	if _, isMember := s.data.members[sheetID][chatID]; !isMember {
+		return nil
+	}
+	if s.data.memberNames[sheetID] == nil {
+		s.data.memberNames[sheetID] = make(map[int64]string)
+	}
+	s.data.memberNames[sheetID][chatID] = chatName
+	if s.data.participants[sheetID] == nil {
+		s.data.participants[sheetID] = make(map[int64]SheetParticipant)
+	}
+	s.data.participants[sheetID][user.userID] = SheetParticipant{userID: user.userID, chatID: chatID, name: user.name}
+	return nil
+}
+
+func (s *memoryStorage) ListSheetParticipants(ctx context.Context, sheetID string) ([]SheetParticipant, error) {
+	var participants []SheetParticipant
+	for _, participant := range s.data.participants[sheetID] {
+		participants = append(participants, participant)
+	}
+	sort.Slice(participants, func(i, j int) bool {
+		return participants[i].userID < participants[j].userID
+	})
+	return participants, nil
+}
+
+func (s *memoryStorage) InsertNewInvite(ctx context.Context, sheetID string, chatID int64, token string, role Role, uses int, expiresAt time.Time) error {
+	if _, exists := s.data.invites[token]; exists {
+		return errDuplicate
+	}
+	s.data.invites[token] = memoryInvite{sheetID: sheetID, createdByChatID: chatID, invite: SheetInvite{
+		token: token, role: role, usesLeft: uses, expiresAt: expiresAt,
+	}}
+	return nil
+}
+
+func (i memoryInvite) redeemable(now time.Time) bool {
+	return i.invite.usesLeft > 0 && i.invite.expiresAt.After(now)
+}
+
+func (s *memoryStorage) ListInvites(ctx context.Context, sheetID string, now time.Time) ([]SheetInvite, error) {
+	var invites []SheetInvite
+	for _, invite := range s.data.invites {
+		if invite.sheetID == sheetID && invite.redeemable(now) {
+			invites = append(invites, invite.invite)
+		}
+	}
+	sort.Slice(invites, func(i, j int) bool {
+		return invites[i].expiresAt.Before(invites[j].expiresAt)
+	})
+	return invites, nil
+}
+
+func (s *memoryStorage) RedeemInvite(ctx context.Context, token string, now time.Time) (string, Role, error) {
+	invite, ok := s.data.invites[token]
+	if !ok || !invite.redeemable(now) {
+		return "", RoleNone, nil
+	}
+	invite.invite.usesLeft--
+	s.data.invites[token] = invite
+	return invite.sheetID, invite.invite.role, nil
+}
+
+func (s *memoryStorage) PeekInvite(ctx context.Context, token string, now time.Time) (string, error) {
+	invite, ok := s.data.invites[token]
+	if !ok || !invite.redeemable(now) {
+		return "", nil
+	}
+	return invite.sheetID, nil
+}
+
+func (s *memoryStorage) RevokeInvite(ctx context.Context, sheetID string, token string) (bool, error) {
+	invite, ok := s.data.invites[token]
+	if !ok || invite.sheetID != sheetID {
+		return false, nil
+	}
+	delete(s.data.invites, token)
+	return true, nil
+}
+
+// findPayment returns the index of the payment, or -1 if the sheet has no such payment
+func (s *memoryStorage) findPayment(sheetID string, paymentID string) int {
+	for i, payment := range s.data.payments {
+		if payment.sheetID == sheetID && payment.payment.id == paymentID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *memoryStorage) GetPayment(ctx context.Context, sheetID string, paymentID string) (*Payment, error) {
+	i := s.findPayment(sheetID, paymentID)
+	if i < 0 {
+		return nil, nil
+	}
+	stored := s.data.payments[i].payment
+	payment := Payment{id: stored.id, categoryID: stored.categoryID, amount: stored.amount, comment: stored.comment, madeTime: stored.madeTime, paidByUserID: stored.paidByUserID}
+	payment.categoryName, _ = s.categoryName(stored.categoryID)
+	return &payment, nil
+}
+
+func (s *memoryStorage) DeletePayment(ctx context.Context, sheetID string, paymentID string) error {
+	if i := s.findPayment(sheetID, paymentID); i >= 0 {
+		s.data.payments = append(s.data.payments[:i:i], s.data.payments[i+1:]...)
+	}
+	return nil
+}
+
+func (s *memoryStorage) UpdatePaymentCategory(ctx context.Context, sheetID string, paymentID string, categoryID string) error {
+	if i := s.findPayment(sheetID, paymentID); i >= 0 {
+		s.data.payments[i].payment.categoryID = categoryID
+	}
+	return nil
+}
+
+func (s *memoryStorage) UpdatePaymentAmount(ctx context.Context, sheetID string, paymentID string, amount int64) error {
+	if i := s.findPayment(sheetID, paymentID); i >= 0 {
+		s.data.payments[i].payment.amount = amount
+	}
+	return nil
+}
+
+func (s *memoryStorage) ListPayments(ctx context.Context, sheetID string, from time.Time, to time.Time) ([]Payment, error) {
+	sheet, ok := s.data.sheets[sheetID]
+	if !ok {
+		return nil, nil
+	}
+
+	var payments []Payment
+	for _, stored := range s.data.payments {
+		p := stored.payment
+		if stored.sheetID != sheetID || p.madeTime.Before(from) || !p.madeTime.Before(to) {
+			continue
+		}
+		payment := Payment{id: p.id, amount: p.amount, currency: p.currency, comment: p.comment, madeTime: p.madeTime, author: Author{userID: p.author.userID, name: p.author.name}}
+		payment.categoryName, _ = s.categoryName(p.categoryID)
+		if payment.currency == "" {
+			payment.currency = sheet.currency
+		}
+		payments = append(payments, payment)
+	}
+	sort.SliceStable(payments, func(i, j int) bool {
+		return payments[i].madeTime.Before(payments[j].madeTime)
+	})
+	return payments, nil
+}
+
+func (s *memoryStorage) InsertPayments(ctx context.Context, sheetID string, payments []Payment) error {
+	for _, payment := range payments {
+		s.data.payments = append(s.data.payments, memoryPayment{sheetID: sheetID, payment: Payment{
+			id: payment.id, categoryID: payment.categoryID, amount: payment.amount, currency: s.data.sheets[sheetID].currency, comment: payment.comment, madeTime: payment.madeTime, author: payment.author,
+		}})
+	}
+	return nil
+}
+
+func (s *memoryStorage) GetImportProfile(ctx context.Context, sheetID string) (*ImportProfile, error) {
+	profile, ok := s.data.importProfiles[sheetID]
+	if !ok {
+		return nil, nil
+	}
+	return &profile, nil
+}
+
+func (s *memoryStorage) SaveImportProfile(ctx context.Context, sheetID string, profile *ImportProfile) error {
+	s.data.importProfiles[sheetID] = *profile
+	return nil
+}
+
+// sheetRules returns the indexes of the rules of the sheet in the order they are applied
+func (s *memoryStorage) sheetRules(sheetID string) []int {
+	var indexes []int
+	for i, rule := range s.data.rules {
+		if rule.sheetID == sheetID {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		return s.data.rules[indexes[i]].position < s.data.rules[indexes[j]].position
+	})
+	return indexes
+}
+
+func (s *memoryStorage) ListRules(ctx context.Context, sheetID string) ([]*CategorizationRule, error) {
+	var rules []*CategorizationRule
+	for _, i := range s.sheetRules(sheetID) {
+		stored := s.data.rules[i]
+		categoryName, ok := s.categoryName(stored.categoryID)
+		if !ok {
+			continue
+		}
+		rule := &CategorizationRule{id: stored.id, position: stored.position, conditionsText: stored.conditions, categoryName: categoryName}
+		if err := rule.parseConditions(); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (s *memoryStorage) InsertNewRule(ctx context.Context, sheetID string, id string, conditions string, categoryID string) error {
+	position := 1
+	if indexes := s.sheetRules(sheetID); len(indexes) > 0 {
+		position = s.data.rules[indexes[len(indexes)-1]].position + 1
+	}
+	s.data.rules = append(s.data.rules, memoryRule{id: id, sheetID: sheetID, position: position, conditions: conditions, categoryID: categoryID})
+	return nil
+}
+
+func (s *memoryStorage) MoveRule(ctx context.Context, sheetID string, from int, to int) error {
+	indexes := s.sheetRules(sheetID)
+	if from < 1 || from > len(indexes) || to < 1 || to > len(indexes) {
+		return errNotFound
+	}
+
+	moved := indexes[from-1]
+	indexes = append(indexes[:from-1], indexes[from:]...)
+	indexes = append(indexes[:to-1], append([]int{moved}, indexes[to-1:]...)...)
+	for position, i := range indexes {
+		s.data.rules[i].position = position + 1
+	}
+	return nil
+}
+
+func (s *memoryStorage) DeleteRule(ctx context.Context, sheetID string, position int) (bool, error) {
+	for i, rule := range s.data.rules {
+		if rule.sheetID == sheetID && rule.position == position {
+			s.data.rules = append(s.data.rules[:i:i], s.data.rules[i+1:]...)
+			for j := range s.data.rules {
+				if s.data.rules[j].sheetID == sheetID && s.data.rules[j].position > position {
+					s.data.rules[j].position--
+				}
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memoryStorage) InsertSharedPayment(ctx context.Context, sheetID string, payment Payment, paidByUserID int64, split map[int64]int64) error {
+	stored := memoryPayment{sheetID: sheetID, payment: Payment{
+		id: payment.id, categoryID: payment.categoryID, amount: payment.amount, currency: s.data.sheets[sheetID].currency, comment: payment.comment, madeTime: payment.madeTime, author: payment.author, paidByUserID: paidByUserID,
+	}, split: make(map[int64]int64, len(split))}
+	for userID, amount := range split {
+		stored.split[userID] = amount
+	}
+	s.data.payments = append(s.data.payments, stored)
+	return nil
+}
+
+func (s *memoryStorage) GetBalances(ctx context.Context, sheetID string) (map[int64]int64, error) {
+	balances := make(map[int64]int64)
+	for _, stored := range s.data.payments {
+		if stored.sheetID != sheetID || stored.split == nil {
+			continue
+		}
+		balances[stored.payment.paidByUserID] += stored.payment.amount
+		for userID, amount := range stored.split {
+			balances[userID] -= amount
+		}
+	}
+	for _, settlement := range s.data.settlements {
+		if settlement.sheetID == sheetID {
+			balances[settlement.transfer.fromUserID] += settlement.transfer.amount
+			balances[settlement.transfer.toUserID] -= settlement.transfer.amount
+		}
+	}
+	return balances, nil
+}
+
+func (s *memoryStorage) InsertSettlements(ctx context.Context, sheetID string, transfers []Transfer, madeTime time.Time) error {
+	for _, transfer := range transfers {
+		s.data.settlements = append(s.data.settlements, memorySettlement{id: uuid.New().String(), sheetID: sheetID, transfer: transfer, madeTime: madeTime})
+	}
+	return nil
+}
+
+func (s *memoryStorage) GetChatLanguage(ctx context.Context, chatID int64) (string, error) {
+	return s.data.chatLanguages[chatID], nil
+}
+
+func (s *memoryStorage) SetChatLanguage(ctx context.Context, chatID int64, language string) error {
+	s.data.chatLanguages[chatID] = language
+	return nil
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// meteredStorage records the duration and the errors of every call of the storage it wraps.
+// The storage isn't embedded, so that a new Storage method can't be left out by mistake
+type meteredStorage struct {
+	storage Storage
+}
+
+func newMeteredStorage(storage Storage) *meteredStorage {
+	return &meteredStorage{storage: storage}
+}
+
+func observeStorage(method string, start time.Time, err *error) {
+	botMetrics.storageDuration.observe(time.Since(start).Seconds(), method)
+	if *err != nil {
+		botMetrics.storageErrors.add(1, method, storageErrorKind(*err))
+	}
+}
+
+// WithTx passes on a metered storage as well, so that the calls in the transaction are recorded too
+func (s *meteredStorage) WithTx(ctx context.Context, fn func(tx Storage) error) (err error) {
+	defer observeStorage("WithTx", time.Now(), &err)
+	return s.storage.WithTx(ctx, func(tx Storage) error {
+		return fn(newMeteredStorage(tx))
+	})
+}
+
+func (s *meteredStorage) InsertNewPayment(ctx context.Context, sheetID *string, categoryID string, id string, amount int64, comment string, madeTime time.Time, author Author) (err error) {
+	defer observeStorage("InsertNewPayment", time.Now(), &err)
+	return s.storage.InsertNewPayment(ctx, sheetID, categoryID, id, amount, comment, madeTime, author)
+}
+
+func (s *meteredStorage) ListCategoryIDs(ctx context.Context, sheetID string) (_ map[string]string, err error) {
+	defer observeStorage("ListCategoryIDs", time.Now(), &err)
+	return s.storage.ListCategoryIDs(ctx, sheetID)
+}
+
+func (s *meteredStorage) FindCategory(ctx context.Context, sheetID *string, categoryName string) (_ string, err error) {
+	defer observeStorage("FindCategory", time.Now(), &err)
+	return s.storage.FindCategory(ctx, sheetID, categoryName)
+}
+
+func (s *meteredStorage) InsertNewCategory(ctx context.Context, sheetID string, id string, name string, author Author) (err error) {
+	defer observeStorage("InsertNewCategory", time.Now(), &err)
+	return s.storage.InsertNewCategory(ctx, sheetID, id, name, author)
+}
+
+func (s *meteredStorage) ListCategories(ctx context.Context, sheetID string) (_ []string, err error) {
+	defer observeStorage("ListCategories", time.Now(), &err)
+	return s.storage.ListCategories(ctx, sheetID)
+}
+
+func (s *meteredStorage) CheckPassword(ctx context.Context, sheetID string, password string) (_ bool, err error) {
+	defer observeStorage("CheckPassword", time.Now(), &err)
+	return s.storage.CheckPassword(ctx, sheetID, password)
+}
+
+func (s *meteredStorage) UpdatePassword(ctx context.Context, sheetID string, password string) (err error) {
+	defer observeStorage("UpdatePassword", time.Now(), &err)
+	return s.storage.UpdatePassword(ctx, sheetID, password)
+}
+
+func (s *meteredStorage) InsertNewSheet(ctx context.Context, chatID int64, id string, name string, password string) (err error) {
+	defer observeStorage("InsertNewSheet", time.Now(), &err)
+	return s.storage.InsertNewSheet(ctx, chatID, id, name, password)
+}
+
+func (s *meteredStorage) ConnectToSheet(ctx context.Context, chatID int64, sheetID string) (err error) {
+	defer observeStorage("ConnectToSheet", time.Now(), &err)
+	return s.storage.ConnectToSheet(ctx, chatID, sheetID)
+}
+
+func (s *meteredStorage) FetchCurrentSheetFromDB(ctx context.Context, chatID int64) (_ *string, err error) {
+	defer observeStorage("FetchCurrentSheetFromDB", time.Now(), &err)
+	return s.storage.FetchCurrentSheetFromDB(ctx, chatID)
+}
+
+func (s *meteredStorage) DisconnectFromSheet(ctx context.Context, chatID int64) (err error) {
+	defer observeStorage("DisconnectFromSheet", time.Now(), &err)
+	return s.storage.DisconnectFromSheet(ctx, chatID)
+}
+
+func (s *meteredStorage) ListSheets(ctx context.Context, chatID int64) (_ []Sheet, err error) {
+	defer observeStorage("ListSheets", time.Now(), &err)
+	return s.storage.ListSheets(ctx, chatID)
+}
+
+func (s *meteredStorage) GetSheetName(ctx context.Context, sheetID string) (_ string, err error) {
+	defer observeStorage("GetSheetName", time.Now(), &err)
+	return s.storage.GetSheetName(ctx, sheetID)
+}
+
+func (s *meteredStorage) GetSheetSettings(ctx context.Context, sheetID string) (_ *SheetSettings, err error) {
+	defer observeStorage("GetSheetSettings", time.Now(), &err)
+	return s.storage.GetSheetSettings(ctx, sheetID)
+}
+
+func (s *meteredStorage) UpdateSheetCurrency(ctx context.Context, sheetID string, currency string) (err error) {
+	defer observeStorage("UpdateSheetCurrency", time.Now(), &err)
+	return s.storage.UpdateSheetCurrency(ctx, sheetID, currency)
+}
+
+func (s *meteredStorage) UpdateSheetFundingAccount(ctx context.Context, sheetID string, fundingAccount string) (err error) {
+	defer observeStorage("UpdateSheetFundingAccount", time.Now(), &err)
+	return s.storage.UpdateSheetFundingAccount(ctx, sheetID, fundingAccount)
+}
+
+func (s *meteredStorage) RenameSheet(ctx context.Context, sheetID string, name string) (err error) {
+	defer observeStorage("RenameSheet", time.Now(), &err)
+	return s.storage.RenameSheet(ctx, sheetID, name)
+}
+
+func (s *meteredStorage) TransferOwnership(ctx context.Context, sheetID string, ownerChatID int64, newOwnerChatID int64) (err error) {
+	defer observeStorage("TransferOwnership", time.Now(), &err)
+	return s.storage.TransferOwnership(ctx, sheetID, ownerChatID, newOwnerChatID)
+}
+
+func (s *meteredStorage) DeleteSheet(ctx context.Context, sheetID string) (err error) {
+	defer observeStorage("DeleteSheet", time.Now(), &err)
+	return s.storage.DeleteSheet(ctx, sheetID)
+}
+
+func (s *meteredStorage) GetSheetOwnerChatID(ctx context.Context, sheetID string) (_ int64, err error) {
+	defer observeStorage("GetSheetOwnerChatID", time.Now(), &err)
+	return s.storage.GetSheetOwnerChatID(ctx, sheetID)
+}
+
+func (s *meteredStorage) AddSheetMember(ctx context.Context, sheetID string, chatID int64, role Role) (err error) {
+	defer observeStorage("AddSheetMember", time.Now(), &err)
+	return s.storage.AddSheetMember(ctx, sheetID, chatID, role)
+}
+
+func (s *meteredStorage) GetSheetMemberRole(ctx context.Context, sheetID string, chatID int64) (_ Role, err error) {
+	defer observeStorage("GetSheetMemberRole", time.Now(), &err)
+	return s.storage.GetSheetMemberRole(ctx, sheetID, chatID)
+}
+
+func (s *meteredStorage) ListSheetMembers(ctx context.Context, sheetID string) (_ []SheetMember, err error) {
+	defer observeStorage("ListSheetMembers", time.Now(), &err)
+	return s.storage.ListSheetMembers(ctx, sheetID)
+}
+
+func (s *meteredStorage) UpdateSheetMemberRole(ctx context.Context, sheetID string, chatID int64, role Role) (err error) {
+	defer observeStorage("UpdateSheetMemberRole", time.Now(), &err)
+	return s.storage.UpdateSheetMemberRole(ctx, sheetID, chatID, role)
+}
+
+func (s *meteredStorage) RemoveSheetMember(ctx context.Context, sheetID string, chatID int64) (err error) {
+	defer observeStorage("RemoveSheetMember", time.Now(), &err)
+	return s.storage.RemoveSheetMember(ctx, sheetID, chatID)
+}
+
+func (s *meteredStorage) RecordSheetParticipant(ctx context.Context, sheetID string, chatID int64, chatName string, user Author) (err error) {
+	defer observeStorage("RecordSheetParticipant", time.Now(), &err)
+	return s.storage.RecordSheetParticipant(ctx, sheetID, chatID, chatName, user)
+}
+
+func (s *meteredStorage) ListSheetParticipants(ctx context.Context, sheetID string) (_ []SheetParticipant, err error) {
+	defer observeStorage("ListSheetParticipants", time.Now(), &err)
+	return s.storage.ListSheetParticipants(ctx, sheetID)
+}
+
+func (s *meteredStorage) InsertNewInvite(ctx context.Context, sheetID string, chatID int64, token string, role Role, uses int, expiresAt time.Time) (err error) {
+	defer observeStorage("InsertNewInvite", time.Now(), &err)
+	return s.storage.InsertNewInvite(ctx, sheetID, chatID, token, role, uses, expiresAt)
+}
+
+func (s *meteredStorage) ListInvites(ctx context.Context, sheetID string, now time.Time) (_ []SheetInvite, err error) {
+	defer observeStorage("ListInvites", time.Now(), &err)
+	return s.storage.ListInvites(ctx, sheetID, now)
+}
+
+func (s *meteredStorage) RedeemInvite(ctx context.Context, token string, now time.Time) (_ string, _ Role, err error) {
+	defer observeStorage("RedeemInvite", time.Now(), &err)
+	return s.storage.RedeemInvite(ctx, token, now)
+}
+
+func (s *meteredStorage) PeekInvite(ctx context.Context, token string, now time.Time) (_ string, err error) {
+	defer observeStorage("PeekInvite", time.Now(), &err)
+	return s.storage.PeekInvite(ctx, token, now)
+}
+
+func (s *meteredStorage) RevokeInvite(ctx context.Context, sheetID string, token string) (_ bool, err error) {
+	defer observeStorage("RevokeInvite", time.Now(), &err)
+	return s.storage.RevokeInvite(ctx, sheetID, token)
+}
+
+func (s *meteredStorage) GetPayment(ctx context.Context, sheetID string, paymentID string) (_ *Payment, err error) {
+	defer observeStorage("GetPayment", time.Now(), &err)
+	return s.storage.GetPayment(ctx, sheetID, paymentID)
+}
+
+func (s *meteredStorage) DeletePayment(ctx context.Context, sheetID string, paymentID string) (err error) {
+	defer observeStorage("DeletePayment", time.Now(), &err)
+	return s.storage.DeletePayment(ctx, sheetID, paymentID)
+}
+
+func (s *meteredStorage) UpdatePaymentCategory(ctx context.Context, sheetID string, paymentID string, categoryID string) (err error) {
+	defer observeStorage("UpdatePaymentCategory", time.Now(), &err)
+	return s.storage.UpdatePaymentCategory(ctx, sheetID, paymentID, categoryID)
+}
+
+func (s *meteredStorage) UpdatePaymentAmount(ctx context.Context, sheetID string, paymentID string, amount int64) (err error) {
+	defer observeStorage("UpdatePaymentAmount", time.Now(), &err)
+	return s.storage.UpdatePaymentAmount(ctx, sheetID, paymentID, amount)
+}
+
+func (s *meteredStorage) ListPayments(ctx context.Context, sheetID string, from time.Time, to time.Time) (_ []Payment, err error) {
+	defer observeStorage("ListPayments", time.Now(), &err)
+	return s.storage.ListPayments(ctx, sheetID, from, to)
+}
+
+func (s *meteredStorage) InsertPayments(ctx context.Context, sheetID string, payments []Payment) (err error) {
+	defer observeStorage("InsertPayments", time.Now(), &err)
+	return s.storage.InsertPayments(ctx, sheetID, payments)
+}
+
+func (s *meteredStorage) GetImportProfile(ctx context.Context, sheetID string) (_ *ImportProfile, err error) {
+	defer observeStorage("GetImportProfile", time.Now(), &err)
+	return s.storage.GetImportProfile(ctx, sheetID)
+}
+
+func (s *meteredStorage) SaveImportProfile(ctx context.Context, sheetID string, profile *ImportProfile) (err error) {
+	defer observeStorage("SaveImportProfile", time.Now(), &err)
+	return s.storage.SaveImportProfile(ctx, sheetID, profile)
+}
+
+func (s *meteredStorage) ListRules(ctx context.Context, sheetID string) (_ []*CategorizationRule, err error) {
+	defer observeStorage("ListRules", time.Now(), &err)
+	return s.storage.ListRules(ctx, sheetID)
+}
+
+func (s *meteredStorage) InsertNewRule(ctx context.Context, sheetID string, id string, conditions string, categoryID string) (err error) {
+	defer observeStorage("InsertNewRule", time.Now(), &err)
+	return s.storage.InsertNewRule(ctx, sheetID, id, conditions, categoryID)
+}
+
+func (s *meteredStorage) MoveRule(ctx context.Context, sheetID string, from int, to int) (err error) {
+	defer observeStorage("MoveRule", time.Now(), &err)
+	return s.storage.MoveRule(ctx, sheetID, from, to)
+}
+
+func (s *meteredStorage) DeleteRule(ctx context.Context, sheetID string, position int) (_ bool, err error) {
+	defer observeStorage("DeleteRule", time.Now(), &err)
+	return s.storage.DeleteRule(ctx, sheetID, position)
+}
+
+func (s *meteredStorage) InsertSharedPayment(ctx context.Context, sheetID string, payment Payment, paidByUserID int64, split map[int64]int64) (err error) {
+	defer observeStorage("InsertSharedPayment", time.Now(), &err)
+	return s.storage.InsertSharedPayment(ctx, sheetID, payment, paidByUserID, split)
+}
+
+func (s *meteredStorage) GetBalances(ctx context.Context, sheetID string) (_ map[int64]int64, err error) {
+	defer observeStorage("GetBalances", time.Now(), &err)
+	return s.storage.GetBalances(ctx, sheetID)
+}
+
+func (s *meteredStorage) InsertSettlements(ctx context.Context, sheetID string, transfers []Transfer, madeTime time.Time) (err error) {
+	defer observeStorage("InsertSettlements", time.Now(), &err)
+	return s.storage.InsertSettlements(ctx, sheetID, transfers, madeTime)
+}
+
+func (s *meteredStorage) GetChatLanguage(ctx context.Context, chatID int64) (_ string, err error) {
+	defer observeStorage("GetChatLanguage", time.Now(), &err)
+	return s.storage.GetChatLanguage(ctx, chatID)
+}
+
+func (s *meteredStorage) SetChatLanguage(ctx context.Context, chatID int64, language string) (err error) {
+	defer observeStorage("SetChatLanguage", time.Now(), &err)
+	return s.storage.SetChatLanguage(ctx, chatID, language)
+}
@@ -1,7 +1,26 @@
 package main
 
 const (
-	MESSAGE_UNEXPECTED_SERVER_ERROR = "Unexpected server error"
+	MESSAGE_UNEXPECTED_SERVER_ERROR     = "Unexpected server error"
+	MESSAGE_FAILURE_TRY_AGAIN           = "The server is busy right now, please try again in a minute"
+	MESSAGE_FAILURE_NOT_FOUND           = "Could not find it, it may have just been deleted"
+	MESSAGE_FAILURE_DUPLICATE           = "It already exists"
+	MESSAGE_FAILURE_CONSTRAINT          = "Could not save it, probably it is too long or refers to something deleted meanwhile"
+	MESSAGE_ERROR_ID                    = "Error code for support: %s"
+	MESSAGE_FAILURE_PERMISSION_DENIED   = "You need to be at least %s of this sheet to do this"
+	MESSAGE_FAILURE_GROUP_ADMINS_ONLY   = "Only the admins of this group can do this"
+	MESSAGE_FAILURE_DOWNLOAD_DOCUMENT   = "Could not download the document, note that it should be at most 1 MB"
+	MESSAGE_FAILURE_UNEXPECTED_DOCUMENT = "Documents are not supported"
+	MESSAGE_FAILURE_EXPIRED_BUTTON      = "This button has expired, please repeat the command"
+	MESSAGE_BUTTON_PREVIOUS_PAGE        = "« Previous"
+	MESSAGE_BUTTON_NEXT_PAGE            = "Next »"
+	MESSAGE_NOT_CONNECTED_TO_SHEET      = "You are not yet connected to a sheet. Please either create a new one or connect to an existing one.\n/createSheet /connectSheet"
+	MESSAGE_OPTION_CONFIRM              = "Confirm"
+	MESSAGE_OPTION_CANCEL               = "Cancel"
+
+	MESSAGE_INPUT_LANGUAGE       = "Please choose the language"
+	MESSAGE_INCORRECT_LANGUAGE   = "This language is not supported, please choose one from the list"
+	MESSAGE_SUCCESS_SET_LANGUAGE = "Language is changed"
 
 	MESSAGE_START_GREETING = "Hi, BudgliBot for your service!"
 	MESSAGE_START_CONNECT  = `To start using the bot you need to either
@@ -10,17 +29,67 @@ const (
 	MESSAGE_START_FULL_HELP = "Click /help to get the list of all the commands"
 
 	MESSAGE_HELP = `
-- To add a new payment record, simply type "<amount> <category>", e.g "42 groceries". The category with this name must exist prior to this. TBD: It will soon be possible to add a category if it doesn't exist.
+- To add a new payment record, simply type "<amount> <category>", e.g "42 groceries". If there is no category with this name, the categorization rules are tried on the text instead, and if none of them matches, the likely categories are suggested. You can also type just the amount.
+- Amounts are typed with the decimal separator of the chat language, thousands may be separated with spaces or apostrophes, and "1.5k" stands for 1500
+- To add several payments at once, type each of them on its own line. Either all of them are saved or, if some lines can't be read, none
+- Amounts can also be calculated, e.g. "12.5+7.3 lunch", "3*4.99 beer" or "(120-20)/2 dinner"
+- The buttons under an added payment undo it, change its category or edit its amount.
+- Commands that ask for a value also accept it right away, e.g. /createCategory groceries or /setCurrency USD
+
+- To export the payments of the current sheet to a CSV, QIF, OFX, Ledger or Beancount file, click /export
+- To import a bank statement (CSV, QIF or OFX), click /import
 
 Categories:
 - To add a new category, click /createCategory
 - To list your categories, click /listCategories
 
+Shared payments:
+- To add a payment that is split between the sheet members, click /addShared. It can be split equally, by shares or by exact amounts
+- To see how much each member owes or is owed, click /balances
+- To record the transfers that settle all the balances, click /settle
+
+Categorization rules:
+- Rules put imported payments and payments typed without a category into categories, e.g. "description contains LIDL -> groceries" or "amount > 1000 and description matches /rent/ -> housing"
+- To list the rules in the order they are tried, click /listRules
+- To add a rule, click /addRule
+- To change the order of the rules, click /moveRule
+- To delete a rule, click /deleteRule
+- To check which past payments a rule would match, click /testRule
+
 Sheets:
 - To add a new sheet, click /createSheet, but you are very likely to only need one
 - To connect to a sheet, click /connectSheet
 - To disconnect from the current sheet, click /disconnectSheet
-- To list all your sheets, click /listSheets`
+- To list all your sheets, click /listSheets
+- To rename the current sheet, click /renameSheet (owner only)
+- To change the password of the current sheet, click /changePassword (owner only)
+- To set the currency of the current sheet, click /setCurrency (owner only)
+- To set the account payments are funded from in Ledger and Beancount exports, click /setFundingAccount (owner only)
+- To make another member the owner of the current sheet, click /transferOwnership (owner only)
+- To delete the current sheet with all its categories and payments, click /deleteSheet (owner only)
+
+Members:
+- To list the members of the current sheet, click /listMembers
+- To change the role of a member, click /setRole (owner only)
+- To remove a member from the sheet, click /removeMember (owner only)
+
+Invites:
+- To invite someone to the current sheet with a link, click /createInvite (owner only)
+- To list the invites that can still be used, click /listInvites (owner only)
+- To revoke an invite, click /revokeInvite (owner only)
+
+Groups:
+- Add the bot to a group to share a sheet with all its members, every payment records who made it
+- In a group, commands may be sent as /command@bot, and the answers to the bot questions should be sent as replies
+- To connect a group without posting the sheet password there, use the group link of an invite
+
+Language:
+- To choose the language of the bot in this chat, click /language. By default it follows the language of your Telegram app
+
+Roles:
+- viewer can list categories and members
+- editor can also add categories and payments
+- owner can also manage the members`
 
 	MESSAGE_INPUT_NEW_SHEET_NAME               = "Create and enter a name for the new sheet"
 	MESSAGE_INPUT_NEW_SHEET_PASSWORD           = "Please enter new sheet password"
@@ -30,21 +99,148 @@ Sheets:
 	MESSAGE_INCORRECT_SHEET_ID_FORMAT          = "Incorrect sheet ID format, expected to be xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx, e.g e72e1f4c-fb53-4455-9f0e-a1e9d0e1bc4d"
 	MESSAGE_SUCCESS_CONNECT_TO_SHEET           = "Successfully connected to the sheet"
 	MESSAGE_INCORRECT_PASSWORD                 = "Incorrect password, please try again"
+	MESSAGE_TOO_MANY_PASSWORD_ATTEMPTS         = "Too many incorrect password attempts, please try again in %s"
+	MESSAGE_NOTIFY_FAILED_PASSWORD_ATTEMPTS    = "Warning: there have been %d failed password attempts on your sheet %s recently. If it's not someone you know, consider inviting members with /createInvite instead of sharing the password"
 	MESSAGE_INCORRECT_NEW_PASSWORD_SLASH       = "Sheet password shouldn't start with /"
 	MESSAGE_INCORRECT_NEW_PASSWORD_TOO_SHORT   = "Sheet password should be at least 3 characters long"
+	MESSAGE_INCORRECT_NEW_PASSWORD_TOO_LONG    = "Sheet password should be at most 72 bytes long"
 	MESSAGE_INCORRECT_NEW_SHEET_NAME_SLASH     = "Sheet name shouldn't start with /"
 	MESSAGE_INCORRECT_NEW_SHEET_NAME_TOO_SHORT = "Sheet name should be at least 3 characters long"
 	MESSAGE_SUCCESS_DISCONNECT_SHEET           = "Successfully disconnected from the sheet"
-	MESSAGE_LIST_SHEETS_INTRO                  = "Your user owns the following %d sheets:"
+	MESSAGE_LIST_SHEETS_INTRO                  = "Your user is a member of the following %d sheets:"
+	MESSAGE_LIST_SHEETS_ITEM                   = "%2d. Name: %s\n    ID: %s\n    Role: %s\n\n"
+	MESSAGE_SUCCESS_RENAME_SHEET               = "Sheet is renamed"
+	MESSAGE_SUCCESS_CHANGE_SHEET_PASSWORD      = "Sheet password is changed. Existing members stay connected"
+	MESSAGE_INPUT_SHEET_CURRENCY               = "Please enter the currency code of the sheet, e.g. EUR"
+	MESSAGE_INCORRECT_SHEET_CURRENCY           = "Currency code should be 2 to 10 latin letters or digits starting with a letter, e.g. EUR"
+	MESSAGE_SUCCESS_SET_SHEET_CURRENCY         = "Sheet currency is changed"
+	MESSAGE_INPUT_SHEET_FUNDING_ACCOUNT        = "Please enter the account payments are funded from, e.g. Assets:Bank:Checking or Liabilities:CreditCard"
+	MESSAGE_INCORRECT_SHEET_FUNDING_ACCOUNT    = "Account should start with Assets, Liabilities, Equity or Income, followed by capitalized components separated by colons, e.g. Assets:Bank:Checking"
+	MESSAGE_SUCCESS_SET_SHEET_FUNDING_ACCOUNT  = "Funding account is changed"
+	MESSAGE_INPUT_NEW_OWNER                    = "Please choose the new owner. You will become an editor of the sheet"
+	MESSAGE_SUCCESS_TRANSFER_OWNERSHIP         = "Ownership is transferred, you are an editor of the sheet now"
+	MESSAGE_INPUT_DELETE_SHEET_CONFIRMATION    = "This will delete the sheet with all its categories and payments for all its members. This cannot be undone.\n\nTo confirm, enter the sheet name: %s"
+	MESSAGE_CANCELLED_DELETE_SHEET             = "The name doesn't match, the sheet is not deleted"
+	MESSAGE_SUCCESS_DELETE_SHEET               = "Sheet is deleted"
 	MESSAGE_LIST_SHEETS_OUTRO                  = `To add new sheets (if you have one, you are very unlikely to need more), click /createSheet
 To connect to one of these or other sheets, click /connectSheet`
 
-	MESSAGE_FAILURE_UNKNOWN_CATEGORY_NAME = "Could not find category with this name"
-	MESAGE_SUCCESS_CREATE_PAYMENT         = "Successfully created payment record"
-	MESSAGE_FAILURE_PARSING               = "Failed to parse\n\n" + MESSAGE_START_FULL_HELP
+	MESSAGE_FAILURE_UNKNOWN_CATEGORY_NAME  = "Could not find category with this name"
+	MESAGE_SUCCESS_CREATE_PAYMENT          = "Successfully created payment record"
+	MESSAGE_SUCCESS_CREATE_PAYMENT_BY_RULE = "Successfully created payment record in the %s category"
+	MESSAGE_INPUT_SUGGESTED_CATEGORY       = "Could not find category with this name. Please choose a category for this payment"
+	MESSAGE_FAILURE_PARSING                = "Failed to parse\n\n" + MESSAGE_START_FULL_HELP
+	MESSAGE_BUTTON_UNDO                    = "Undo"
+	MESSAGE_BUTTON_CHANGE_CATEGORY         = "Change category"
+	MESSAGE_BUTTON_EDIT_AMOUNT             = "Edit amount"
+	MESSAGE_FAILURE_PAYMENT_NOT_FOUND      = "This payment no longer exists"
+	MESSAGE_FAILURE_EDIT_SHARED_PAYMENT    = "The amount of a shared payment can't be edited, undo it and add it again instead"
+	MESSAGE_SUCCESS_UNDO_PAYMENT           = "Payment of %s in the %s category is deleted"
+	MESSAGE_INPUT_NEW_CATEGORY             = "Please choose the new category of the payment (page %d of %d)"
+	MESSAGE_SUCCESS_CHANGE_CATEGORY        = "Payment is moved to the %s category"
+	MESSAGE_INPUT_NEW_AMOUNT               = "The payment amount is %s, please enter the new one"
+	MESSAGE_INCORRECT_AMOUNT               = "Expected an amount, e.g. 42.50"
+	MESSAGE_COMPUTED_AMOUNT                = "Amount: %s = %s"
+	MESSAGE_SUCCESS_CREATE_PAYMENTS        = "Successfully created %d payment records:"
+	MESSAGE_FAILURE_CREATE_PAYMENTS        = "%d lines could not be read, so none of the payments are saved. Please fix them and send the message again:"
+	MESSAGE_BATCH_INCORRECT_LINE           = "expected \"<amount> <category>\""
+	MESSAGE_BATCH_AMBIGUOUS_AMOUNT         = "the separator in the amount is ambiguous"
+	MESSAGE_AMBIGUOUS_AMOUNT               = "Could not tell whether the separator in the amount is a decimal or a thousands one. Please type the amount like %s or without separators"
+	MESSAGE_SUCCESS_EDIT_AMOUNT            = "Payment amount is changed to %s"
+
+	MESSAGE_INPUT_EXPORT_FORMAT     = "Please choose the file format"
+	MESSAGE_INCORRECT_EXPORT_FORMAT = "Unknown file format, please choose one from the list"
+	MESSAGE_INPUT_EXPORT_DATE_RANGE = "Please choose the period to export or enter two dates, e.g. 2024-01-01 2024-03-31"
+	MESSAGE_INCORRECT_DATE_RANGE    = "Could not parse the period, expected two dates in the YYYY-MM-DD format, e.g. 2024-01-01 2024-03-31"
+	MESSAGE_SUCCESS_EXPORT          = "Exported %d payments"
+	MESSAGE_FAILURE_EXPORT_CURRENCY = "An OFX statement has a single currency, but the payments of this period are in %s. Please choose a period in one currency or another format"
+	MESSAGE_OPTION_THIS_MONTH       = "This month"
+	MESSAGE_OPTION_LAST_MONTH       = "Last month"
+	MESSAGE_OPTION_THIS_YEAR        = "This year"
+	MESSAGE_OPTION_ALL_TIME         = "All time"
+
+	MESSAGE_IMPORT_INSTRUCTIONS = `To import a bank statement, upload it here as a CSV, QIF or OFX file.
+For CSV files with a header row, the first time you will be asked which columns hold the date, the amount and the description, next time the same columns will be used.
+Payments are put into categories by the /listRules rules, then into the category whose name appears in the description, or into "` + uncategorizedCategoryName + `" otherwise. Payments that are already in the sheet are skipped.
+Nothing is saved before you confirm.`
+	MESSAGE_FAILURE_UNKNOWN_DOCUMENT_FORMAT = "Unsupported file format, expected a CSV, QIF or OFX file"
+	MESSAGE_FAILURE_PARSING_STATEMENT       = "Could not read the statement, expected a CSV file with a header row"
+	MESSAGE_INPUT_IMPORT_DATE_COLUMN        = "Which column holds the payment date?"
+	MESSAGE_INPUT_IMPORT_AMOUNT_COLUMN      = "Which column holds the amount?"
+	MESSAGE_INPUT_IMPORT_DESCRIPTION_COLUMN = "Which column holds the description?"
+	MESSAGE_INPUT_IMPORT_EXPENSES_SIGN      = "Is spending shown as negative amounts in this statement?"
+	MESSAGE_INCORRECT_IMPORT_COLUMN         = "There is no such column, please choose one from the list"
+	MESSAGE_INCORRECT_IMPORT_DATE_COLUMN    = "Could not recognize the dates in this column, please choose another one"
+	MESSAGE_FAILURE_EMPTY_IMPORT            = "Could not find any payments in the statement"
+	MESSAGE_FAILURE_ONLY_DUPLICATES_IMPORT  = "All the payments in the statement are already in the sheet"
+	MESSAGE_IMPORT_PREVIEW_INTRO            = "%d payments will be imported, %d already existing ones will be skipped, %d rows could not be read:"
+	MESSAGE_IMPORT_PREVIEW_MORE             = "...and %d more\n"
+	MESSAGE_CANCELLED_IMPORT                = "Import is cancelled"
+	MESSAGE_SUCCESS_IMPORT                  = "Imported %d payments"
+	MESSAGE_OPTION_EXPENSES_NEGATIVE        = "Yes, spending is negative"
+	MESSAGE_OPTION_EXPENSES_POSITIVE        = "No, spending is positive"
 
 	MESSAGE_INPUT_CATEGORY_NAME     = "Please enter new category name"
 	MESSAGE_SUCCESS_CREATE_CATEGORY = "New category is created!"
 	MESSAGE_LIST_CATEGORIES_INTRO   = "This sheet has the following %d categories:"
 	MESSAGE_LIST_CATEGORIES_OUTRO   = "To add new categories, click /createCategory"
+
+	MESSAGE_LIST_RULES_INTRO         = "This sheet has the following %d categorization rules, the first matching one is applied:"
+	MESSAGE_LIST_RULES_OUTRO         = "To add new rules, click /addRule\nTo change their order, click /moveRule\nTo delete a rule, click /deleteRule"
+	MESSAGE_INPUT_RULE               = "Please enter the rule, e.g.\ndescription contains LIDL -> groceries\namount > 1000 and description matches /rent/ -> housing"
+	MESSAGE_INCORRECT_RULE           = "Could not parse the rule: %s"
+	MESSAGE_SUCCESS_ADD_RULE         = "New rule is added, it is tried after all the existing ones"
+	MESSAGE_INPUT_RULE_POSITIONS     = "Please enter the current position of the rule and the new one, e.g. \"3 1\" to make the third rule the first one"
+	MESSAGE_INCORRECT_RULE_POSITIONS = "Expected two rule positions from /listRules, e.g. \"3 1\""
+	MESSAGE_SUCCESS_MOVE_RULE        = "Rule is moved"
+	MESSAGE_INPUT_RULE_POSITION      = "Please enter the position of the rule to delete"
+	MESSAGE_INCORRECT_RULE_POSITION  = "There is no rule at this position, see /listRules"
+	MESSAGE_SUCCESS_DELETE_RULE      = "Rule is deleted"
+	MESSAGE_TEST_RULE_INTRO          = "The rule matches %d of %d payments, %d of them are in another category now. The most recent ones:"
+
+	MESSAGE_INPUT_SHARED_PAYMENT       = "Please enter the shared payment as \"<amount> <category>\", e.g. \"120 groceries\""
+	MESSAGE_INCORRECT_SHARED_PAYMENT   = "Expected \"<amount> <category>\", e.g. \"120 groceries\""
+	MESSAGE_INPUT_SHARED_PAYER         = "Who paid?"
+	MESSAGE_INPUT_SPLIT_MODE           = "How should the payment be split between the members?"
+	MESSAGE_INCORRECT_SPLIT_MODE       = "Please choose one of the options: equally, by shares or by exact amounts"
+	MESSAGE_INPUT_SPLIT_SHARES         = "Please enter the shares as \"<member>:<share>\" pairs, e.g. \"me:2 123456:1\". Members:\n%s"
+	MESSAGE_INPUT_SPLIT_AMOUNTS        = "Please enter the amounts as \"<member>:<amount>\" pairs adding up to %s, e.g. \"me:70 123456:50\". Members:\n%s"
+	MESSAGE_INCORRECT_SPLIT            = "Could not split the payment: %s"
+	MESSAGE_SUCCESS_ADD_SHARED_PAYMENT = "Shared payment is added, it is split as follows:"
+	MESSAGE_LIST_BALANCES_INTRO        = "Balances of the sheet members, positive ones are owed money:"
+	MESSAGE_LIST_BALANCES_OUTRO        = "To settle up, click /settle"
+	MESSAGE_NOTHING_TO_SETTLE          = "All the balances are already settled"
+	MESSAGE_SETTLE_INTRO               = "These transfers settle all the balances:"
+	MESSAGE_SETTLE_OUTRO               = "Confirm once the money is transferred to record the settlement"
+	MESSAGE_CANCELLED_SETTLE           = "Settlement is cancelled"
+	MESSAGE_SUCCESS_SETTLE             = "Settlement is recorded"
+	MESSAGE_OPTION_SPLIT_EQUALLY       = "Equally"
+	MESSAGE_OPTION_SPLIT_BY_SHARES     = "By shares"
+	MESSAGE_OPTION_SPLIT_BY_AMOUNTS    = "By exact amounts"
+
+	MESSAGE_ROLE_VIEWER = "viewer"
+	MESSAGE_ROLE_EDITOR = "editor"
+	MESSAGE_ROLE_OWNER  = "owner"
+
+	MESSAGE_LIST_MEMBERS_INTRO    = "This sheet has the following %d members:"
+	MESSAGE_LIST_MEMBERS_OUTRO    = "To change a member role, click /setRole\nTo remove a member, click /removeMember"
+	MESSAGE_INPUT_MEMBER          = "Please choose a member"
+	MESSAGE_INPUT_ROLE            = "Please choose a new role: editor or viewer"
+	MESSAGE_INCORRECT_MEMBER      = "Could not find such a member, please choose one from the list"
+	MESSAGE_INCORRECT_ROLE        = "Unknown role, expected either editor or viewer"
+	MESSAGE_NO_OTHER_MEMBERS      = "There are no other members in this sheet"
+	MESSAGE_SUCCESS_SET_ROLE      = "Member role is changed"
+	MESSAGE_SUCCESS_REMOVE_MEMBER = "Member is removed from the sheet"
+
+	MESSAGE_INPUT_INVITE_ROLE     = "Please choose the role the invite grants: editor or viewer"
+	MESSAGE_INPUT_INVITE_USES     = "Please enter how many times the invite can be used"
+	MESSAGE_INCORRECT_INVITE_USES = "The number of uses should be between 1 and 100"
+	MESSAGE_CREATED_INVITE        = "New invite is created! Share this link:\n%s\n\nTo connect a group to the sheet instead, add the bot to it with this link:\n%s\n\nRole: %s\nUses: %d\nExpires: %s"
+	MESSAGE_LIST_INVITES_INTRO    = "This sheet has the following %d active invites:"
+	MESSAGE_LIST_INVITES_ITEM     = "%2d. %s\n    Role: %s, uses left: %d, expires: %s\n\n"
+	MESSAGE_LIST_INVITES_OUTRO    = "To create a new invite, click /createInvite\nTo revoke an invite, click /revokeInvite"
+	MESSAGE_NO_INVITES            = "This sheet has no active invites"
+	MESSAGE_INPUT_INVITE_TOKEN    = "Please choose the invite to revoke"
+	MESSAGE_INCORRECT_INVITE      = "This invite is invalid, expired or has already been used"
+	MESSAGE_SUCCESS_REVOKE_INVITE = "Invite is revoked"
 )
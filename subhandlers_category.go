@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -11,7 +12,8 @@ func getCategorySubhandlers(h *Handler) []Subhandler {
 	return []Subhandler{
 		Subhandler{
 			expectedText: "/createCategory",
-			handle: func(text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+			requiredRole: RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
 				chatStatus.stage = CreateCategoryInputName
 
 				return MESSAGE_INPUT_CATEGORY_NAME
@@ -19,13 +21,14 @@ func getCategorySubhandlers(h *Handler) []Subhandler {
 		},
 		Subhandler{
 			expectedStage: CreateCategoryInputName,
-			handle: func(name string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+			requiredRole:  RoleEditor,
+			handle: func(ctx context.Context, name string, chatStatus *ChatStatus, _ *ReplyExtras) string {
 				chatStatus.stage = None
 
 				newCategoryID := uuid.New().String()
-				err := h.storage.InsertNewCategory(*chatStatus.sheetID, newCategoryID, name)
+				err := h.storage.InsertNewCategory(ctx, *chatStatus.sheetID, newCategoryID, name, chatStatus.author)
 				if err != nil {
-					return MESSAGE_UNEXPECTED_SERVER_ERROR
+					return chatStatus.serverError(err)
 				}
 
 				return MESSAGE_SUCCESS_CREATE_CATEGORY
@@ -33,25 +36,44 @@ func getCategorySubhandlers(h *Handler) []Subhandler {
 		},
 		Subhandler{
 			expectedText: "/listCategories",
-			handle: func(text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+			requiredRole: RoleViewer,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
 				chatStatus.stage = None
 
-				categories, err := h.storage.ListCategories(*chatStatus.sheetID)
-				if err != nil {
-					return MESSAGE_UNEXPECTED_SERVER_ERROR
-				}
-
-				var reply strings.Builder
-				fmt.Fprintf(&reply, MESSAGE_LIST_CATEGORIES_INTRO, len(categories))
-				reply.WriteString("\n\n")
-				for i, category := range categories {
-					fmt.Fprintf(&reply, "%2d. %s\n", i+1, category)
-				}
-				reply.WriteString("\n\n")
-				reply.WriteString(MESSAGE_LIST_CATEGORIES_OUTRO)
-
-				return reply.String()
+				return listCategories(ctx, h, chatStatus, replyExtras, 0)
+			},
+		},
+		Subhandler{
+			expectedCallback: callbackCategoriesPage,
+			requiredRole:     RoleViewer,
+			handle: func(ctx context.Context, page string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				return listCategories(ctx, h, chatStatus, replyExtras, parsePage(page))
 			},
 		},
 	}
 }
+
+const callbackCategoriesPage = "catlist"
+
+func listCategories(ctx context.Context, h *Handler, chatStatus *ChatStatus, replyExtras *ReplyExtras, page int) string {
+	categories, err := h.storage.ListCategories(ctx, *chatStatus.sheetID)
+	if err != nil {
+		return chatStatus.serverError(err)
+	}
+
+	from, to, page := paginate(len(categories), page)
+	var reply strings.Builder
+	fmt.Fprintf(&reply, chatStatus.trn(MESSAGE_LIST_CATEGORIES_INTRO, len(categories)), len(categories))
+	reply.WriteString("\n\n")
+	for i := from; i < to; i++ {
+		fmt.Fprintf(&reply, "%2d. %s\n", i+1, categories[i])
+	}
+	reply.WriteString("\n\n")
+	reply.WriteString(chatStatus.tr(MESSAGE_LIST_CATEGORIES_OUTRO))
+
+	if navigation := pageNavigation(len(categories), page, callbackCategoriesPage); navigation != nil {
+		replyExtras.InlineButtons = [][]InlineButton{navigation}
+	}
+
+	return reply.String()
+}
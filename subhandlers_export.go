@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func getExportSubhandlers(h *Handler) []Subhandler {
+	return []Subhandler{
+		Subhandler{
+			expectedText: "/export",
+			requiredRole: RoleViewer,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				chatStatus.stage = ExportInputFormat
+				replyExtras.ReplyOptions = exportFormatNames()
+
+				return MESSAGE_INPUT_EXPORT_FORMAT
+			},
+		},
+		Subhandler{
+			expectedStage: ExportInputFormat,
+			requiredRole:  RoleViewer,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				format := findExportFormat(text)
+				if format == nil {
+					replyExtras.ReplyOptions = exportFormatNames()
+					return MESSAGE_INCORRECT_EXPORT_FORMAT
+				}
+
+				chatStatus.exportFormat = format
+				chatStatus.stage = ExportInputDateRange
+				replyExtras.ReplyOptions = dateRangeOptions
+
+				return MESSAGE_INPUT_EXPORT_DATE_RANGE
+			},
+		},
+		Subhandler{
+			expectedStage: ExportInputDateRange,
+			requiredRole:  RoleViewer,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				from, to, ok := parseDateRange(text, time.Now())
+				if !ok {
+					replyExtras.ReplyOptions = dateRangeOptions
+					return MESSAGE_INCORRECT_DATE_RANGE
+				}
+
+				chatStatus.stage = None
+
+				payments, err := h.storage.ListPayments(ctx, *chatStatus.sheetID, from, to)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				settings, err := h.storage.GetSheetSettings(ctx, *chatStatus.sheetID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				var content bytes.Buffer
+				data := &exportData{sheetID: *chatStatus.sheetID, settings: settings, payments: payments, from: from, to: to}
+				err = chatStatus.exportFormat.write(&content, data)
+				var mixedCurrencies *mixedCurrenciesError
+				if errors.As(err, &mixedCurrencies) {
+					return fmt.Sprintf(chatStatus.tr(MESSAGE_FAILURE_EXPORT_CURRENCY), strings.Join(mixedCurrencies.currencies, ", "))
+				}
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				replyExtras.Document = &Document{
+					Name:    "payments_" + time.Now().Format("2006-01-02") + chatStatus.exportFormat.extension,
+					Content: content.Bytes(),
+				}
+
+				return fmt.Sprintf(chatStatus.trn(MESSAGE_SUCCESS_EXPORT, len(payments)), len(payments))
+			},
+		},
+	}
+}
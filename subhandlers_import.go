@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	importConfirm        = MESSAGE_OPTION_CONFIRM
+	importCancel         = MESSAGE_OPTION_CANCEL
+	expensesNegativeYes  = MESSAGE_OPTION_EXPENSES_NEGATIVE
+	expensesNegativeNo   = MESSAGE_OPTION_EXPENSES_POSITIVE
+	importPreviewMaxRows = 10
+)
+
+func getImportSubhandlers(h *Handler) []Subhandler {
+	return []Subhandler{
+		Subhandler{
+			expectedText: "/import",
+			requiredRole: RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				chatStatus.stage = None
+
+				return MESSAGE_IMPORT_INSTRUCTIONS
+			},
+		},
+		Subhandler{
+			expectedDocument: true,
+			requiredRole:     RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				chatStatus.stage = None
+
+				switch strings.ToLower(filepath.Ext(chatStatus.document.Name)) {
+				case ".csv":
+				case ".ofx", ".qfx":
+					payments, skipped := parseOFX(chatStatus.document.Content)
+					return previewImport(ctx, h, chatStatus, replyExtras, payments, skipped)
+				case ".qif":
+					categories, err := h.storage.ListCategories(ctx, *chatStatus.sheetID)
+					if err != nil {
+						return chatStatus.serverError(err)
+					}
+					payments, skipped := parseQIF(chatStatus.document.Content, categories)
+					return previewImport(ctx, h, chatStatus, replyExtras, payments, skipped)
+				default:
+					return MESSAGE_FAILURE_UNKNOWN_DOCUMENT_FORMAT
+				}
+
+				statement, err := parseCSVStatement(chatStatus.document.Content)
+				if err != nil {
+					return MESSAGE_FAILURE_PARSING_STATEMENT
+				}
+				chatStatus.importStatement = statement
+
+				profile, err := h.storage.GetImportProfile(ctx, *chatStatus.sheetID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				if profile != nil && profile.matches(statement) {
+					chatStatus.importProfile = *profile
+					payments, skipped := profile.readPayments(statement)
+					return previewImport(ctx, h, chatStatus, replyExtras, payments, skipped)
+				}
+
+				chatStatus.importProfile = ImportProfile{}
+				chatStatus.stage = ImportInputDateColumn
+				replyExtras.ReplyOptions = statement.header
+
+				return MESSAGE_INPUT_IMPORT_DATE_COLUMN
+			},
+		},
+		Subhandler{
+			expectedStage: ImportInputDateColumn,
+			requiredRole:  RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				statement := chatStatus.importStatement
+				replyExtras.ReplyOptions = statement.header
+
+				column := statement.column(text)
+				if column < 0 {
+					return MESSAGE_INCORRECT_IMPORT_COLUMN
+				}
+				dateLayout := detectDateLayout(statement.values(column))
+				if dateLayout == "" {
+					return MESSAGE_INCORRECT_IMPORT_DATE_COLUMN
+				}
+
+				chatStatus.importProfile.dateColumn = statement.header[column]
+				chatStatus.importProfile.dateLayout = dateLayout
+				chatStatus.stage = ImportInputAmountColumn
+
+				return MESSAGE_INPUT_IMPORT_AMOUNT_COLUMN
+			},
+		},
+		Subhandler{
+			expectedStage: ImportInputAmountColumn,
+			requiredRole:  RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				statement := chatStatus.importStatement
+				replyExtras.ReplyOptions = statement.header
+
+				column := statement.column(text)
+				if column < 0 {
+					return MESSAGE_INCORRECT_IMPORT_COLUMN
+				}
+
+				chatStatus.importProfile.amountColumn = statement.header[column]
+				chatStatus.stage = ImportInputDescriptionColumn
+
+				return MESSAGE_INPUT_IMPORT_DESCRIPTION_COLUMN
+			},
+		},
+		Subhandler{
+			expectedStage: ImportInputDescriptionColumn,
+			requiredRole:  RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				statement := chatStatus.importStatement
+
+				column := statement.column(text)
+				if column < 0 {
+					replyExtras.ReplyOptions = statement.header
+					return MESSAGE_INCORRECT_IMPORT_COLUMN
+				}
+
+				chatStatus.importProfile.descriptionColumn = statement.header[column]
+				chatStatus.stage = ImportInputExpensesSign
+				replyExtras.ReplyOptions = []string{expensesNegativeYes, expensesNegativeNo}
+
+				return MESSAGE_INPUT_IMPORT_EXPENSES_SIGN
+			},
+		},
+		Subhandler{
+			expectedStage: ImportInputExpensesSign,
+			requiredRole:  RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				switch normalizeText(text) {
+				case normalizeText(expensesNegativeYes):
+					chatStatus.importProfile.expensesNegative = true
+				case normalizeText(expensesNegativeNo):
+					chatStatus.importProfile.expensesNegative = false
+				default:
+					replyExtras.ReplyOptions = []string{expensesNegativeYes, expensesNegativeNo}
+					return MESSAGE_INPUT_IMPORT_EXPENSES_SIGN
+				}
+
+				if err := h.storage.SaveImportProfile(ctx, *chatStatus.sheetID, &chatStatus.importProfile); err != nil {
+					chatStatus.stage = None
+					return chatStatus.serverError(err)
+				}
+
+				payments, skipped := chatStatus.importProfile.readPayments(chatStatus.importStatement)
+				return previewImport(ctx, h, chatStatus, replyExtras, payments, skipped)
+			},
+		},
+		Subhandler{
+			expectedStage: ImportInputConfirmation,
+			requiredRole:  RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				chatStatus.stage = None
+				imported := chatStatus.importPayments
+				chatStatus.importStatement = nil
+				chatStatus.importPayments = nil
+
+				if normalizeText(text) != normalizeText(importConfirm) {
+					return MESSAGE_CANCELLED_IMPORT
+				}
+
+				count, err := commitImport(ctx, h, *chatStatus.sheetID, chatStatus.author, imported)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				botMetrics.paymentsRecorded.add(float64(count), "import")
+
+				return fmt.Sprintf(chatStatus.trn(MESSAGE_SUCCESS_IMPORT, count), count)
+			},
+		},
+	}
+}
+
+// previewImport categorizes the payments read from a statement, marks the duplicates and asks to confirm the result.
+// Payments that already have a category keep it
+func previewImport(ctx context.Context, h *Handler, chatStatus *ChatStatus, replyExtras *ReplyExtras, payments []importedPayment, skipped int) string {
+	chatStatus.stage = None
+
+	if len(payments) == 0 {
+		return MESSAGE_FAILURE_EMPTY_IMPORT
+	}
+
+	categories, err := h.storage.ListCategories(ctx, *chatStatus.sheetID)
+	if err != nil {
+		return chatStatus.serverError(err)
+	}
+	rules, err := h.storage.ListRules(ctx, *chatStatus.sheetID)
+	if err != nil {
+		return chatStatus.serverError(err)
+	}
+	categorizeImportedPayments(payments, rules, categories)
+
+	from, to := importedPaymentsRange(payments)
+	existing, err := h.storage.ListPayments(ctx, *chatStatus.sheetID, from, to)
+	if err != nil {
+		return chatStatus.serverError(err)
+	}
+	markDuplicates(payments, existing)
+
+	duplicates := 0
+	for _, payment := range payments {
+		if payment.duplicate {
+			duplicates++
+		}
+	}
+	if duplicates == len(payments) {
+		return MESSAGE_FAILURE_ONLY_DUPLICATES_IMPORT
+	}
+
+	var reply strings.Builder
+	fmt.Fprintf(&reply, chatStatus.trn(MESSAGE_IMPORT_PREVIEW_INTRO, len(payments)-duplicates), len(payments)-duplicates, duplicates, skipped)
+	reply.WriteString("\n\n")
+	shown := 0
+	for _, payment := range payments {
+		if payment.duplicate {
+			continue
+		}
+		if shown == importPreviewMaxRows {
+			fmt.Fprintf(&reply, chatStatus.tr(MESSAGE_IMPORT_PREVIEW_MORE), len(payments)-duplicates-shown)
+			break
+		}
+		fmt.Fprintf(&reply, "%s %s %s (%s)\n", chatStatus.localDate(payment.madeTime), chatStatus.localAmount(payment.amount), payment.categoryName, payment.description)
+		shown++
+	}
+
+	chatStatus.importPayments = payments
+	chatStatus.stage = ImportInputConfirmation
+	replyExtras.ReplyOptions = []string{importConfirm, importCancel}
+
+	return reply.String()
+}
+
+// commitImport stores the payments which are not duplicates and returns their number.
+// The categories it creates are only kept if the payments are stored too
+func commitImport(ctx context.Context, h *Handler, sheetID string, author Author, imported []importedPayment) (int, error) {
+	var payments []Payment
+	err := h.storage.WithTx(ctx, func(tx Storage) error {
+		categoryIDs, err := tx.ListCategoryIDs(ctx, sheetID)
+		if err != nil {
+			return err
+		}
+
+		for _, payment := range imported {
+			if payment.duplicate {
+				continue
+			}
+
+			categoryID, ok := categoryIDs[payment.categoryName]
+			if !ok {
+				categoryID = uuid.New().String()
+				if err := tx.InsertNewCategory(ctx, sheetID, categoryID, payment.categoryName, author); err != nil {
+					return err
+				}
+				categoryIDs[payment.categoryName] = categoryID
+			}
+
+			payments = append(payments, Payment{
+				id:         uuid.New().String(),
+				categoryID: categoryID,
+				amount:     payment.amount,
+				comment:    payment.description,
+				madeTime:   payment.madeTime,
+				author:     author,
+			})
+		}
+
+		return tx.InsertPayments(ctx, sheetID, payments)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(payments), nil
+}
@@ -1,24 +1,65 @@
 package main
 
+import (
+	"context"
+	"strings"
+)
+
 func getInfoSubhandlers(h *Handler) []Subhandler {
 	return []Subhandler{
 		Subhandler{
 			expectedText:  "/start",
 			sheetOptional: true,
-			handle: func(_ string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+			withPayload:   true,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				// Invite links open the bot with "/start <token>"
+				if fields := strings.Fields(text); len(fields) > 1 {
+					return redeemInvite(ctx, h, chatStatus, fields[1])
+				}
+
 				if chatStatus.sheetID != nil {
-					return MESSAGE_START_GREETING + "\n\n" + MESSAGE_START_FULL_HELP
+					return chatStatus.tr(MESSAGE_START_GREETING) + "\n\n" + chatStatus.tr(MESSAGE_START_FULL_HELP)
 				}
 
-				return MESSAGE_START_GREETING + "\n\n" + MESSAGE_START_CONNECT + "\n\n" + MESSAGE_START_FULL_HELP
+				return chatStatus.tr(MESSAGE_START_GREETING) + "\n\n" + chatStatus.tr(MESSAGE_START_CONNECT) + "\n\n" + chatStatus.tr(MESSAGE_START_FULL_HELP)
 			},
 		},
 		Subhandler{
 			expectedText:  "/help",
 			sheetOptional: true,
-			handle: func(_ string, _ *ChatStatus, _ *ReplyExtras) string {
+			handle: func(ctx context.Context, _ string, _ *ChatStatus, _ *ReplyExtras) string {
 				return MESSAGE_HELP
 			},
 		},
+		Subhandler{
+			expectedText:  "/language",
+			sheetOptional: true,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				chatStatus.stage = SetLanguageInputLanguage
+				replyExtras.ReplyOptions = languageNames()
+
+				return MESSAGE_INPUT_LANGUAGE
+			},
+		},
+		Subhandler{
+			expectedStage: SetLanguageInputLanguage,
+			sheetOptional: true,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				language := findLanguage(text)
+				if language == nil {
+					replyExtras.ReplyOptions = languageNames()
+					return MESSAGE_INCORRECT_LANGUAGE
+				}
+
+				chatStatus.stage = None
+
+				if err := h.storage.SetChatLanguage(ctx, chatStatus.chatID, language.code); err != nil {
+					return chatStatus.serverError(err)
+				}
+				setChatLanguage(chatStatus.chatID, language.code)
+
+				return MESSAGE_SUCCESS_SET_LANGUAGE
+			},
+		},
 	}
 }
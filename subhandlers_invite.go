@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	inviteTTL = 7 * 24 * time.Hour
+	// Telegram allows up to 64 characters of [A-Za-z0-9_-] in a deep link payload
+	inviteTokenBytes = 16
+)
+
+var inviteUsesOptions = []string{"1", "5", "10"}
+
+func getInviteSubhandlers(h *Handler) []Subhandler {
+	return []Subhandler{
+		Subhandler{
+			expectedText: "/createInvite",
+			requiredRole: RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				chatStatus.stage = CreateInviteInputRole
+				replyExtras.ReplyOptions = []string{RoleEditor.String(), RoleViewer.String()}
+
+				return MESSAGE_INPUT_INVITE_ROLE
+			},
+		},
+		Subhandler{
+			expectedStage: CreateInviteInputRole,
+			requiredRole:  RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				role := parseRole(text)
+				if role != RoleEditor && role != RoleViewer {
+					return MESSAGE_INCORRECT_ROLE
+				}
+
+				chatStatus.inviteRole = role
+				chatStatus.stage = CreateInviteInputUses
+				replyExtras.ReplyOptions = inviteUsesOptions
+
+				return MESSAGE_INPUT_INVITE_USES
+			},
+		},
+		Subhandler{
+			expectedStage: CreateInviteInputUses,
+			requiredRole:  RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				uses, err := strconv.Atoi(strings.TrimSpace(text))
+				if err != nil || uses < 1 || uses > 100 {
+					return MESSAGE_INCORRECT_INVITE_USES
+				}
+
+				chatStatus.stage = None
+
+				token, err := generateInviteToken()
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				expiresAt := time.Now().Add(inviteTTL)
+				err = h.storage.InsertNewInvite(ctx, *chatStatus.sheetID, chatStatus.chatID, token, chatStatus.inviteRole, uses, expiresAt)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				return fmt.Sprintf(chatStatus.tr(MESSAGE_CREATED_INVITE), inviteLink(h, token), groupInviteLink(h, token), chatStatus.roleName(chatStatus.inviteRole), uses, chatStatus.localDateTime(expiresAt))
+			},
+		},
+		Subhandler{
+			expectedText: "/listInvites",
+			requiredRole: RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				chatStatus.stage = None
+
+				invites, err := h.storage.ListInvites(ctx, *chatStatus.sheetID, time.Now())
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				var reply strings.Builder
+				fmt.Fprintf(&reply, chatStatus.trn(MESSAGE_LIST_INVITES_INTRO, len(invites)), len(invites))
+				reply.WriteString("\n\n")
+				for i, invite := range invites {
+					fmt.Fprintf(&reply, chatStatus.tr(MESSAGE_LIST_INVITES_ITEM),
+						i+1, inviteLink(h, invite.token), chatStatus.roleName(invite.role), invite.usesLeft, chatStatus.localDateTime(invite.expiresAt))
+				}
+				reply.WriteString("\n\n")
+				reply.WriteString(chatStatus.tr(MESSAGE_LIST_INVITES_OUTRO))
+
+				return reply.String()
+			},
+		},
+		Subhandler{
+			expectedText: "/revokeInvite",
+			requiredRole: RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				invites, err := h.storage.ListInvites(ctx, *chatStatus.sheetID, time.Now())
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				if len(invites) == 0 {
+					chatStatus.stage = None
+					return MESSAGE_NO_INVITES
+				}
+				replyOptions := make([]string, len(invites))
+				for i, invite := range invites {
+					replyOptions[i] = invite.token + " (" + chatStatus.roleName(invite.role) + ")"
+				}
+				replyExtras.ReplyOptions = replyOptions
+
+				chatStatus.stage = RevokeInviteInputToken
+
+				return MESSAGE_INPUT_INVITE_TOKEN
+			},
+		},
+		Subhandler{
+			expectedStage: RevokeInviteInputToken,
+			requiredRole:  RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				token := parseInviteToken(text)
+				if token == "" {
+					return MESSAGE_INCORRECT_INVITE
+				}
+
+				chatStatus.stage = None
+
+				revoked, err := h.storage.RevokeInvite(ctx, *chatStatus.sheetID, token)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				if !revoked {
+					return MESSAGE_INCORRECT_INVITE
+				}
+
+				return MESSAGE_SUCCESS_REVOKE_INVITE
+			},
+		},
+	}
+}
+
+// redeemInvite makes the chat a member of the sheet the invite leads to and connects the chat to it
+func redeemInvite(ctx context.Context, h *Handler, chatStatus *ChatStatus, token string) string {
+	chatStatus.stage = None
+
+	token = parseInviteToken(token)
+	if token == "" {
+		return MESSAGE_INCORRECT_INVITE
+	}
+
+	now := time.Now()
+	// The use is only taken if the member is added, and concurrent redemptions can't take the same last use
+	var sheetID string
+	err := h.storage.WithTx(ctx, func(tx Storage) error {
+		sheetID = ""
+		peekedSheetID, err := tx.PeekInvite(ctx, token, now)
+		if err != nil || peekedSheetID == "" {
+			return err
+		}
+
+		// Existing members just get connected, without using the invite up or changing their role
+		role, err := tx.GetSheetMemberRole(ctx, peekedSheetID, chatStatus.chatID)
+		if err != nil {
+			return err
+		}
+		if role != RoleNone {
+			sheetID = peekedSheetID
+			return nil
+		}
+
+		redeemedSheetID, role, err := tx.RedeemInvite(ctx, token, now)
+		if err != nil || redeemedSheetID == "" {
+			return err
+		}
+		if err := tx.AddSheetMember(ctx, redeemedSheetID, chatStatus.chatID, role); err != nil {
+			return err
+		}
+		sheetID = redeemedSheetID
+		return nil
+	})
+	if err != nil {
+		return chatStatus.serverError(err)
+	}
+	if sheetID == "" {
+		return MESSAGE_INCORRECT_INVITE
+	}
+
+	return updateCurrentSheet(ctx, h, chatStatus, sheetID)
+}
+
+func generateInviteToken() (string, error) {
+	b := make([]byte, inviteTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// parseInviteToken accepts a bare token, a reply option in the "<token> (<role>)" format or the full invite link
+func parseInviteToken(text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return ""
+	}
+	token := fields[0]
+	if i := strings.LastIndex(token, "start="); i >= 0 {
+		token = token[i+len("start="):]
+	}
+	token = strings.ToLower(token)
+	if _, err := hex.DecodeString(token); err != nil || len(token) != 2*inviteTokenBytes {
+		return ""
+	}
+
+	return token
+}
+
+func inviteLink(h *Handler, token string) string {
+	return "https://t.me/" + h.botUser.UserName + "?start=" + token
+}
+
+// groupInviteLink adds the bot to a group of the user's choice, which then sends "/start@<bot> <token>" there
+func groupInviteLink(h *Handler, token string) string {
+	return "https://t.me/" + h.botUser.UserName + "?startgroup=" + token
+}
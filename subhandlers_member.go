@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func getMemberSubhandlers(h *Handler) []Subhandler {
+	return []Subhandler{
+		Subhandler{
+			expectedText: "/listMembers",
+			requiredRole: RoleViewer,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				chatStatus.stage = None
+
+				members, err := h.storage.ListSheetMembers(ctx, *chatStatus.sheetID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				var reply strings.Builder
+				fmt.Fprintf(&reply, chatStatus.trn(MESSAGE_LIST_MEMBERS_INTRO, len(members)), len(members))
+				reply.WriteString("\n\n")
+				for i, member := range members {
+					fmt.Fprintf(&reply, "%2d. %s\n", i+1, formatMember(chatStatus, member))
+				}
+				reply.WriteString("\n\n")
+				reply.WriteString(chatStatus.tr(MESSAGE_LIST_MEMBERS_OUTRO))
+
+				return reply.String()
+			},
+		},
+		Subhandler{
+			expectedText: "/setRole",
+			requiredRole: RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				if errMsg := fillMemberReplyOptions(ctx, h, chatStatus, replyExtras); errMsg != "" {
+					return errMsg
+				}
+
+				chatStatus.stage = SetMemberRoleInputMember
+
+				return MESSAGE_INPUT_MEMBER
+			},
+		},
+		Subhandler{
+			expectedStage: SetMemberRoleInputMember,
+			requiredRole:  RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				memberChatID, errMsg := parseMember(ctx, h, chatStatus, text)
+				if errMsg != "" {
+					return errMsg
+				}
+
+				chatStatus.memberChatID = memberChatID
+				chatStatus.stage = SetMemberRoleInputRole
+				replyExtras.ReplyOptions = []string{RoleEditor.String(), RoleViewer.String()}
+
+				return MESSAGE_INPUT_ROLE
+			},
+		},
+		Subhandler{
+			expectedStage: SetMemberRoleInputRole,
+			requiredRole:  RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				// There is only one owner per sheet, so the role can't be given away here
+				role := parseRole(text)
+				if role != RoleEditor && role != RoleViewer {
+					return MESSAGE_INCORRECT_ROLE
+				}
+
+				chatStatus.stage = None
+
+				if err := h.storage.UpdateSheetMemberRole(ctx, *chatStatus.sheetID, chatStatus.memberChatID, role); err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				return MESSAGE_SUCCESS_SET_ROLE
+			},
+		},
+		Subhandler{
+			expectedText: "/removeMember",
+			requiredRole: RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				if errMsg := fillMemberReplyOptions(ctx, h, chatStatus, replyExtras); errMsg != "" {
+					return errMsg
+				}
+
+				chatStatus.stage = RemoveMemberInputMember
+
+				return MESSAGE_INPUT_MEMBER
+			},
+		},
+		Subhandler{
+			expectedStage: RemoveMemberInputMember,
+			requiredRole:  RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				memberChatID, errMsg := parseMember(ctx, h, chatStatus, text)
+				if errMsg != "" {
+					return errMsg
+				}
+
+				chatStatus.stage = None
+
+				if err := h.storage.RemoveSheetMember(ctx, *chatStatus.sheetID, memberChatID); err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				return MESSAGE_SUCCESS_REMOVE_MEMBER
+			},
+		},
+	}
+}
+
+// fillMemberReplyOptions offers all the sheet members except the current chat as reply options
+func fillMemberReplyOptions(ctx context.Context, h *Handler, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+	members, err := h.storage.ListSheetMembers(ctx, *chatStatus.sheetID)
+	if err != nil {
+		return chatStatus.serverError(err)
+	}
+
+	var replyOptions []string
+	for _, member := range members {
+		if member.chatID != chatStatus.chatID {
+			replyOptions = append(replyOptions, formatMember(chatStatus, member))
+		}
+	}
+	if len(replyOptions) == 0 {
+		chatStatus.stage = None
+		return MESSAGE_NO_OTHER_MEMBERS
+	}
+	replyExtras.ReplyOptions = replyOptions
+
+	return ""
+}
+
+// formatMember shows the member by name, if it is known. The chat ID goes first for parseMember
+func formatMember(chatStatus *ChatStatus, member SheetMember) string {
+	if member.name == "" {
+		return fmt.Sprintf("%d (%s)", member.chatID, chatStatus.roleName(member.role))
+	}
+	return fmt.Sprintf("%d %s (%s)", member.chatID, member.name, chatStatus.roleName(member.role))
+}
+
+// parseMember accepts both a bare chat ID and a reply option in the "<chat ID> <name> (<role>)" format
+func parseMember(ctx context.Context, h *Handler, chatStatus *ChatStatus, text string) (int64, string) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return 0, MESSAGE_INCORRECT_MEMBER
+	}
+	memberChatID, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || memberChatID == chatStatus.chatID {
+		return 0, MESSAGE_INCORRECT_MEMBER
+	}
+
+	role, err := h.storage.GetSheetMemberRole(ctx, *chatStatus.sheetID, memberChatID)
+	if err != nil {
+		return 0, chatStatus.serverError(err)
+	}
+	if role == RoleNone || role == RoleOwner {
+		return 0, MESSAGE_INCORRECT_MEMBER
+	}
+
+	return memberChatID, ""
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
-	"regexp"
-	"strconv"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,31 +14,367 @@ func getPaymentSubhandlers(h *Handler) []Subhandler {
 	return []Subhandler{
 		// default subhandler
 		Subhandler{
-			handle: func(text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
-				re := regexp.MustCompile(`^(-?\d+(\.\d+)?)\s(.*)$`)
-				if matches := re.FindAllStringSubmatch(text, 1); matches != nil {
-					amount, _ := strconv.ParseFloat(matches[0][1], 64)
-					categoryName := matches[0][3]
+			requiredRole: RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				return createPayment(ctx, h, text, chatStatus, replyExtras)
+			},
+		},
+		Subhandler{
+			expectedStage: CreatePaymentInputCategory,
+			requiredRole:  RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				chatStatus.stage = None
 
-					categoryID, err := h.storage.FindCategory(chatStatus.sheetID, categoryName)
-					if err != nil {
-						return MESSAGE_UNEXPECTED_SERVER_ERROR
-					}
-					if len(categoryID) == 0 {
-						return MESSAGE_FAILURE_UNKNOWN_CATEGORY_NAME
-					}
+				categoryID, err := h.storage.FindCategory(ctx, chatStatus.sheetID, strings.TrimSpace(text))
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				// Not one of the suggestions, so it is most likely the next payment
+				if len(categoryID) == 0 {
+					return createPayment(ctx, h, text, chatStatus, replyExtras)
+				}
 
-					newPaymentID := uuid.New().String()
-					err = h.storage.InsertNewPayment(chatStatus.sheetID, categoryID, newPaymentID, int64(amount*100), categoryName, time.Now())
-					if err != nil {
-						return MESSAGE_UNEXPECTED_SERVER_ERROR
+				pending := chatStatus.pendingPayment
+				newPaymentID := uuid.New().String()
+				err = h.storage.InsertNewPayment(ctx, chatStatus.sheetID, categoryID, newPaymentID, pending.amount, pending.comment, pending.madeTime, chatStatus.author)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				botMetrics.paymentsRecorded.add(1, "message")
+				replyExtras.InlineButtons = paymentButtons(newPaymentID)
+
+				return MESAGE_SUCCESS_CREATE_PAYMENT
+			},
+		},
+		Subhandler{
+			expectedCallback: callbackUndoPayment,
+			requiredRole:     RoleEditor,
+			handle: func(ctx context.Context, paymentID string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				payment, err := h.storage.GetPayment(ctx, *chatStatus.sheetID, paymentID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				if payment == nil {
+					return MESSAGE_FAILURE_PAYMENT_NOT_FOUND
+				}
+
+				if err := h.storage.DeletePayment(ctx, *chatStatus.sheetID, paymentID); err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				return fmt.Sprintf(chatStatus.tr(MESSAGE_SUCCESS_UNDO_PAYMENT), chatStatus.localAmount(payment.amount), payment.categoryName)
+			},
+		},
+		Subhandler{
+			expectedCallback: callbackChangeCategory,
+			requiredRole:     RoleEditor,
+			handle: func(ctx context.Context, paymentID string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				payment, err := h.storage.GetPayment(ctx, *chatStatus.sheetID, paymentID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				if payment == nil {
+					return MESSAGE_FAILURE_PAYMENT_NOT_FOUND
+				}
+
+				chatStatus.stage = None
+				chatStatus.editPaymentID = paymentID
+
+				return chooseCategory(ctx, h, chatStatus, replyExtras, 0)
+			},
+		},
+		Subhandler{
+			expectedCallback: callbackCategoryPage,
+			requiredRole:     RoleEditor,
+			handle: func(ctx context.Context, page string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				if chatStatus.editPaymentID == "" {
+					return MESSAGE_FAILURE_EXPIRED_BUTTON
+				}
+
+				return chooseCategory(ctx, h, chatStatus, replyExtras, parsePage(page))
+			},
+		},
+		Subhandler{
+			expectedCallback: callbackSetCategory,
+			requiredRole:     RoleEditor,
+			handle: func(ctx context.Context, categoryID string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				paymentID := chatStatus.editPaymentID
+				if paymentID == "" {
+					return MESSAGE_FAILURE_EXPIRED_BUTTON
+				}
+
+				categoryIDs, err := h.storage.ListCategoryIDs(ctx, *chatStatus.sheetID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				categoryName := ""
+				for name, id := range categoryIDs {
+					if id == categoryID {
+						categoryName = name
 					}
+				}
+				if categoryName == "" {
+					return MESSAGE_FAILURE_EXPIRED_BUTTON
+				}
+
+				chatStatus.editPaymentID = ""
+
+				if err := h.storage.UpdatePaymentCategory(ctx, *chatStatus.sheetID, paymentID, categoryID); err != nil {
+					return chatStatus.serverError(err)
+				}
+				replyExtras.InlineButtons = paymentButtons(paymentID)
 
-					return MESAGE_SUCCESS_CREATE_PAYMENT
+				return fmt.Sprintf(chatStatus.tr(MESSAGE_SUCCESS_CHANGE_CATEGORY), categoryName)
+			},
+		},
+		Subhandler{
+			expectedCallback: callbackEditAmount,
+			requiredRole:     RoleEditor,
+			handle: func(ctx context.Context, paymentID string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				payment, err := h.storage.GetPayment(ctx, *chatStatus.sheetID, paymentID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				if payment == nil {
+					return MESSAGE_FAILURE_PAYMENT_NOT_FOUND
 				}
+				// The split would no longer add up to the amount
+				if payment.paidByUserID != 0 {
+					return MESSAGE_FAILURE_EDIT_SHARED_PAYMENT
+				}
+
+				chatStatus.editPaymentID = paymentID
+				chatStatus.stage = EditPaymentInputAmount
 
-				return MESSAGE_FAILURE_PARSING
+				return fmt.Sprintf(chatStatus.tr(MESSAGE_INPUT_NEW_AMOUNT), chatStatus.localAmount(payment.amount))
 			},
 		},
+		Subhandler{
+			expectedStage: EditPaymentInputAmount,
+			requiredRole:  RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				amount, rest, _, err := parsePaymentText(strings.TrimSpace(text), getLanguage(chatStatus.language))
+				if err == errAmbiguousAmount {
+					return ambiguousAmountReply(chatStatus)
+				}
+				if err != nil || rest != "" {
+					return MESSAGE_INCORRECT_AMOUNT
+				}
+
+				chatStatus.stage = None
+				paymentID := chatStatus.editPaymentID
+				chatStatus.editPaymentID = ""
+
+				if err := h.storage.UpdatePaymentAmount(ctx, *chatStatus.sheetID, paymentID, amount); err != nil {
+					return chatStatus.serverError(err)
+				}
+				replyExtras.InlineButtons = paymentButtons(paymentID)
+
+				return fmt.Sprintf(chatStatus.tr(MESSAGE_SUCCESS_EDIT_AMOUNT), chatStatus.localAmount(amount))
+			},
+		},
+	}
+}
+
+const (
+	callbackUndoPayment    = "undo"
+	callbackChangeCategory = "chcat"
+	callbackCategoryPage   = "catpage"
+	callbackSetCategory    = "setcat"
+	callbackEditAmount     = "editamt"
+)
+
+// paymentButtons are shown under every payment confirmation
+func paymentButtons(paymentID string) [][]InlineButton {
+	return [][]InlineButton{{
+		{Text: MESSAGE_BUTTON_UNDO, Data: callbackData(callbackUndoPayment, paymentID)},
+		{Text: MESSAGE_BUTTON_CHANGE_CATEGORY, Data: callbackData(callbackChangeCategory, paymentID)},
+		{Text: MESSAGE_BUTTON_EDIT_AMOUNT, Data: callbackData(callbackEditAmount, paymentID)},
+	}}
+}
+
+// chooseCategory offers a page of the sheet categories for the payment in editPaymentID
+func chooseCategory(ctx context.Context, h *Handler, chatStatus *ChatStatus, replyExtras *ReplyExtras, page int) string {
+	categoryIDs, err := h.storage.ListCategoryIDs(ctx, *chatStatus.sheetID)
+	if err != nil {
+		return chatStatus.serverError(err)
+	}
+	names := make([]string, 0, len(categoryIDs))
+	for name := range categoryIDs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	from, to, page := paginate(len(names), page)
+	for _, name := range names[from:to] {
+		replyExtras.InlineButtons = append(replyExtras.InlineButtons, []InlineButton{{Text: name, Data: callbackData(callbackSetCategory, categoryIDs[name])}})
+	}
+	if navigation := pageNavigation(len(names), page, callbackCategoryPage); navigation != nil {
+		replyExtras.InlineButtons = append(replyExtras.InlineButtons, navigation)
+	}
+
+	return fmt.Sprintf(chatStatus.tr(MESSAGE_INPUT_NEW_CATEGORY), page+1, pageCount(len(names)))
+}
+
+// createPayment handles the "<amount> <category>" quick entry. If the text after the amount is not a category,
+// the categorization rules are tried, and if none of them matches, the likely categories are suggested
+func createPayment(ctx context.Context, h *Handler, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+	if lines := paymentLines(text); len(lines) > 1 {
+		return createPayments(ctx, h, lines, chatStatus)
+	}
+
+	amount, categoryName, expression, err := parsePaymentText(text, getLanguage(chatStatus.language))
+	if err == errAmbiguousAmount {
+		return ambiguousAmountReply(chatStatus)
+	}
+	if err != nil {
+		return MESSAGE_FAILURE_PARSING
+	}
+
+	successMessage := MESAGE_SUCCESS_CREATE_PAYMENT
+
+	categoryID, err := h.storage.FindCategory(ctx, chatStatus.sheetID, categoryName)
+	if err != nil {
+		return chatStatus.serverError(err)
+	}
+	if len(categoryID) == 0 {
+		// The text is not a category name, so it may be a description one of the rules knows
+		rules, err := h.storage.ListRules(ctx, *chatStatus.sheetID)
+		if err != nil {
+			return chatStatus.serverError(err)
+		}
+		ruleCategoryName := categorizeByRules(rules, categoryName, amount)
+		if ruleCategoryName == "" {
+			reply := suggestCategories(ctx, h, chatStatus, replyExtras, Payment{amount: amount, comment: categoryName, madeTime: time.Now()})
+			return withComputedAmount(chatStatus, reply, expression, amount)
+		}
+
+		categoryID, err = h.storage.FindCategory(ctx, chatStatus.sheetID, ruleCategoryName)
+		if err != nil {
+			return chatStatus.serverError(err)
+		}
+		successMessage = fmt.Sprintf(chatStatus.tr(MESSAGE_SUCCESS_CREATE_PAYMENT_BY_RULE), ruleCategoryName)
+	}
+
+	newPaymentID := uuid.New().String()
+	err = h.storage.InsertNewPayment(ctx, chatStatus.sheetID, categoryID, newPaymentID, amount, categoryName, time.Now(), chatStatus.author)
+	if err != nil {
+		return chatStatus.serverError(err)
+	}
+	botMetrics.paymentsRecorded.add(1, "message")
+	replyExtras.InlineButtons = paymentButtons(newPaymentID)
+
+	return withComputedAmount(chatStatus, successMessage, expression, amount)
+}
+
+func paymentLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// createPayments handles a message of several "<amount> <category>" lines. The payments are only saved
+// if every line is understood, so that sending the message again with the mistakes fixed adds no duplicates
+func createPayments(ctx context.Context, h *Handler, lines []string, chatStatus *ChatStatus) string {
+	var reply string
+	// Only counted once the transaction is committed
+	inserted := 0
+	err := h.storage.WithTx(ctx, func(tx Storage) error {
+		inserted = 0
+		rules, err := tx.ListRules(ctx, *chatStatus.sheetID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var payments []Payment
+		var summary strings.Builder
+		failed := 0
+		for i, line := range lines {
+			payment, errMsg, err := batchPayment(ctx, tx, chatStatus, rules, line)
+			if err != nil {
+				return err
+			}
+			if errMsg != "" {
+				failed++
+				fmt.Fprintf(&summary, "%d. %s: %s\n", i+1, line, chatStatus.tr(errMsg))
+				continue
+			}
+
+			payment.id = uuid.New().String()
+			payment.madeTime = now
+			payment.author = chatStatus.author
+			payments = append(payments, payment)
+			fmt.Fprintf(&summary, "%d. %s %s\n", i+1, chatStatus.localAmount(payment.amount), payment.categoryName)
+		}
+
+		if failed > 0 {
+			reply = fmt.Sprintf(chatStatus.trn(MESSAGE_FAILURE_CREATE_PAYMENTS, failed), failed) + "\n\n" + summary.String()
+			return nil
+		}
+		if err := tx.InsertPayments(ctx, *chatStatus.sheetID, payments); err != nil {
+			return err
+		}
+		inserted = len(payments)
+		reply = fmt.Sprintf(chatStatus.trn(MESSAGE_SUCCESS_CREATE_PAYMENTS, len(payments)), len(payments)) + "\n\n" + summary.String()
+		return nil
+	})
+	if err != nil {
+		return chatStatus.serverError(err)
+	}
+	botMetrics.paymentsRecorded.add(float64(inserted), "batch")
+	return reply
+}
+
+// batchPayment finds the category of a line the same way a single payment does, but without suggestions.
+// errMsg tells what is wrong with the line
+func batchPayment(ctx context.Context, storage Storage, chatStatus *ChatStatus, rules []*CategorizationRule, line string) (Payment, string, error) {
+	amount, text, _, err := parsePaymentText(line, getLanguage(chatStatus.language))
+	if err == errAmbiguousAmount {
+		return Payment{}, MESSAGE_BATCH_AMBIGUOUS_AMOUNT, nil
+	}
+	if err != nil {
+		return Payment{}, MESSAGE_BATCH_INCORRECT_LINE, nil
+	}
+
+	categoryName := text
+	categoryID, err := storage.FindCategory(ctx, chatStatus.sheetID, categoryName)
+	if err != nil {
+		return Payment{}, "", err
 	}
+	if len(categoryID) == 0 {
+		categoryName = categorizeByRules(rules, text, amount)
+		if categoryName == "" {
+			return Payment{}, MESSAGE_FAILURE_UNKNOWN_CATEGORY_NAME, nil
+		}
+		if categoryID, err = storage.FindCategory(ctx, chatStatus.sheetID, categoryName); err != nil {
+			return Payment{}, "", err
+		}
+	}
+
+	return Payment{categoryID: categoryID, categoryName: categoryName, amount: amount, comment: text}, "", nil
+}
+
+// suggestCategories offers the categories the sheet history makes most likely and keeps the payment until one is chosen
+func suggestCategories(ctx context.Context, h *Handler, chatStatus *ChatStatus, replyExtras *ReplyExtras, payment Payment) string {
+	now := time.Now()
+	history, err := h.storage.ListPayments(ctx, *chatStatus.sheetID, now.Add(-suggestionHistory), now.Add(time.Minute))
+	if err != nil {
+		return chatStatus.serverError(err)
+	}
+
+	suggestions := trainCategoryModel(history).suggest(payment.comment, payment.amount, payment.madeTime, suggestedCategoriesCount)
+	if len(suggestions) == 0 {
+		return MESSAGE_FAILURE_UNKNOWN_CATEGORY_NAME
+	}
+
+	chatStatus.pendingPayment = payment
+	chatStatus.stage = CreatePaymentInputCategory
+	replyExtras.ReplyOptions = suggestions
+
+	return MESSAGE_INPUT_SUGGESTED_CATEGORY
 }
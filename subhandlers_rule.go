@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// How many matching payments /testRule shows
+const ruleTestMaxRows = 10
+
+func getRuleSubhandlers(h *Handler) []Subhandler {
+	return []Subhandler{
+		Subhandler{
+			expectedText: "/listRules",
+			requiredRole: RoleViewer,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				chatStatus.stage = None
+
+				rules, err := h.storage.ListRules(ctx, *chatStatus.sheetID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				var reply strings.Builder
+				fmt.Fprintf(&reply, chatStatus.trn(MESSAGE_LIST_RULES_INTRO, len(rules)), len(rules))
+				reply.WriteString("\n\n")
+				for _, rule := range rules {
+					fmt.Fprintf(&reply, "%2d. %s\n", rule.position, rule)
+				}
+				reply.WriteString("\n\n")
+				reply.WriteString(chatStatus.tr(MESSAGE_LIST_RULES_OUTRO))
+
+				return reply.String()
+			},
+		},
+		Subhandler{
+			expectedText: "/addRule",
+			requiredRole: RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				chatStatus.stage = AddRuleInputRule
+
+				return MESSAGE_INPUT_RULE
+			},
+		},
+		Subhandler{
+			expectedStage: AddRuleInputRule,
+			requiredRole:  RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				rule, err := parseRule(text)
+				if err != nil {
+					return fmt.Sprintf(chatStatus.tr(MESSAGE_INCORRECT_RULE), err)
+				}
+
+				categoryID, err := h.storage.FindCategory(ctx, chatStatus.sheetID, rule.categoryName)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				if len(categoryID) == 0 {
+					return MESSAGE_FAILURE_UNKNOWN_CATEGORY_NAME
+				}
+
+				chatStatus.stage = None
+
+				if err := h.storage.InsertNewRule(ctx, *chatStatus.sheetID, uuid.New().String(), rule.conditionsText, categoryID); err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				return MESSAGE_SUCCESS_ADD_RULE
+			},
+		},
+		Subhandler{
+			expectedText: "/moveRule",
+			requiredRole: RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				chatStatus.stage = MoveRuleInputPositions
+
+				return MESSAGE_INPUT_RULE_POSITIONS
+			},
+		},
+		Subhandler{
+			expectedStage: MoveRuleInputPositions,
+			requiredRole:  RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				fields := strings.Fields(text)
+				if len(fields) != 2 {
+					return MESSAGE_INCORRECT_RULE_POSITIONS
+				}
+				from, errFrom := strconv.Atoi(fields[0])
+				to, errTo := strconv.Atoi(fields[1])
+				if errFrom != nil || errTo != nil {
+					return MESSAGE_INCORRECT_RULE_POSITIONS
+				}
+
+				rules, err := h.storage.ListRules(ctx, *chatStatus.sheetID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				if from < 1 || from > len(rules) || to < 1 || to > len(rules) {
+					return MESSAGE_INCORRECT_RULE_POSITIONS
+				}
+
+				chatStatus.stage = None
+
+				if err := h.storage.MoveRule(ctx, *chatStatus.sheetID, from, to); err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				return MESSAGE_SUCCESS_MOVE_RULE
+			},
+		},
+		Subhandler{
+			expectedText: "/deleteRule",
+			requiredRole: RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				chatStatus.stage = DeleteRuleInputPosition
+
+				return MESSAGE_INPUT_RULE_POSITION
+			},
+		},
+		Subhandler{
+			expectedStage: DeleteRuleInputPosition,
+			requiredRole:  RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				position, err := strconv.Atoi(strings.TrimSpace(text))
+				if err != nil {
+					return MESSAGE_INCORRECT_RULE_POSITION
+				}
+
+				chatStatus.stage = None
+
+				deleted, err := h.storage.DeleteRule(ctx, *chatStatus.sheetID, position)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				if !deleted {
+					return MESSAGE_INCORRECT_RULE_POSITION
+				}
+
+				return MESSAGE_SUCCESS_DELETE_RULE
+			},
+		},
+		Subhandler{
+			expectedText: "/testRule",
+			requiredRole: RoleViewer,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				chatStatus.stage = TestRuleInputRule
+
+				return MESSAGE_INPUT_RULE
+			},
+		},
+		Subhandler{
+			expectedStage: TestRuleInputRule,
+			requiredRole:  RoleViewer,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				rule, err := parseRule(text)
+				if err != nil {
+					return fmt.Sprintf(chatStatus.tr(MESSAGE_INCORRECT_RULE), err)
+				}
+
+				chatStatus.stage = None
+
+				from, to, _ := parseDateRange(dateRangeAllTime, time.Now())
+				payments, err := h.storage.ListPayments(ctx, *chatStatus.sheetID, from, to)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				var matching []Payment
+				recategorized := 0
+				for _, payment := range payments {
+					if rule.matches(payment.comment, payment.amount) {
+						matching = append(matching, payment)
+						if normalizeText(payment.categoryName) != normalizeText(rule.categoryName) {
+							recategorized++
+						}
+					}
+				}
+
+				var reply strings.Builder
+				fmt.Fprintf(&reply, chatStatus.tr(MESSAGE_TEST_RULE_INTRO), len(matching), len(payments), recategorized)
+				reply.WriteString("\n\n")
+				// Most recent payments are the most relevant ones
+				for i := len(matching) - 1; i >= 0 && i >= len(matching)-ruleTestMaxRows; i-- {
+					payment := matching[i]
+					fmt.Fprintf(&reply, "%s %s %s (%s)\n", chatStatus.localDate(payment.madeTime), chatStatus.localAmount(payment.amount), payment.categoryName, payment.comment)
+				}
+
+				return reply.String()
+			},
+		},
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -12,7 +14,7 @@ func getSheetSubhandlers(h *Handler) []Subhandler {
 		Subhandler{
 			expectedText:  "/createSheet",
 			sheetOptional: true,
-			handle: func(text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
 				chatStatus.stage = CreateSheetInputName
 
 				return MESSAGE_INPUT_NEW_SHEET_NAME
@@ -21,7 +23,7 @@ func getSheetSubhandlers(h *Handler) []Subhandler {
 		Subhandler{
 			expectedStage: CreateSheetInputName,
 			sheetOptional: true,
-			handle: func(name string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+			handle: func(ctx context.Context, name string, chatStatus *ChatStatus, _ *ReplyExtras) string {
 				if errMsg := validateNewSheetName(name); errMsg != "" {
 					return errMsg
 				}
@@ -35,36 +37,40 @@ func getSheetSubhandlers(h *Handler) []Subhandler {
 		Subhandler{
 			expectedStage: CreateSheetInputPassword,
 			sheetOptional: true,
-			handle: func(password string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+			handle: func(ctx context.Context, password string, chatStatus *ChatStatus, _ *ReplyExtras) string {
 				if errMsg := validateNewSheetPassword(password); errMsg != "" {
 					return errMsg
 				}
 
 				newSheetID := uuid.New().String()
 
-				err := h.storage.InsertNewSheet(chatStatus.chatID, newSheetID, chatStatus.newSheetName, password)
+				// Without the owner or the connection the new sheet would be left unreachable
+				err := h.storage.WithTx(ctx, func(tx Storage) error {
+					if err := tx.InsertNewSheet(ctx, chatStatus.chatID, newSheetID, chatStatus.newSheetName, password); err != nil {
+						return err
+					}
+					if err := tx.AddSheetMember(ctx, newSheetID, chatStatus.chatID, RoleOwner); err != nil {
+						return err
+					}
+					return tx.ConnectToSheet(ctx, chatStatus.chatID, newSheetID)
+				})
 				if err != nil {
-					return MESSAGE_UNEXPECTED_SERVER_ERROR
+					return chatStatus.serverError(err)
 				}
 
-				err = h.storage.ConnectToSheet(chatStatus.chatID, newSheetID)
-				if err != nil {
-					return MESSAGE_UNEXPECTED_SERVER_ERROR
-				}
-
-				chatStatus.sheetID = &newSheetID
+				setChatSheet(chatStatus.chatID, &newSheetID)
 				chatStatus.stage = None
 
-				return fmt.Sprintf(MESSAGE_CREATED_NEW_SHEET, chatStatus.newSheetName, newSheetID)
+				return fmt.Sprintf(chatStatus.tr(MESSAGE_CREATED_NEW_SHEET), chatStatus.newSheetName, newSheetID)
 			},
 		},
 		Subhandler{
 			expectedText:  "/connectSheet",
 			sheetOptional: true,
-			handle: func(text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
-				sheets, err := h.storage.ListSheets(chatStatus.chatID)
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				sheets, err := h.storage.ListSheets(ctx, chatStatus.chatID)
 				if err != nil {
-					return MESSAGE_UNEXPECTED_SERVER_ERROR
+					return chatStatus.serverError(err)
 				}
 				replyOptions := make([]string, len(sheets))
 				for i, sheet := range sheets {
@@ -80,7 +86,7 @@ func getSheetSubhandlers(h *Handler) []Subhandler {
 		Subhandler{
 			expectedStage: ConnectToSheetInputID,
 			sheetOptional: true,
-			handle: func(connectToSheetID string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+			handle: func(ctx context.Context, connectToSheetID string, chatStatus *ChatStatus, _ *ReplyExtras) string {
 				connectToSheetID = strings.TrimSpace(connectToSheetID)
 				if len(connectToSheetID) == 0 {
 					return MESSAGE_INCORRECT_SHEET_ID_FORMAT
@@ -91,10 +97,14 @@ func getSheetSubhandlers(h *Handler) []Subhandler {
 					return MESSAGE_INCORRECT_SHEET_ID_FORMAT
 				}
 
-				// If the sheet belongs to the current chat, no need to ask for password
-				ownerChatID, err := h.storage.GetSheetOwnerChatID(connectToSheetID)
-				if err == nil && ownerChatID == chatStatus.chatID {
-					return updateCurrentSheet(h, chatStatus, connectToSheetID)
+				// If the current chat is already a member of the sheet, no need to ask for password
+				role, err := h.storage.GetSheetMemberRole(ctx, connectToSheetID, chatStatus.chatID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				if role != RoleNone {
+					chatStatus.stage = None
+					return updateCurrentSheet(ctx, h, chatStatus, connectToSheetID)
 				}
 
 				chatStatus.connectToSheetID = connectToSheetID
@@ -106,11 +116,41 @@ func getSheetSubhandlers(h *Handler) []Subhandler {
 		Subhandler{
 			expectedStage: ConnectToSheetInputPassword,
 			sheetOptional: true,
-			handle: func(password string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+			handle: func(ctx context.Context, password string, chatStatus *ChatStatus, _ *ReplyExtras) string {
 				chatStatus.stage = None
 
-				if h.storage.CheckPassword(chatStatus.connectToSheetID, password) {
-					return updateCurrentSheet(h, chatStatus, chatStatus.connectToSheetID)
+				// The chat may have joined with an invite meanwhile, members are never locked out by the failures of others
+				role, err := h.storage.GetSheetMemberRole(ctx, chatStatus.connectToSheetID, chatStatus.chatID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				if role != RoleNone {
+					return updateCurrentSheet(ctx, h, chatStatus, chatStatus.connectToSheetID)
+				}
+
+				now := time.Now()
+				if blockedTill := h.passwordAttempts.blockedTill(chatStatus.chatID, chatStatus.connectToSheetID, now); now.Before(blockedTill) {
+					return fmt.Sprintf(chatStatus.tr(MESSAGE_TOO_MANY_PASSWORD_ATTEMPTS), blockedTill.Sub(now).Round(time.Second))
+				}
+
+				ok, err := h.storage.CheckPassword(ctx, chatStatus.connectToSheetID, password)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				if ok {
+					h.passwordAttempts.recordSuccess(chatStatus.chatID, chatStatus.connectToSheetID, now)
+
+					// Knowing the password grants the same access it used to grant before roles were introduced
+					if err := h.storage.AddSheetMember(ctx, chatStatus.connectToSheetID, chatStatus.chatID, RoleEditor); err != nil {
+						return chatStatus.serverError(err)
+					}
+					return updateCurrentSheet(ctx, h, chatStatus, chatStatus.connectToSheetID)
+				}
+
+				if failures := h.passwordAttempts.recordFailure(chatStatus.chatID, chatStatus.connectToSheetID, now); failures%notifyOwnerEveryFailedAttempts == 0 {
+					if ownerChatID, err := h.storage.GetSheetOwnerChatID(ctx, chatStatus.connectToSheetID); err == nil {
+						h.notifyChat(ownerChatID, fmt.Sprintf(translate(h.chatLanguage(ctx, ownerChatID), MESSAGE_NOTIFY_FAILED_PASSWORD_ATTEMPTS), failures, chatStatus.connectToSheetID))
+					}
 				}
 
 				return MESSAGE_INCORRECT_PASSWORD
@@ -119,12 +159,12 @@ func getSheetSubhandlers(h *Handler) []Subhandler {
 		Subhandler{
 			expectedText:  "/disconnectSheet",
 			sheetOptional: true,
-			handle: func(text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
-				chatStatus.sheetID = nil
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				setChatSheet(chatStatus.chatID, nil)
 				chatStatus.stage = None
 
-				if err := h.storage.DisconnectFromSheet(chatStatus.chatID); err != nil {
-					return MESSAGE_UNEXPECTED_SERVER_ERROR
+				if err := h.storage.DisconnectFromSheet(ctx, chatStatus.chatID); err != nil {
+					return chatStatus.serverError(err)
 				}
 
 				return MESSAGE_SUCCESS_DISCONNECT_SHEET
@@ -133,35 +173,209 @@ func getSheetSubhandlers(h *Handler) []Subhandler {
 		Subhandler{
 			expectedText:  "/listSheets",
 			sheetOptional: true,
-			handle: func(text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
 				chatStatus.stage = None
 
-				sheets, err := h.storage.ListSheets(chatStatus.chatID)
+				sheets, err := h.storage.ListSheets(ctx, chatStatus.chatID)
 				if err != nil {
-					return MESSAGE_UNEXPECTED_SERVER_ERROR
+					return chatStatus.serverError(err)
 				}
 
 				var reply strings.Builder
-				fmt.Fprintf(&reply, MESSAGE_LIST_SHEETS_INTRO, len(sheets))
+				fmt.Fprintf(&reply, chatStatus.trn(MESSAGE_LIST_SHEETS_INTRO, len(sheets)), len(sheets))
 				reply.WriteString("\n\n")
 				for i, sheet := range sheets {
-					fmt.Fprintf(&reply, "%2d. Name: %s\n    ID: %s\n\n", i+1, sheet.name, sheet.id)
+					fmt.Fprintf(&reply, chatStatus.tr(MESSAGE_LIST_SHEETS_ITEM), i+1, sheet.name, sheet.id, chatStatus.roleName(sheet.role))
 				}
 				reply.WriteString("\n\n")
-				reply.WriteString(MESSAGE_LIST_SHEETS_OUTRO)
+				reply.WriteString(chatStatus.tr(MESSAGE_LIST_SHEETS_OUTRO))
 				return reply.String()
 			},
 		},
+		Subhandler{
+			expectedText: "/renameSheet",
+			requiredRole: RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				chatStatus.stage = RenameSheetInputName
+
+				return MESSAGE_INPUT_NEW_SHEET_NAME
+			},
+		},
+		Subhandler{
+			expectedStage: RenameSheetInputName,
+			requiredRole:  RoleOwner,
+			handle: func(ctx context.Context, name string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				if errMsg := validateNewSheetName(name); errMsg != "" {
+					return errMsg
+				}
+
+				chatStatus.stage = None
+
+				if err := h.storage.RenameSheet(ctx, *chatStatus.sheetID, name); err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				return MESSAGE_SUCCESS_RENAME_SHEET
+			},
+		},
+		Subhandler{
+			expectedText: "/changePassword",
+			requiredRole: RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				chatStatus.stage = ChangeSheetPasswordInputPassword
+
+				return MESSAGE_INPUT_NEW_SHEET_PASSWORD
+			},
+		},
+		Subhandler{
+			expectedStage: ChangeSheetPasswordInputPassword,
+			requiredRole:  RoleOwner,
+			handle: func(ctx context.Context, password string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				if errMsg := validateNewSheetPassword(password); errMsg != "" {
+					return errMsg
+				}
+
+				chatStatus.stage = None
+
+				if err := h.storage.UpdatePassword(ctx, *chatStatus.sheetID, password); err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				return MESSAGE_SUCCESS_CHANGE_SHEET_PASSWORD
+			},
+		},
+		Subhandler{
+			expectedText: "/setCurrency",
+			requiredRole: RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				chatStatus.stage = SetSheetCurrencyInputCurrency
+
+				return MESSAGE_INPUT_SHEET_CURRENCY
+			},
+		},
+		Subhandler{
+			expectedStage: SetSheetCurrencyInputCurrency,
+			requiredRole:  RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				currency := strings.ToUpper(strings.TrimSpace(text))
+				if !validateCurrency(currency) {
+					return MESSAGE_INCORRECT_SHEET_CURRENCY
+				}
+
+				chatStatus.stage = None
+
+				if err := h.storage.UpdateSheetCurrency(ctx, *chatStatus.sheetID, currency); err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				return MESSAGE_SUCCESS_SET_SHEET_CURRENCY
+			},
+		},
+		Subhandler{
+			expectedText: "/setFundingAccount",
+			requiredRole: RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				chatStatus.stage = SetSheetFundingAccountInputAccount
+
+				return MESSAGE_INPUT_SHEET_FUNDING_ACCOUNT
+			},
+		},
+		Subhandler{
+			expectedStage: SetSheetFundingAccountInputAccount,
+			requiredRole:  RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				account := strings.TrimSpace(text)
+				if !validateFundingAccount(account) {
+					return MESSAGE_INCORRECT_SHEET_FUNDING_ACCOUNT
+				}
+
+				chatStatus.stage = None
+
+				if err := h.storage.UpdateSheetFundingAccount(ctx, *chatStatus.sheetID, account); err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				return MESSAGE_SUCCESS_SET_SHEET_FUNDING_ACCOUNT
+			},
+		},
+		Subhandler{
+			expectedText: "/transferOwnership",
+			requiredRole: RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				if errMsg := fillMemberReplyOptions(ctx, h, chatStatus, replyExtras); errMsg != "" {
+					return errMsg
+				}
+
+				chatStatus.stage = TransferOwnershipInputMember
+
+				return MESSAGE_INPUT_NEW_OWNER
+			},
+		},
+		Subhandler{
+			expectedStage: TransferOwnershipInputMember,
+			requiredRole:  RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				newOwnerChatID, errMsg := parseMember(ctx, h, chatStatus, text)
+				if errMsg != "" {
+					return errMsg
+				}
+
+				chatStatus.stage = None
+
+				if err := h.storage.TransferOwnership(ctx, *chatStatus.sheetID, chatStatus.chatID, newOwnerChatID); err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				return MESSAGE_SUCCESS_TRANSFER_OWNERSHIP
+			},
+		},
+		Subhandler{
+			expectedText: "/deleteSheet",
+			requiredRole: RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				name, err := h.storage.GetSheetName(ctx, *chatStatus.sheetID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				chatStatus.stage = DeleteSheetInputConfirmation
+
+				return fmt.Sprintf(chatStatus.tr(MESSAGE_INPUT_DELETE_SHEET_CONFIRMATION), name)
+			},
+		},
+		Subhandler{
+			expectedStage: DeleteSheetInputConfirmation,
+			requiredRole:  RoleOwner,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				chatStatus.stage = None
+
+				name, err := h.storage.GetSheetName(ctx, *chatStatus.sheetID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				if strings.TrimSpace(text) != name {
+					return MESSAGE_CANCELLED_DELETE_SHEET
+				}
+
+				// Other chats connected to the sheet notice it is gone by losing their membership
+				if err := h.storage.DeleteSheet(ctx, *chatStatus.sheetID); err != nil {
+					return chatStatus.serverError(err)
+				}
+				setChatSheet(chatStatus.chatID, nil)
+
+				return MESSAGE_SUCCESS_DELETE_SHEET
+			},
+		},
 	}
 }
 
-func updateCurrentSheet(h *Handler, chatStatus *ChatStatus, sheetID string) string {
-	err := h.storage.ConnectToSheet(chatStatus.chatID, chatStatus.connectToSheetID)
+func updateCurrentSheet(ctx context.Context, h *Handler, chatStatus *ChatStatus, sheetID string) string {
+	err := h.storage.ConnectToSheet(ctx, chatStatus.chatID, sheetID)
 	if err != nil {
-		return MESSAGE_UNEXPECTED_SERVER_ERROR
+		return chatStatus.serverError(err)
 	}
 
-	chatStatus.sheetID = &sheetID
+	setChatSheet(chatStatus.chatID, &sheetID)
 	return MESSAGE_SUCCESS_CONNECT_TO_SHEET
 }
 
@@ -174,6 +388,10 @@ func validateNewSheetPassword(password string) string {
 		return MESSAGE_INCORRECT_NEW_PASSWORD_TOO_SHORT
 	}
 
+	if len(password) > maxPasswordBytes {
+		return MESSAGE_INCORRECT_NEW_PASSWORD_TOO_LONG
+	}
+
 	return ""
 }
 
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	splitEquallyMode = MESSAGE_OPTION_SPLIT_EQUALLY
+	splitSharesMode  = MESSAGE_OPTION_SPLIT_BY_SHARES
+	splitAmountsMode = MESSAGE_OPTION_SPLIT_BY_AMOUNTS
+	splitMe          = "me"
+	settleConfirm    = MESSAGE_OPTION_CONFIRM
+	settleCancel     = MESSAGE_OPTION_CANCEL
+)
+
+func getSplitSubhandlers(h *Handler) []Subhandler {
+	return []Subhandler{
+		Subhandler{
+			expectedText: "/addShared",
+			requiredRole: RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				chatStatus.stage = AddSharedPaymentInputPayment
+
+				return MESSAGE_INPUT_SHARED_PAYMENT
+			},
+		},
+		Subhandler{
+			expectedStage: AddSharedPaymentInputPayment,
+			requiredRole:  RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				amount, categoryName, _, err := parsePaymentText(strings.TrimSpace(text), getLanguage(chatStatus.language))
+				if err == errAmbiguousAmount {
+					return ambiguousAmountReply(chatStatus)
+				}
+				if err != nil {
+					return MESSAGE_INCORRECT_SHARED_PAYMENT
+				}
+
+				categoryID, err := h.storage.FindCategory(ctx, chatStatus.sheetID, categoryName)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				if len(categoryID) == 0 {
+					return MESSAGE_FAILURE_UNKNOWN_CATEGORY_NAME
+				}
+
+				participants, names, err := listParticipants(ctx, h, *chatStatus.sheetID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				replyOptions := []string{splitMe}
+				for _, participant := range participants {
+					if participant != chatStatus.author.userID {
+						replyOptions = append(replyOptions, formatSplitMember(chatStatus, names, participant))
+					}
+				}
+
+				chatStatus.pendingPayment = Payment{categoryID: categoryID, categoryName: categoryName, amount: amount, comment: categoryName, madeTime: time.Now(), author: chatStatus.author}
+				chatStatus.stage = AddSharedPaymentInputPayer
+				replyExtras.ReplyOptions = replyOptions
+
+				return MESSAGE_INPUT_SHARED_PAYER
+			},
+		},
+		Subhandler{
+			expectedStage: AddSharedPaymentInputPayer,
+			requiredRole:  RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				payer, errMsg := parseSplitMember(ctx, h, chatStatus, text)
+				if errMsg != "" {
+					return errMsg
+				}
+
+				chatStatus.sharedPaidBy = payer
+				chatStatus.stage = AddSharedPaymentInputSplitMode
+				replyExtras.ReplyOptions = []string{splitEquallyMode, splitSharesMode, splitAmountsMode}
+
+				return MESSAGE_INPUT_SPLIT_MODE
+			},
+		},
+		Subhandler{
+			expectedStage: AddSharedPaymentInputSplitMode,
+			requiredRole:  RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				members, names, err := listParticipants(ctx, h, *chatStatus.sheetID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				switch normalizeText(text) {
+				case normalizeText(splitEquallyMode):
+					return insertSharedPayment(ctx, h, chatStatus, replyExtras, names, splitEqually(chatStatus.pendingPayment.amount, members))
+				case normalizeText(splitSharesMode):
+					chatStatus.stage = AddSharedPaymentInputShares
+					return fmt.Sprintf(chatStatus.tr(MESSAGE_INPUT_SPLIT_SHARES), formatSplitMembers(chatStatus, names, members))
+				case normalizeText(splitAmountsMode):
+					chatStatus.stage = AddSharedPaymentInputAmounts
+					return fmt.Sprintf(chatStatus.tr(MESSAGE_INPUT_SPLIT_AMOUNTS), chatStatus.localAmount(chatStatus.pendingPayment.amount), formatSplitMembers(chatStatus, names, members))
+				}
+
+				return MESSAGE_INCORRECT_SPLIT_MODE
+			},
+		},
+		Subhandler{
+			expectedStage: AddSharedPaymentInputShares,
+			requiredRole:  RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				members, names, err := listParticipants(ctx, h, *chatStatus.sheetID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				shares, err := parseSplitValues(text, chatStatus.author.userID, members, parseShare)
+				if err != nil {
+					return fmt.Sprintf(chatStatus.tr(MESSAGE_INCORRECT_SPLIT), err)
+				}
+				split, err := splitByShares(chatStatus.pendingPayment.amount, shares)
+				if err != nil {
+					return fmt.Sprintf(chatStatus.tr(MESSAGE_INCORRECT_SPLIT), err)
+				}
+
+				return insertSharedPayment(ctx, h, chatStatus, replyExtras, names, split)
+			},
+		},
+		Subhandler{
+			expectedStage: AddSharedPaymentInputAmounts,
+			requiredRole:  RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				members, names, err := listParticipants(ctx, h, *chatStatus.sheetID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				split, err := parseSplitValues(text, chatStatus.author.userID, members, parseStatementAmount)
+				if err != nil {
+					return fmt.Sprintf(chatStatus.tr(MESSAGE_INCORRECT_SPLIT), err)
+				}
+				if err := checkExactSplit(chatStatus.pendingPayment.amount, split); err != nil {
+					return fmt.Sprintf(chatStatus.tr(MESSAGE_INCORRECT_SPLIT), err)
+				}
+
+				return insertSharedPayment(ctx, h, chatStatus, replyExtras, names, split)
+			},
+		},
+		Subhandler{
+			expectedText: "/balances",
+			requiredRole: RoleViewer,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				chatStatus.stage = None
+
+				balances, err := h.storage.GetBalances(ctx, *chatStatus.sheetID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				members, names, err := listParticipants(ctx, h, *chatStatus.sheetID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				var reply strings.Builder
+				reply.WriteString(chatStatus.tr(MESSAGE_LIST_BALANCES_INTRO))
+				reply.WriteString("\n\n")
+				for _, member := range members {
+					fmt.Fprintf(&reply, "%s: %s\n", formatSplitMember(chatStatus, names, member), chatStatus.localAmount(balances[member]))
+				}
+				reply.WriteString("\n\n")
+				reply.WriteString(chatStatus.tr(MESSAGE_LIST_BALANCES_OUTRO))
+
+				return reply.String()
+			},
+		},
+		Subhandler{
+			expectedText: "/settle",
+			requiredRole: RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, replyExtras *ReplyExtras) string {
+				chatStatus.stage = None
+
+				balances, err := h.storage.GetBalances(ctx, *chatStatus.sheetID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+				transfers := settleUp(balances)
+				if len(transfers) == 0 {
+					return MESSAGE_NOTHING_TO_SETTLE
+				}
+				_, names, err := listParticipants(ctx, h, *chatStatus.sheetID)
+				if err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				var reply strings.Builder
+				reply.WriteString(chatStatus.tr(MESSAGE_SETTLE_INTRO))
+				reply.WriteString("\n\n")
+				for _, transfer := range transfers {
+					fmt.Fprintf(&reply, "%s -> %s: %s\n", formatSplitMember(chatStatus, names, transfer.fromUserID), formatSplitMember(chatStatus, names, transfer.toUserID), chatStatus.localAmount(transfer.amount))
+				}
+				reply.WriteString("\n\n")
+				reply.WriteString(chatStatus.tr(MESSAGE_SETTLE_OUTRO))
+
+				chatStatus.pendingTransfers = transfers
+				chatStatus.stage = SettleInputConfirmation
+				replyExtras.ReplyOptions = []string{settleConfirm, settleCancel}
+
+				return reply.String()
+			},
+		},
+		Subhandler{
+			expectedStage: SettleInputConfirmation,
+			requiredRole:  RoleEditor,
+			handle: func(ctx context.Context, text string, chatStatus *ChatStatus, _ *ReplyExtras) string {
+				chatStatus.stage = None
+				transfers := chatStatus.pendingTransfers
+				chatStatus.pendingTransfers = nil
+
+				if normalizeText(text) != normalizeText(settleConfirm) {
+					return MESSAGE_CANCELLED_SETTLE
+				}
+
+				if err := h.storage.InsertSettlements(ctx, *chatStatus.sheetID, transfers, time.Now()); err != nil {
+					return chatStatus.serverError(err)
+				}
+
+				return MESSAGE_SUCCESS_SETTLE
+			},
+		},
+	}
+}
+
+func insertSharedPayment(ctx context.Context, h *Handler, chatStatus *ChatStatus, replyExtras *ReplyExtras, names map[int64]string, split map[int64]int64) string {
+	chatStatus.stage = None
+
+	payment := chatStatus.pendingPayment
+	payment.id = uuid.New().String()
+	if err := h.storage.InsertSharedPayment(ctx, *chatStatus.sheetID, payment, chatStatus.sharedPaidBy, split); err != nil {
+		return chatStatus.serverError(err)
+	}
+	botMetrics.paymentsRecorded.add(1, "shared")
+	replyExtras.InlineButtons = paymentButtons(payment.id)
+
+	var reply strings.Builder
+	reply.WriteString(chatStatus.tr(MESSAGE_SUCCESS_ADD_SHARED_PAYMENT))
+	reply.WriteString("\n\n")
+	members := make([]int64, 0, len(split))
+	for member := range split {
+		members = append(members, member)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i] < members[j] })
+	for _, member := range members {
+		fmt.Fprintf(&reply, "%s: %s\n", formatSplitMember(chatStatus, names, member), chatStatus.localAmount(split[member]))
+	}
+
+	return reply.String()
+}
+
+// listParticipants returns the user IDs of the sheet participants and their names by user ID
+func listParticipants(ctx context.Context, h *Handler, sheetID string) ([]int64, map[int64]string, error) {
+	participants, err := h.storage.ListSheetParticipants(ctx, sheetID)
+	if err != nil {
+		return nil, nil, err
+	}
+	userIDs := make([]int64, 0, len(participants))
+	names := make(map[int64]string, len(participants))
+	for _, participant := range participants {
+		userIDs = append(userIDs, participant.userID)
+		names[participant.userID] = participant.name
+	}
+	return userIDs, names, nil
+}
+
+// splitMemberRe takes the user ID, or "me", out of a reply option made by formatSplitMember
+var splitMemberRe = regexp.MustCompile(`\(([^()]+)\)$`)
+
+// parseSplitMember accepts a user ID of any sheet participant, including the current user, "me",
+// or one of the reply options
+func parseSplitMember(ctx context.Context, h *Handler, chatStatus *ChatStatus, text string) (int64, string) {
+	text = strings.TrimSpace(text)
+	if match := splitMemberRe.FindStringSubmatch(text); match != nil {
+		text = match[1]
+	}
+	if normalizeText(text) == splitMe {
+		return chatStatus.author.userID, ""
+	}
+
+	userID, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return 0, MESSAGE_INCORRECT_MEMBER
+	}
+	participants, _, err := listParticipants(ctx, h, *chatStatus.sheetID)
+	if err != nil {
+		return 0, chatStatus.serverError(err)
+	}
+	for _, participant := range participants {
+		if participant == userID {
+			return userID, ""
+		}
+	}
+
+	return 0, MESSAGE_INCORRECT_MEMBER
+}
+
+// formatSplitMember shows the participant by name, followed by the user ID to enter in the split, or "me"
+func formatSplitMember(chatStatus *ChatStatus, names map[int64]string, userID int64) string {
+	id := strconv.FormatInt(userID, 10)
+	if userID == chatStatus.author.userID {
+		id = splitMe
+	}
+	if names[userID] == "" {
+		return id
+	}
+	return fmt.Sprintf("%s (%s)", names[userID], id)
+}
+
+func formatSplitMembers(chatStatus *ChatStatus, names map[int64]string, members []int64) string {
+	formatted := make([]string, 0, len(members))
+	for _, member := range members {
+		formatted = append(formatted, formatSplitMember(chatStatus, names, member))
+	}
+	return strings.Join(formatted, "\n")
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How many categories are suggested for a payment the bot couldn't categorize
+const suggestedCategoriesCount = 3
+
+// Only this much of the sheet history is learned from, older habits are less relevant
+const suggestionHistory = 365 * 24 * time.Hour
+
+var suggestionWordRe = regexp.MustCompile(`[\p{L}\d]+`)
+
+const suggestionWordPrefix = "word:"
+
+// A merchant name in the comment says more about the category than when and how much was paid
+const suggestionWordWeight = 3
+
+// categoryModel is a naive Bayes classifier over the words of the comment, the order of magnitude of the amount,
+// the weekday and the time of day of a payment
+type categoryModel struct {
+	categoryCounts map[string]int
+	featureCounts  map[string]map[string]int
+	featureTotals  map[string]int
+	vocabulary     map[string]bool
+	total          int
+}
+
+func trainCategoryModel(payments []Payment) *categoryModel {
+	m := &categoryModel{
+		categoryCounts: make(map[string]int),
+		featureCounts:  make(map[string]map[string]int),
+		featureTotals:  make(map[string]int),
+		vocabulary:     make(map[string]bool),
+	}
+	for _, payment := range payments {
+		if payment.categoryName == "" {
+			continue
+		}
+		m.total++
+		m.categoryCounts[payment.categoryName]++
+		if m.featureCounts[payment.categoryName] == nil {
+			m.featureCounts[payment.categoryName] = make(map[string]int)
+		}
+		for _, feature := range paymentFeatures(payment.comment, payment.amount, payment.madeTime) {
+			m.featureCounts[payment.categoryName][feature]++
+			m.featureTotals[payment.categoryName]++
+			m.vocabulary[feature] = true
+		}
+	}
+	return m
+}
+
+// suggest returns up to n categories, the most likely first
+func (m *categoryModel) suggest(comment string, amount int64, madeTime time.Time, n int) []string {
+	features := paymentFeatures(comment, amount, madeTime)
+
+	scores := make(map[string]float64, len(m.categoryCounts))
+	categories := make([]string, 0, len(m.categoryCounts))
+	for category, count := range m.categoryCounts {
+		// Laplace smoothing, so that a feature never seen with a category doesn't rule it out
+		score := math.Log(float64(count) / float64(m.total))
+		denominator := float64(m.featureTotals[category] + len(m.vocabulary) + 1)
+		for _, feature := range features {
+			weight := 1.0
+			if strings.HasPrefix(feature, suggestionWordPrefix) {
+				weight = suggestionWordWeight
+			}
+			score += weight * math.Log(float64(m.featureCounts[category][feature]+1)/denominator)
+		}
+		scores[category] = score
+		categories = append(categories, category)
+	}
+
+	sort.Slice(categories, func(i, j int) bool {
+		if scores[categories[i]] != scores[categories[j]] {
+			return scores[categories[i]] > scores[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+	if len(categories) > n {
+		categories = categories[:n]
+	}
+	return categories
+}
+
+func paymentFeatures(comment string, amount int64, madeTime time.Time) []string {
+	var features []string
+	for _, word := range suggestionWordRe.FindAllString(normalizeText(comment), -1) {
+		features = append(features, suggestionWordPrefix+word)
+	}
+	if amount < 0 {
+		features = append(features, "refund")
+		amount = -amount
+	}
+	// 1-2, 2-4, 4-8... in whole currency units
+	magnitude := int(math.Log2(float64(amount)/100 + 1))
+	features = append(features, "amount:"+strconv.Itoa(magnitude))
+	features = append(features, "weekday:"+madeTime.Weekday().String())
+	features = append(features, "hour:"+strconv.Itoa(madeTime.Hour()/4))
+	return features
+}
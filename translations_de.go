@@ -0,0 +1,256 @@
+package main
+
+var germanLanguage = &language{
+	code:       "de",
+	name:       "Deutsch",
+	pluralForm: oneOtherPluralForm,
+	messages: map[string]string{
+		MESSAGE_UNEXPECTED_SERVER_ERROR:     "Unerwarteter Serverfehler",
+		MESSAGE_FAILURE_TRY_AGAIN:           "Der Server ist gerade ausgelastet, bitte versuche es in einer Minute erneut",
+		MESSAGE_FAILURE_NOT_FOUND:           "Nicht gefunden, vielleicht wurde es gerade gelöscht",
+		MESSAGE_FAILURE_DUPLICATE:           "Das gibt es bereits",
+		MESSAGE_FAILURE_CONSTRAINT:          "Konnte nicht gespeichert werden, wahrscheinlich ist es zu lang oder verweist auf etwas inzwischen Gelöschtes",
+		MESSAGE_ERROR_ID:                    "Fehlercode für den Support: %s",
+		MESSAGE_FAILURE_PERMISSION_DENIED:   "Dafür musst du mindestens %s dieser Tabelle sein",
+		MESSAGE_FAILURE_GROUP_ADMINS_ONLY:   "Das können nur die Admins dieser Gruppe",
+		MESSAGE_FAILURE_DOWNLOAD_DOCUMENT:   "Das Dokument konnte nicht heruntergeladen werden, es darf höchstens 1 MB groß sein",
+		MESSAGE_FAILURE_UNEXPECTED_DOCUMENT: "Dokumente werden nicht unterstützt",
+		MESSAGE_FAILURE_EXPIRED_BUTTON:      "Diese Schaltfläche ist abgelaufen, bitte wiederhole den Befehl",
+		MESSAGE_BUTTON_PREVIOUS_PAGE:        "« Zurück",
+		MESSAGE_BUTTON_NEXT_PAGE:            "Weiter »",
+		MESSAGE_NOT_CONNECTED_TO_SHEET:      "Du bist noch mit keiner Tabelle verbunden. Bitte erstelle eine neue oder verbinde dich mit einer bestehenden.\n/createSheet /connectSheet",
+		MESSAGE_OPTION_CONFIRM:              "Bestätigen",
+		MESSAGE_OPTION_CANCEL:               "Abbrechen",
+
+		MESSAGE_INPUT_LANGUAGE:       "Bitte wähle die Sprache",
+		MESSAGE_INCORRECT_LANGUAGE:   "Diese Sprache wird nicht unterstützt, bitte wähle eine aus der Liste",
+		MESSAGE_SUCCESS_SET_LANGUAGE: "Die Sprache wurde geändert",
+
+		MESSAGE_START_GREETING: "Hallo, BudgliBot zu Diensten!",
+		MESSAGE_START_CONNECT: `Um den Bot zu nutzen, kannst du entweder
+- Mit /createSheet deine eigene Tabelle erstellen
+- Oder dich mit /connectSheet mit einer bestehenden Tabelle verbinden, die jemand anderes erstellt hat`,
+		MESSAGE_START_FULL_HELP: "Klicke /help, um die Liste aller Befehle zu sehen",
+
+		MESSAGE_HELP: `
+- Um eine neue Zahlung zu erfassen, schreibe einfach "<Betrag> <Kategorie>", z.B. "42 Lebensmittel". Gibt es keine Kategorie mit diesem Namen, werden stattdessen die Kategorisierungsregeln auf den Text angewendet, und wenn keine passt, werden die wahrscheinlichsten Kategorien vorgeschlagen. Du kannst auch nur den Betrag schreiben.
+- Beträge werden mit dem Dezimaltrennzeichen der Chatsprache geschrieben, Tausender können mit Leerzeichen oder Apostrophen getrennt werden, und "1,5k" steht für 1500
+- Um mehrere Zahlungen auf einmal zu erfassen, schreibe jede in eine eigene Zeile. Entweder werden alle gespeichert oder, wenn manche Zeilen nicht gelesen werden können, keine
+- Beträge können auch berechnet werden, z.B. "12,5+7,3 Mittagessen", "3*4,99 Bier" oder "(120-20)/2 Abendessen"
+- Mit den Schaltflächen unter einer erfassten Zahlung kannst du sie rückgängig machen, ihre Kategorie ändern oder ihren Betrag bearbeiten.
+- Befehle, die nach einem Wert fragen, nehmen ihn auch direkt an, z.B. /createCategory Lebensmittel oder /setCurrency USD
+
+- Um die Zahlungen der aktuellen Tabelle als CSV-, QIF-, OFX-, Ledger- oder Beancount-Datei zu exportieren, klicke /export
+- Um einen Kontoauszug (CSV, QIF oder OFX) zu importieren, klicke /import
+
+Kategorien:
+- Um eine neue Kategorie hinzuzufügen, klicke /createCategory
+- Um deine Kategorien aufzulisten, klicke /listCategories
+
+Geteilte Zahlungen:
+- Um eine Zahlung hinzuzufügen, die zwischen den Mitgliedern der Tabelle aufgeteilt wird, klicke /addShared. Sie kann gleichmäßig, nach Anteilen oder nach genauen Beträgen aufgeteilt werden
+- Um zu sehen, wie viel jedes Mitglied schuldet oder bekommt, klicke /balances
+- Um die Überweisungen zu erfassen, die alle Salden ausgleichen, klicke /settle
+
+Kategorisierungsregeln:
+- Regeln ordnen importierte Zahlungen und Zahlungen ohne Kategorie einer Kategorie zu, z.B. "description contains LIDL -> Lebensmittel" oder "amount > 1000 and description matches /Miete/ -> Wohnen"
+- Um die Regeln in der Reihenfolge aufzulisten, in der sie angewendet werden, klicke /listRules
+- Um eine Regel hinzuzufügen, klicke /addRule
+- Um die Reihenfolge der Regeln zu ändern, klicke /moveRule
+- Um eine Regel zu löschen, klicke /deleteRule
+- Um zu prüfen, welche bisherigen Zahlungen eine Regel treffen würde, klicke /testRule
+
+Tabellen:
+- Um eine neue Tabelle hinzuzufügen, klicke /createSheet, aber wahrscheinlich brauchst du nur eine
+- Um dich mit einer Tabelle zu verbinden, klicke /connectSheet
+- Um die Verbindung zur aktuellen Tabelle zu trennen, klicke /disconnectSheet
+- Um alle deine Tabellen aufzulisten, klicke /listSheets
+- Um die aktuelle Tabelle umzubenennen, klicke /renameSheet (nur Eigentümer)
+- Um das Passwort der aktuellen Tabelle zu ändern, klicke /changePassword (nur Eigentümer)
+- Um die Währung der aktuellen Tabelle festzulegen, klicke /setCurrency (nur Eigentümer)
+- Um das Konto festzulegen, von dem Zahlungen in Ledger- und Beancount-Exporten bezahlt werden, klicke /setFundingAccount (nur Eigentümer)
+- Um ein anderes Mitglied zum Eigentümer der aktuellen Tabelle zu machen, klicke /transferOwnership (nur Eigentümer)
+- Um die aktuelle Tabelle mit allen Kategorien und Zahlungen zu löschen, klicke /deleteSheet (nur Eigentümer)
+
+Mitglieder:
+- Um die Mitglieder der aktuellen Tabelle aufzulisten, klicke /listMembers
+- Um die Rolle eines Mitglieds zu ändern, klicke /setRole (nur Eigentümer)
+- Um ein Mitglied aus der Tabelle zu entfernen, klicke /removeMember (nur Eigentümer)
+
+Einladungen:
+- Um jemanden mit einem Link zur aktuellen Tabelle einzuladen, klicke /createInvite (nur Eigentümer)
+- Um die noch gültigen Einladungen aufzulisten, klicke /listInvites (nur Eigentümer)
+- Um eine Einladung zu widerrufen, klicke /revokeInvite (nur Eigentümer)
+
+Gruppen:
+- Füge den Bot zu einer Gruppe hinzu, um eine Tabelle mit allen Gruppenmitgliedern zu teilen, jede Zahlung merkt sich, wer sie erfasst hat
+- In einer Gruppe können Befehle als /befehl@bot gesendet werden, und Antworten auf Fragen des Bots sollten als Antwort auf seine Nachricht gesendet werden
+- Um eine Gruppe zu verbinden, ohne dort das Passwort der Tabelle zu posten, verwende den Gruppenlink einer Einladung
+
+Sprache:
+- Um die Sprache des Bots in diesem Chat zu wählen, klicke /language. Standardmäßig folgt sie der Sprache deiner Telegram-App
+
+Rollen:
+- viewer kann Kategorien und Mitglieder auflisten
+- editor kann außerdem Kategorien und Zahlungen hinzufügen
+- owner kann außerdem die Mitglieder verwalten`,
+
+		MESSAGE_INPUT_NEW_SHEET_NAME:               "Denk dir einen Namen für die neue Tabelle aus und gib ihn ein",
+		MESSAGE_INPUT_NEW_SHEET_PASSWORD:           "Bitte gib das Passwort der neuen Tabelle ein",
+		MESSAGE_CREATED_NEW_SHEET:                  "Neue Tabelle erstellt!\nName: %s\nID: %s",
+		MESSAGE_INPUT_SHEET_ID:                     "Bitte gib die ID der Tabelle ein (sie wird beim Erstellen einer Tabelle angezeigt)",
+		MESSAGE_INPUT_SHEET_PASSWORD:               "Bitte gib das Passwort der Tabelle ein",
+		MESSAGE_INCORRECT_SHEET_ID_FORMAT:          "Falsches Format der Tabellen-ID, erwartet wird xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx, z.B. e72e1f4c-fb53-4455-9f0e-a1e9d0e1bc4d",
+		MESSAGE_SUCCESS_CONNECT_TO_SHEET:           "Erfolgreich mit der Tabelle verbunden",
+		MESSAGE_INCORRECT_PASSWORD:                 "Falsches Passwort, bitte versuche es erneut",
+		MESSAGE_TOO_MANY_PASSWORD_ATTEMPTS:         "Zu viele falsche Passworteingaben, bitte versuche es in %s erneut",
+		MESSAGE_NOTIFY_FAILED_PASSWORD_ATTEMPTS:    "Achtung: In letzter Zeit gab es %d fehlgeschlagene Passworteingaben für deine Tabelle %s. Wenn es niemand ist, den du kennst, lade Mitglieder besser mit /createInvite ein, statt das Passwort weiterzugeben",
+		MESSAGE_INCORRECT_NEW_PASSWORD_SLASH:       "Das Passwort der Tabelle darf nicht mit / beginnen",
+		MESSAGE_INCORRECT_NEW_PASSWORD_TOO_SHORT:   "Das Passwort der Tabelle muss mindestens 3 Zeichen lang sein",
+		MESSAGE_INCORRECT_NEW_PASSWORD_TOO_LONG:    "Das Passwort der Tabelle darf höchstens 72 Bytes lang sein",
+		MESSAGE_INCORRECT_NEW_SHEET_NAME_SLASH:     "Der Name der Tabelle darf nicht mit / beginnen",
+		MESSAGE_INCORRECT_NEW_SHEET_NAME_TOO_SHORT: "Der Name der Tabelle muss mindestens 3 Zeichen lang sein",
+		MESSAGE_SUCCESS_DISCONNECT_SHEET:           "Die Verbindung zur Tabelle wurde getrennt",
+		MESSAGE_SUCCESS_RENAME_SHEET:               "Die Tabelle wurde umbenannt",
+		MESSAGE_SUCCESS_CHANGE_SHEET_PASSWORD:      "Das Passwort der Tabelle wurde geändert. Bestehende Mitglieder bleiben verbunden",
+		MESSAGE_INPUT_SHEET_CURRENCY:               "Bitte gib den Währungscode der Tabelle ein, z.B. EUR",
+		MESSAGE_INCORRECT_SHEET_CURRENCY:           "Der Währungscode muss aus 2 bis 10 lateinischen Buchstaben oder Ziffern bestehen und mit einem Buchstaben beginnen, z.B. EUR",
+		MESSAGE_SUCCESS_SET_SHEET_CURRENCY:         "Die Währung der Tabelle wurde geändert",
+		MESSAGE_INPUT_SHEET_FUNDING_ACCOUNT:        "Bitte gib das Konto ein, von dem die Zahlungen bezahlt werden, z.B. Assets:Bank:Checking oder Liabilities:CreditCard",
+		MESSAGE_INCORRECT_SHEET_FUNDING_ACCOUNT:    "Das Konto muss mit Assets, Liabilities, Equity oder Income beginnen, gefolgt von großgeschriebenen, durch Doppelpunkte getrennten Teilen, z.B. Assets:Bank:Checking",
+		MESSAGE_SUCCESS_SET_SHEET_FUNDING_ACCOUNT:  "Das Zahlungskonto wurde geändert",
+		MESSAGE_INPUT_NEW_OWNER:                    "Bitte wähle den neuen Eigentümer. Du wirst dann editor der Tabelle",
+		MESSAGE_SUCCESS_TRANSFER_OWNERSHIP:         "Die Eigentümerschaft wurde übertragen, du bist jetzt editor der Tabelle",
+		MESSAGE_INPUT_DELETE_SHEET_CONFIRMATION:    "Damit wird die Tabelle mit allen Kategorien und Zahlungen für alle Mitglieder gelöscht. Das kann nicht rückgängig gemacht werden.\n\nGib zur Bestätigung den Namen der Tabelle ein: %s",
+		MESSAGE_CANCELLED_DELETE_SHEET:             "Der Name stimmt nicht überein, die Tabelle wurde nicht gelöscht",
+		MESSAGE_SUCCESS_DELETE_SHEET:               "Die Tabelle wurde gelöscht",
+		MESSAGE_LIST_SHEETS_ITEM:                   "%2d. Name: %s\n    ID: %s\n    Rolle: %s\n\n",
+		MESSAGE_LIST_SHEETS_OUTRO: `Um neue Tabellen hinzuzufügen (wenn du schon eine hast, brauchst du sehr wahrscheinlich keine weitere), klicke /createSheet
+Um dich mit einer dieser oder anderer Tabellen zu verbinden, klicke /connectSheet`,
+
+		MESSAGE_FAILURE_UNKNOWN_CATEGORY_NAME:  "Es gibt keine Kategorie mit diesem Namen",
+		MESAGE_SUCCESS_CREATE_PAYMENT:          "Die Zahlung wurde erfasst",
+		MESSAGE_SUCCESS_CREATE_PAYMENT_BY_RULE: "Die Zahlung wurde in der Kategorie %s erfasst",
+		MESSAGE_INPUT_SUGGESTED_CATEGORY:       "Es gibt keine Kategorie mit diesem Namen. Bitte wähle eine Kategorie für diese Zahlung",
+		MESSAGE_FAILURE_PARSING:                "Konnte die Nachricht nicht verstehen\n\nKlicke /help, um die Liste aller Befehle zu sehen",
+		MESSAGE_BUTTON_UNDO:                    "Rückgängig",
+		MESSAGE_BUTTON_CHANGE_CATEGORY:         "Kategorie ändern",
+		MESSAGE_BUTTON_EDIT_AMOUNT:             "Betrag bearbeiten",
+		MESSAGE_FAILURE_PAYMENT_NOT_FOUND:      "Diese Zahlung existiert nicht mehr",
+		MESSAGE_FAILURE_EDIT_SHARED_PAYMENT:    "Der Betrag einer geteilten Zahlung kann nicht bearbeitet werden, mache sie stattdessen rückgängig und füge sie erneut hinzu",
+		MESSAGE_SUCCESS_UNDO_PAYMENT:           "Die Zahlung über %s in der Kategorie %s wurde gelöscht",
+		MESSAGE_INPUT_NEW_CATEGORY:             "Bitte wähle die neue Kategorie der Zahlung (Seite %d von %d)",
+		MESSAGE_SUCCESS_CHANGE_CATEGORY:        "Die Zahlung wurde in die Kategorie %s verschoben",
+		MESSAGE_INPUT_NEW_AMOUNT:               "Der Betrag der Zahlung ist %s, bitte gib den neuen ein",
+		MESSAGE_INCORRECT_AMOUNT:               "Erwartet wird ein Betrag, z.B. 42,50",
+		MESSAGE_COMPUTED_AMOUNT:                "Betrag: %s = %s",
+		MESSAGE_BATCH_INCORRECT_LINE:           "erwartet wird \"<Betrag> <Kategorie>\"",
+		MESSAGE_BATCH_AMBIGUOUS_AMOUNT:         "das Trennzeichen im Betrag ist mehrdeutig",
+		MESSAGE_AMBIGUOUS_AMOUNT:               "Es ist unklar, ob das Trennzeichen im Betrag Dezimalstellen oder Tausender trennt. Bitte schreibe den Betrag wie %s oder ohne Trennzeichen",
+		MESSAGE_SUCCESS_EDIT_AMOUNT:            "Der Betrag der Zahlung wurde auf %s geändert",
+
+		MESSAGE_INPUT_EXPORT_FORMAT:     "Bitte wähle das Dateiformat",
+		MESSAGE_INCORRECT_EXPORT_FORMAT: "Unbekanntes Dateiformat, bitte wähle eines aus der Liste",
+		MESSAGE_INPUT_EXPORT_DATE_RANGE: "Bitte wähle den zu exportierenden Zeitraum oder gib zwei Daten ein, z.B. 2024-01-01 2024-03-31",
+		MESSAGE_FAILURE_EXPORT_CURRENCY: "Ein OFX-Kontoauszug hat nur eine Währung, die Zahlungen dieses Zeitraums sind aber in %s. Bitte wähle einen Zeitraum mit einer Währung oder ein anderes Format",
+		MESSAGE_INCORRECT_DATE_RANGE:    "Der Zeitraum konnte nicht gelesen werden, erwartet werden zwei Daten im Format JJJJ-MM-TT, z.B. 2024-01-01 2024-03-31",
+		MESSAGE_OPTION_THIS_MONTH:       "Dieser Monat",
+		MESSAGE_OPTION_LAST_MONTH:       "Letzter Monat",
+		MESSAGE_OPTION_THIS_YEAR:        "Dieses Jahr",
+		MESSAGE_OPTION_ALL_TIME:         "Gesamter Zeitraum",
+
+		MESSAGE_IMPORT_INSTRUCTIONS: `Um einen Kontoauszug zu importieren, lade ihn hier als CSV-, QIF- oder OFX-Datei hoch.
+Bei CSV-Dateien mit einer Kopfzeile wirst du beim ersten Mal gefragt, welche Spalten das Datum, den Betrag und die Beschreibung enthalten, beim nächsten Mal werden dieselben Spalten verwendet.
+Zahlungen werden nach den Regeln aus /listRules einer Kategorie zugeordnet, dann der Kategorie, deren Name in der Beschreibung vorkommt, und sonst der Kategorie "` + uncategorizedCategoryName + `". Zahlungen, die schon in der Tabelle sind, werden übersprungen.
+Nichts wird gespeichert, bevor du bestätigst.`,
+		MESSAGE_FAILURE_UNKNOWN_DOCUMENT_FORMAT: "Nicht unterstütztes Dateiformat, erwartet wird eine CSV-, QIF- oder OFX-Datei",
+		MESSAGE_FAILURE_PARSING_STATEMENT:       "Der Kontoauszug konnte nicht gelesen werden, erwartet wird eine CSV-Datei mit einer Kopfzeile",
+		MESSAGE_INPUT_IMPORT_DATE_COLUMN:        "Welche Spalte enthält das Datum der Zahlung?",
+		MESSAGE_INPUT_IMPORT_AMOUNT_COLUMN:      "Welche Spalte enthält den Betrag?",
+		MESSAGE_INPUT_IMPORT_DESCRIPTION_COLUMN: "Welche Spalte enthält die Beschreibung?",
+		MESSAGE_INPUT_IMPORT_EXPENSES_SIGN:      "Werden Ausgaben in diesem Kontoauszug als negative Beträge angezeigt?",
+		MESSAGE_INCORRECT_IMPORT_COLUMN:         "Diese Spalte gibt es nicht, bitte wähle eine aus der Liste",
+		MESSAGE_INCORRECT_IMPORT_DATE_COLUMN:    "Die Daten in dieser Spalte wurden nicht erkannt, bitte wähle eine andere",
+		MESSAGE_FAILURE_EMPTY_IMPORT:            "Im Kontoauszug wurden keine Zahlungen gefunden",
+		MESSAGE_FAILURE_ONLY_DUPLICATES_IMPORT:  "Alle Zahlungen aus dem Kontoauszug sind schon in der Tabelle",
+		MESSAGE_IMPORT_PREVIEW_MORE:             "...und %d weitere\n",
+		MESSAGE_CANCELLED_IMPORT:                "Der Import wurde abgebrochen",
+		MESSAGE_OPTION_EXPENSES_NEGATIVE:        "Ja, Ausgaben sind negativ",
+		MESSAGE_OPTION_EXPENSES_POSITIVE:        "Nein, Ausgaben sind positiv",
+
+		MESSAGE_INPUT_CATEGORY_NAME:     "Bitte gib den Namen der neuen Kategorie ein",
+		MESSAGE_SUCCESS_CREATE_CATEGORY: "Neue Kategorie erstellt!",
+		MESSAGE_LIST_CATEGORIES_OUTRO:   "Um neue Kategorien hinzuzufügen, klicke /createCategory",
+
+		MESSAGE_LIST_RULES_OUTRO:         "Um neue Regeln hinzuzufügen, klicke /addRule\nUm ihre Reihenfolge zu ändern, klicke /moveRule\nUm eine Regel zu löschen, klicke /deleteRule",
+		MESSAGE_INPUT_RULE:               "Bitte gib die Regel ein, z.B.\ndescription contains LIDL -> Lebensmittel\namount > 1000 and description matches /Miete/ -> Wohnen",
+		MESSAGE_INCORRECT_RULE:           "Die Regel konnte nicht gelesen werden: %s",
+		MESSAGE_SUCCESS_ADD_RULE:         "Die neue Regel wurde hinzugefügt, sie wird nach allen bestehenden angewendet",
+		MESSAGE_INPUT_RULE_POSITIONS:     "Bitte gib die aktuelle und die neue Position der Regel ein, z.B. \"3 1\", um die dritte Regel zur ersten zu machen",
+		MESSAGE_INCORRECT_RULE_POSITIONS: "Erwartet werden zwei Positionen aus /listRules, z.B. \"3 1\"",
+		MESSAGE_SUCCESS_MOVE_RULE:        "Die Regel wurde verschoben",
+		MESSAGE_INPUT_RULE_POSITION:      "Bitte gib die Position der zu löschenden Regel ein",
+		MESSAGE_INCORRECT_RULE_POSITION:  "An dieser Position gibt es keine Regel, siehe /listRules",
+		MESSAGE_SUCCESS_DELETE_RULE:      "Die Regel wurde gelöscht",
+		MESSAGE_TEST_RULE_INTRO:          "Die Regel trifft %d von %d Zahlungen, %d davon sind jetzt in einer anderen Kategorie. Die neuesten:",
+
+		MESSAGE_INPUT_SHARED_PAYMENT:       "Bitte gib die geteilte Zahlung als \"<Betrag> <Kategorie>\" ein, z.B. \"120 Lebensmittel\"",
+		MESSAGE_INCORRECT_SHARED_PAYMENT:   "Erwartet wird \"<Betrag> <Kategorie>\", z.B. \"120 Lebensmittel\"",
+		MESSAGE_INPUT_SHARED_PAYER:         "Wer hat bezahlt?",
+		MESSAGE_INPUT_SPLIT_MODE:           "Wie soll die Zahlung zwischen den Mitgliedern aufgeteilt werden?",
+		MESSAGE_INCORRECT_SPLIT_MODE:       "Bitte wähle eine der Möglichkeiten: gleichmäßig, nach Anteilen oder nach genauen Beträgen",
+		MESSAGE_INPUT_SPLIT_SHARES:         "Bitte gib die Anteile als \"<Mitglied>:<Anteil>\"-Paare ein, z.B. \"me:2 123456:1\". Mitglieder:\n%s",
+		MESSAGE_INPUT_SPLIT_AMOUNTS:        "Bitte gib die Beträge als \"<Mitglied>:<Betrag>\"-Paare ein, die zusammen %s ergeben, z.B. \"me:70 123456:50\". Mitglieder:\n%s",
+		MESSAGE_INCORRECT_SPLIT:            "Die Zahlung konnte nicht aufgeteilt werden: %s",
+		MESSAGE_SUCCESS_ADD_SHARED_PAYMENT: "Die geteilte Zahlung wurde hinzugefügt, sie ist so aufgeteilt:",
+		MESSAGE_LIST_BALANCES_INTRO:        "Salden der Mitglieder der Tabelle, positive bekommen Geld:",
+		MESSAGE_LIST_BALANCES_OUTRO:        "Um auszugleichen, klicke /settle",
+		MESSAGE_NOTHING_TO_SETTLE:          "Alle Salden sind bereits ausgeglichen",
+		MESSAGE_SETTLE_INTRO:               "Diese Überweisungen gleichen alle Salden aus:",
+		MESSAGE_SETTLE_OUTRO:               "Bestätige, sobald das Geld überwiesen ist, um den Ausgleich zu erfassen",
+		MESSAGE_CANCELLED_SETTLE:           "Der Ausgleich wurde abgebrochen",
+		MESSAGE_SUCCESS_SETTLE:             "Der Ausgleich wurde erfasst",
+		MESSAGE_OPTION_SPLIT_EQUALLY:       "Gleichmäßig",
+		MESSAGE_OPTION_SPLIT_BY_SHARES:     "Nach Anteilen",
+		MESSAGE_OPTION_SPLIT_BY_AMOUNTS:    "Nach genauen Beträgen",
+
+		MESSAGE_LIST_MEMBERS_OUTRO:    "Um die Rolle eines Mitglieds zu ändern, klicke /setRole\nUm ein Mitglied zu entfernen, klicke /removeMember",
+		MESSAGE_INPUT_MEMBER:          "Bitte wähle ein Mitglied",
+		MESSAGE_ROLE_VIEWER:           "Betrachter",
+		MESSAGE_ROLE_EDITOR:           "Bearbeiter",
+		MESSAGE_ROLE_OWNER:            "Eigentümer",
+		MESSAGE_INPUT_ROLE:            "Bitte wähle eine neue Rolle: Bearbeiter oder Betrachter",
+		MESSAGE_INCORRECT_MEMBER:      "Dieses Mitglied wurde nicht gefunden, bitte wähle eines aus der Liste",
+		MESSAGE_INCORRECT_ROLE:        "Unbekannte Rolle, erwartet wird Bearbeiter oder Betrachter",
+		MESSAGE_NO_OTHER_MEMBERS:      "Diese Tabelle hat keine anderen Mitglieder",
+		MESSAGE_SUCCESS_SET_ROLE:      "Die Rolle des Mitglieds wurde geändert",
+		MESSAGE_SUCCESS_REMOVE_MEMBER: "Das Mitglied wurde aus der Tabelle entfernt",
+
+		MESSAGE_INPUT_INVITE_ROLE:     "Bitte wähle die Rolle, die die Einladung vergibt: Bearbeiter oder Betrachter",
+		MESSAGE_INPUT_INVITE_USES:     "Bitte gib ein, wie oft die Einladung verwendet werden kann",
+		MESSAGE_INCORRECT_INVITE_USES: "Die Anzahl der Verwendungen muss zwischen 1 und 100 liegen",
+		MESSAGE_CREATED_INVITE:        "Neue Einladung erstellt! Teile diesen Link:\n%s\n\nUm stattdessen eine Gruppe mit der Tabelle zu verbinden, füge den Bot mit diesem Link hinzu:\n%s\n\nRolle: %s\nVerwendungen: %d\nGültig bis: %s",
+		MESSAGE_LIST_INVITES_OUTRO:    "Um eine neue Einladung zu erstellen, klicke /createInvite\nUm eine Einladung zu widerrufen, klicke /revokeInvite",
+		MESSAGE_LIST_INVITES_ITEM:     "%2d. %s\n    Rolle: %s, verbleibende Verwendungen: %d, gültig bis: %s\n\n",
+		MESSAGE_NO_INVITES:            "Diese Tabelle hat keine gültigen Einladungen",
+		MESSAGE_INPUT_INVITE_TOKEN:    "Bitte wähle die zu widerrufende Einladung",
+		MESSAGE_INCORRECT_INVITE:      "Diese Einladung ist ungültig, abgelaufen oder wurde schon verwendet",
+		MESSAGE_SUCCESS_REVOKE_INVITE: "Die Einladung wurde widerrufen",
+	},
+	plurals: map[string][]string{
+		MESSAGE_LIST_SHEETS_INTRO:       {"Dein Benutzer ist Mitglied der folgenden %d Tabelle:", "Dein Benutzer ist Mitglied der folgenden %d Tabellen:"},
+		MESSAGE_SUCCESS_EXPORT:          {"%d Zahlung exportiert", "%d Zahlungen exportiert"},
+		MESSAGE_IMPORT_PREVIEW_INTRO:    {"%d Zahlung wird importiert, %d bereits vorhandene werden übersprungen, %d Zeilen konnten nicht gelesen werden:", "%d Zahlungen werden importiert, %d bereits vorhandene werden übersprungen, %d Zeilen konnten nicht gelesen werden:"},
+		MESSAGE_SUCCESS_IMPORT:          {"%d Zahlung importiert", "%d Zahlungen importiert"},
+		MESSAGE_LIST_CATEGORIES_INTRO:   {"Diese Tabelle hat die folgende %d Kategorie:", "Diese Tabelle hat die folgenden %d Kategorien:"},
+		MESSAGE_LIST_RULES_INTRO:        {"Diese Tabelle hat die folgende %d Kategorisierungsregel, die erste passende wird angewendet:", "Diese Tabelle hat die folgenden %d Kategorisierungsregeln, die erste passende wird angewendet:"},
+		MESSAGE_LIST_MEMBERS_INTRO:      {"Diese Tabelle hat das folgende %d Mitglied:", "Diese Tabelle hat die folgenden %d Mitglieder:"},
+		MESSAGE_SUCCESS_CREATE_PAYMENTS: {"%d Zahlung wurde erfasst:", "%d Zahlungen wurden erfasst:"},
+		MESSAGE_FAILURE_CREATE_PAYMENTS: {"%d Zeile konnte nicht gelesen werden, daher wurde keine der Zahlungen gespeichert. Bitte korrigiere sie und sende die Nachricht erneut:", "%d Zeilen konnten nicht gelesen werden, daher wurde keine der Zahlungen gespeichert. Bitte korrigiere sie und sende die Nachricht erneut:"},
+		MESSAGE_LIST_INVITES_INTRO:      {"Diese Tabelle hat die folgende %d gültige Einladung:", "Diese Tabelle hat die folgenden %d gültigen Einladungen:"},
+	},
+	decimalSeparator:   ",",
+	thousandsSeparator: ".",
+	dateLayout:         "02.01.2006",
+	dateTimeLayout:     "02.01.2006 15:04",
+}
@@ -0,0 +1,270 @@
+package main
+
+var russianLanguage = &language{
+	code:       "ru",
+	name:       "Русский",
+	pluralForm: russianPluralForm,
+	messages: map[string]string{
+		MESSAGE_UNEXPECTED_SERVER_ERROR:     "Непредвиденная ошибка сервера",
+		MESSAGE_FAILURE_TRY_AGAIN:           "Сервер сейчас перегружен, пожалуйста, попробуйте ещё раз через минуту",
+		MESSAGE_FAILURE_NOT_FOUND:           "Не найдено, возможно, это только что удалили",
+		MESSAGE_FAILURE_DUPLICATE:           "Это уже существует",
+		MESSAGE_FAILURE_CONSTRAINT:          "Не удалось сохранить, вероятно, это слишком длинно или ссылается на что-то уже удалённое",
+		MESSAGE_ERROR_ID:                    "Код ошибки для поддержки: %s",
+		MESSAGE_FAILURE_PERMISSION_DENIED:   "Для этого нужна роль не ниже «%s» в этой таблице",
+		MESSAGE_FAILURE_GROUP_ADMINS_ONLY:   "Это могут делать только администраторы этой группы",
+		MESSAGE_FAILURE_DOWNLOAD_DOCUMENT:   "Не удалось скачать документ, его размер должен быть не больше 1 МБ",
+		MESSAGE_FAILURE_UNEXPECTED_DOCUMENT: "Документы не поддерживаются",
+		MESSAGE_FAILURE_EXPIRED_BUTTON:      "Эта кнопка устарела, пожалуйста, повторите команду",
+		MESSAGE_BUTTON_PREVIOUS_PAGE:        "« Назад",
+		MESSAGE_BUTTON_NEXT_PAGE:            "Вперёд »",
+		MESSAGE_NOT_CONNECTED_TO_SHEET:      "Вы ещё не подключены к таблице. Создайте новую или подключитесь к существующей.\n/createSheet /connectSheet",
+		MESSAGE_OPTION_CONFIRM:              "Подтвердить",
+		MESSAGE_OPTION_CANCEL:               "Отменить",
+
+		MESSAGE_INPUT_LANGUAGE:       "Пожалуйста, выберите язык",
+		MESSAGE_INCORRECT_LANGUAGE:   "Этот язык не поддерживается, пожалуйста, выберите язык из списка",
+		MESSAGE_SUCCESS_SET_LANGUAGE: "Язык изменён",
+
+		MESSAGE_START_GREETING: "Привет, BudgliBot к вашим услугам!",
+		MESSAGE_START_CONNECT: `Чтобы начать пользоваться ботом, нужно
+- Создать свою таблицу с помощью /createSheet
+- Или подключиться к существующей (которую создал кто-то другой) с помощью /connectSheet`,
+		MESSAGE_START_FULL_HELP: "Нажмите /help, чтобы увидеть список всех команд",
+
+		MESSAGE_HELP: `
+- Чтобы добавить новый платёж, просто напишите "<сумма> <категория>", например "42 продукты". Если категории с таким названием нет, к тексту применяются правила категоризации, а если ни одно не подходит, предлагаются наиболее вероятные категории. Можно написать и просто сумму.
+- Суммы пишутся с десятичным разделителем языка чата, тысячи можно отделять пробелами или апострофами, а "1,5k" означает 1500
+- Чтобы добавить несколько платежей сразу, напишите каждый на отдельной строке. Сохраняются либо все, либо, если какие-то строки не удалось прочитать, ни один
+- Суммы можно и вычислять, например "12,5+7,3 обед", "3*4,99 пиво" или "(120-20)/2 ужин"
+- Кнопки под добавленным платежом позволяют отменить его, изменить его категорию или сумму.
+- Команды, которые спрашивают значение, принимают его и сразу, например /createCategory продукты или /setCurrency USD
+
+- Чтобы выгрузить платежи текущей таблицы в файл CSV, QIF, OFX, Ledger или Beancount, нажмите /export
+- Чтобы загрузить банковскую выписку (CSV, QIF или OFX), нажмите /import
+
+Категории:
+- Чтобы добавить новую категорию, нажмите /createCategory
+- Чтобы увидеть список категорий, нажмите /listCategories
+
+Общие платежи:
+- Чтобы добавить платёж, который делится между участниками таблицы, нажмите /addShared. Его можно разделить поровну, по долям или точными суммами
+- Чтобы увидеть, сколько каждый участник должен или сколько должны ему, нажмите /balances
+- Чтобы записать переводы, которые закрывают все долги, нажмите /settle
+
+Правила категоризации:
+- Правила относят загруженные платежи и платежи без категории к категориям, например "description contains LIDL -> продукты" или "amount > 1000 and description matches /аренда/ -> жильё"
+- Чтобы увидеть правила в порядке их применения, нажмите /listRules
+- Чтобы добавить правило, нажмите /addRule
+- Чтобы изменить порядок правил, нажмите /moveRule
+- Чтобы удалить правило, нажмите /deleteRule
+- Чтобы проверить, под какие прошлые платежи подходит правило, нажмите /testRule
+
+Таблицы:
+- Чтобы добавить новую таблицу, нажмите /createSheet, но скорее всего вам хватит одной
+- Чтобы подключиться к таблице, нажмите /connectSheet
+- Чтобы отключиться от текущей таблицы, нажмите /disconnectSheet
+- Чтобы увидеть список всех ваших таблиц, нажмите /listSheets
+- Чтобы переименовать текущую таблицу, нажмите /renameSheet (только владелец)
+- Чтобы изменить пароль текущей таблицы, нажмите /changePassword (только владелец)
+- Чтобы задать валюту текущей таблицы, нажмите /setCurrency (только владелец)
+- Чтобы задать счёт, с которого оплачиваются платежи в выгрузках Ledger и Beancount, нажмите /setFundingAccount (только владелец)
+- Чтобы сделать владельцем текущей таблицы другого участника, нажмите /transferOwnership (только владелец)
+- Чтобы удалить текущую таблицу со всеми категориями и платежами, нажмите /deleteSheet (только владелец)
+
+Участники:
+- Чтобы увидеть список участников текущей таблицы, нажмите /listMembers
+- Чтобы изменить роль участника, нажмите /setRole (только владелец)
+- Чтобы удалить участника из таблицы, нажмите /removeMember (только владелец)
+
+Приглашения:
+- Чтобы пригласить кого-то в текущую таблицу по ссылке, нажмите /createInvite (только владелец)
+- Чтобы увидеть приглашения, которые ещё можно использовать, нажмите /listInvites (только владелец)
+- Чтобы отозвать приглашение, нажмите /revokeInvite (только владелец)
+
+Группы:
+- Добавьте бота в группу, чтобы вести таблицу вместе со всеми её участниками, у каждого платежа записывается, кто его добавил
+- В группе команды можно отправлять как /команда@бот, а ответы на вопросы бота стоит отправлять ответом на его сообщение
+- Чтобы подключить группу, не публикуя в ней пароль таблицы, используйте ссылку приглашения для группы
+
+Язык:
+- Чтобы выбрать язык бота в этом чате, нажмите /language. По умолчанию используется язык вашего приложения Telegram
+
+Роли:
+- viewer может просматривать категории и участников
+- editor может также добавлять категории и платежи
+- owner может также управлять участниками`,
+
+		MESSAGE_INPUT_NEW_SHEET_NAME:               "Придумайте и введите название новой таблицы",
+		MESSAGE_INPUT_NEW_SHEET_PASSWORD:           "Пожалуйста, введите пароль новой таблицы",
+		MESSAGE_CREATED_NEW_SHEET:                  "Новая таблица создана!\nНазвание: %s\nID: %s",
+		MESSAGE_INPUT_SHEET_ID:                     "Пожалуйста, введите ID таблицы (он показывается при создании таблицы)",
+		MESSAGE_INPUT_SHEET_PASSWORD:               "Пожалуйста, введите пароль таблицы",
+		MESSAGE_INCORRECT_SHEET_ID_FORMAT:          "Неверный формат ID таблицы, ожидается xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx, например e72e1f4c-fb53-4455-9f0e-a1e9d0e1bc4d",
+		MESSAGE_SUCCESS_CONNECT_TO_SHEET:           "Вы подключились к таблице",
+		MESSAGE_INCORRECT_PASSWORD:                 "Неверный пароль, попробуйте ещё раз",
+		MESSAGE_TOO_MANY_PASSWORD_ATTEMPTS:         "Слишком много попыток ввести неверный пароль, попробуйте снова через %s",
+		MESSAGE_NOTIFY_FAILED_PASSWORD_ATTEMPTS:    "Внимание: недавно было %d неудачных попыток ввести пароль вашей таблицы %s. Если это кто-то незнакомый, лучше приглашайте участников через /createInvite, а не делитесь паролем",
+		MESSAGE_INCORRECT_NEW_PASSWORD_SLASH:       "Пароль таблицы не должен начинаться с /",
+		MESSAGE_INCORRECT_NEW_PASSWORD_TOO_SHORT:   "Пароль таблицы должен быть не короче 3 символов",
+		MESSAGE_INCORRECT_NEW_PASSWORD_TOO_LONG:    "Пароль таблицы должен быть не длиннее 72 байт",
+		MESSAGE_INCORRECT_NEW_SHEET_NAME_SLASH:     "Название таблицы не должно начинаться с /",
+		MESSAGE_INCORRECT_NEW_SHEET_NAME_TOO_SHORT: "Название таблицы должно быть не короче 3 символов",
+		MESSAGE_SUCCESS_DISCONNECT_SHEET:           "Вы отключились от таблицы",
+		MESSAGE_SUCCESS_RENAME_SHEET:               "Таблица переименована",
+		MESSAGE_SUCCESS_CHANGE_SHEET_PASSWORD:      "Пароль таблицы изменён. Текущие участники остаются подключены",
+		MESSAGE_INPUT_SHEET_CURRENCY:               "Пожалуйста, введите код валюты таблицы, например EUR",
+		MESSAGE_INCORRECT_SHEET_CURRENCY:           "Код валюты должен состоять из 2–10 латинских букв или цифр и начинаться с буквы, например EUR",
+		MESSAGE_SUCCESS_SET_SHEET_CURRENCY:         "Валюта таблицы изменена",
+		MESSAGE_INPUT_SHEET_FUNDING_ACCOUNT:        "Пожалуйста, введите счёт, с которого оплачиваются платежи, например Assets:Bank:Checking или Liabilities:CreditCard",
+		MESSAGE_INCORRECT_SHEET_FUNDING_ACCOUNT:    "Счёт должен начинаться с Assets, Liabilities, Equity или Income, за которыми следуют части с заглавной буквы через двоеточие, например Assets:Bank:Checking",
+		MESSAGE_SUCCESS_SET_SHEET_FUNDING_ACCOUNT:  "Счёт оплаты изменён",
+		MESSAGE_INPUT_NEW_OWNER:                    "Пожалуйста, выберите нового владельца. Вы станете editor таблицы",
+		MESSAGE_SUCCESS_TRANSFER_OWNERSHIP:         "Права владельца переданы, теперь вы editor таблицы",
+		MESSAGE_INPUT_DELETE_SHEET_CONFIRMATION:    "Таблица будет удалена со всеми категориями и платежами для всех её участников. Это нельзя отменить.\n\nДля подтверждения введите название таблицы: %s",
+		MESSAGE_CANCELLED_DELETE_SHEET:             "Название не совпадает, таблица не удалена",
+		MESSAGE_SUCCESS_DELETE_SHEET:               "Таблица удалена",
+		MESSAGE_LIST_SHEETS_ITEM:                   "%2d. Название: %s\n    ID: %s\n    Роль: %s\n\n",
+		MESSAGE_LIST_SHEETS_OUTRO: `Чтобы добавить новые таблицы (если у вас уже есть одна, скорее всего больше не понадобится), нажмите /createSheet
+Чтобы подключиться к одной из этих или других таблиц, нажмите /connectSheet`,
+
+		MESSAGE_FAILURE_UNKNOWN_CATEGORY_NAME:  "Категория с таким названием не найдена",
+		MESAGE_SUCCESS_CREATE_PAYMENT:          "Платёж добавлен",
+		MESSAGE_SUCCESS_CREATE_PAYMENT_BY_RULE: "Платёж добавлен в категорию %s",
+		MESSAGE_INPUT_SUGGESTED_CATEGORY:       "Категория с таким названием не найдена. Пожалуйста, выберите категорию для этого платежа",
+		MESSAGE_FAILURE_PARSING:                "Не удалось разобрать сообщение\n\nНажмите /help, чтобы увидеть список всех команд",
+		MESSAGE_BUTTON_UNDO:                    "Отменить",
+		MESSAGE_BUTTON_CHANGE_CATEGORY:         "Изменить категорию",
+		MESSAGE_BUTTON_EDIT_AMOUNT:             "Изменить сумму",
+		MESSAGE_FAILURE_PAYMENT_NOT_FOUND:      "Этого платежа больше нет",
+		MESSAGE_FAILURE_EDIT_SHARED_PAYMENT:    "Сумму общего платежа нельзя изменить, вместо этого отмените его и добавьте заново",
+		MESSAGE_SUCCESS_UNDO_PAYMENT:           "Платёж на %s в категории %s удалён",
+		MESSAGE_INPUT_NEW_CATEGORY:             "Пожалуйста, выберите новую категорию платежа (страница %d из %d)",
+		MESSAGE_SUCCESS_CHANGE_CATEGORY:        "Платёж перенесён в категорию %s",
+		MESSAGE_INPUT_NEW_AMOUNT:               "Сумма платежа %s, пожалуйста, введите новую",
+		MESSAGE_INCORRECT_AMOUNT:               "Ожидается сумма, например 42,50",
+		MESSAGE_COMPUTED_AMOUNT:                "Сумма: %s = %s",
+		MESSAGE_BATCH_INCORRECT_LINE:           "ожидается \"<сумма> <категория>\"",
+		MESSAGE_BATCH_AMBIGUOUS_AMOUNT:         "разделитель в сумме неоднозначен",
+		MESSAGE_AMBIGUOUS_AMOUNT:               "Непонятно, отделяет ли разделитель в сумме дробную часть или тысячи. Пожалуйста, напишите сумму как %s или без разделителей",
+		MESSAGE_SUCCESS_EDIT_AMOUNT:            "Сумма платежа изменена на %s",
+
+		MESSAGE_INPUT_EXPORT_FORMAT:     "Пожалуйста, выберите формат файла",
+		MESSAGE_INCORRECT_EXPORT_FORMAT: "Неизвестный формат файла, пожалуйста, выберите формат из списка",
+		MESSAGE_INPUT_EXPORT_DATE_RANGE: "Пожалуйста, выберите период для выгрузки или введите две даты, например 2024-01-01 2024-03-31",
+		MESSAGE_FAILURE_EXPORT_CURRENCY: "В выписке OFX может быть только одна валюта, а платежи этого периода в %s. Пожалуйста, выберите период в одной валюте или другой формат",
+		MESSAGE_INCORRECT_DATE_RANGE:    "Не удалось разобрать период, ожидаются две даты в формате ГГГГ-ММ-ДД, например 2024-01-01 2024-03-31",
+		MESSAGE_OPTION_THIS_MONTH:       "Этот месяц",
+		MESSAGE_OPTION_LAST_MONTH:       "Прошлый месяц",
+		MESSAGE_OPTION_THIS_YEAR:        "Этот год",
+		MESSAGE_OPTION_ALL_TIME:         "Всё время",
+
+		MESSAGE_IMPORT_INSTRUCTIONS: `Чтобы загрузить банковскую выписку, отправьте её сюда файлом CSV, QIF или OFX.
+Для файлов CSV со строкой заголовков в первый раз бот спросит, в каких столбцах дата, сумма и описание, в следующий раз будут использованы те же столбцы.
+Платежи относятся к категориям по правилам из /listRules, затем к категории, название которой встречается в описании, а иначе к категории "` + uncategorizedCategoryName + `". Платежи, которые уже есть в таблице, пропускаются.
+Ничего не сохраняется, пока вы не подтвердите.`,
+		MESSAGE_FAILURE_UNKNOWN_DOCUMENT_FORMAT: "Неподдерживаемый формат файла, ожидается файл CSV, QIF или OFX",
+		MESSAGE_FAILURE_PARSING_STATEMENT:       "Не удалось прочитать выписку, ожидается файл CSV со строкой заголовков",
+		MESSAGE_INPUT_IMPORT_DATE_COLUMN:        "В каком столбце дата платежа?",
+		MESSAGE_INPUT_IMPORT_AMOUNT_COLUMN:      "В каком столбце сумма?",
+		MESSAGE_INPUT_IMPORT_DESCRIPTION_COLUMN: "В каком столбце описание?",
+		MESSAGE_INPUT_IMPORT_EXPENSES_SIGN:      "Показаны ли расходы в этой выписке отрицательными суммами?",
+		MESSAGE_INCORRECT_IMPORT_COLUMN:         "Такого столбца нет, пожалуйста, выберите столбец из списка",
+		MESSAGE_INCORRECT_IMPORT_DATE_COLUMN:    "Не удалось распознать даты в этом столбце, пожалуйста, выберите другой",
+		MESSAGE_FAILURE_EMPTY_IMPORT:            "В выписке не найдено ни одного платежа",
+		MESSAGE_FAILURE_ONLY_DUPLICATES_IMPORT:  "Все платежи из выписки уже есть в таблице",
+		MESSAGE_IMPORT_PREVIEW_MORE:             "...и ещё %d\n",
+		MESSAGE_CANCELLED_IMPORT:                "Загрузка отменена",
+		MESSAGE_OPTION_EXPENSES_NEGATIVE:        "Да, расходы отрицательные",
+		MESSAGE_OPTION_EXPENSES_POSITIVE:        "Нет, расходы положительные",
+
+		MESSAGE_INPUT_CATEGORY_NAME:     "Пожалуйста, введите название новой категории",
+		MESSAGE_SUCCESS_CREATE_CATEGORY: "Новая категория создана!",
+		MESSAGE_LIST_CATEGORIES_OUTRO:   "Чтобы добавить новые категории, нажмите /createCategory",
+
+		MESSAGE_LIST_RULES_OUTRO:         "Чтобы добавить новые правила, нажмите /addRule\nЧтобы изменить их порядок, нажмите /moveRule\nЧтобы удалить правило, нажмите /deleteRule",
+		MESSAGE_INPUT_RULE:               "Пожалуйста, введите правило, например\ndescription contains LIDL -> продукты\namount > 1000 and description matches /аренда/ -> жильё",
+		MESSAGE_INCORRECT_RULE:           "Не удалось разобрать правило: %s",
+		MESSAGE_SUCCESS_ADD_RULE:         "Новое правило добавлено, оно применяется после всех существующих",
+		MESSAGE_INPUT_RULE_POSITIONS:     "Пожалуйста, введите текущую и новую позицию правила, например \"3 1\", чтобы сделать третье правило первым",
+		MESSAGE_INCORRECT_RULE_POSITIONS: "Ожидаются две позиции правил из /listRules, например \"3 1\"",
+		MESSAGE_SUCCESS_MOVE_RULE:        "Правило перемещено",
+		MESSAGE_INPUT_RULE_POSITION:      "Пожалуйста, введите позицию правила, которое нужно удалить",
+		MESSAGE_INCORRECT_RULE_POSITION:  "На этой позиции нет правила, см. /listRules",
+		MESSAGE_SUCCESS_DELETE_RULE:      "Правило удалено",
+		MESSAGE_TEST_RULE_INTRO:          "Правило подходит под %d из %d платежей, %d из них сейчас в другой категории. Самые последние:",
+
+		MESSAGE_INPUT_SHARED_PAYMENT:       "Пожалуйста, введите общий платёж в виде \"<сумма> <категория>\", например \"120 продукты\"",
+		MESSAGE_INCORRECT_SHARED_PAYMENT:   "Ожидается \"<сумма> <категория>\", например \"120 продукты\"",
+		MESSAGE_INPUT_SHARED_PAYER:         "Кто заплатил?",
+		MESSAGE_INPUT_SPLIT_MODE:           "Как разделить платёж между участниками?",
+		MESSAGE_INCORRECT_SPLIT_MODE:       "Пожалуйста, выберите один из вариантов: поровну, по долям или точными суммами",
+		MESSAGE_INPUT_SPLIT_SHARES:         "Пожалуйста, введите доли парами \"<участник>:<доля>\", например \"me:2 123456:1\". Участники:\n%s",
+		MESSAGE_INPUT_SPLIT_AMOUNTS:        "Пожалуйста, введите суммы парами \"<участник>:<сумма>\", в сумме %s, например \"me:70 123456:50\". Участники:\n%s",
+		MESSAGE_INCORRECT_SPLIT:            "Не удалось разделить платёж: %s",
+		MESSAGE_SUCCESS_ADD_SHARED_PAYMENT: "Общий платёж добавлен, он разделён так:",
+		MESSAGE_LIST_BALANCES_INTRO:        "Балансы участников таблицы, положительные означают, что участнику должны:",
+		MESSAGE_LIST_BALANCES_OUTRO:        "Чтобы рассчитаться, нажмите /settle",
+		MESSAGE_NOTHING_TO_SETTLE:          "Все балансы уже сведены",
+		MESSAGE_SETTLE_INTRO:               "Эти переводы закрывают все долги:",
+		MESSAGE_SETTLE_OUTRO:               "Подтвердите, когда деньги будут переведены, чтобы записать расчёт",
+		MESSAGE_CANCELLED_SETTLE:           "Расчёт отменён",
+		MESSAGE_SUCCESS_SETTLE:             "Расчёт записан",
+		MESSAGE_OPTION_SPLIT_EQUALLY:       "Поровну",
+		MESSAGE_OPTION_SPLIT_BY_SHARES:     "По долям",
+		MESSAGE_OPTION_SPLIT_BY_AMOUNTS:    "Точными суммами",
+
+		MESSAGE_LIST_MEMBERS_OUTRO:    "Чтобы изменить роль участника, нажмите /setRole\nЧтобы удалить участника, нажмите /removeMember",
+		MESSAGE_INPUT_MEMBER:          "Пожалуйста, выберите участника",
+		MESSAGE_ROLE_VIEWER:           "наблюдатель",
+		MESSAGE_ROLE_EDITOR:           "редактор",
+		MESSAGE_ROLE_OWNER:            "владелец",
+		MESSAGE_INPUT_ROLE:            "Пожалуйста, выберите новую роль: редактор или наблюдатель",
+		MESSAGE_INCORRECT_MEMBER:      "Такой участник не найден, пожалуйста, выберите участника из списка",
+		MESSAGE_INCORRECT_ROLE:        "Неизвестная роль, ожидается редактор или наблюдатель",
+		MESSAGE_NO_OTHER_MEMBERS:      "В этой таблице нет других участников",
+		MESSAGE_SUCCESS_SET_ROLE:      "Роль участника изменена",
+		MESSAGE_SUCCESS_REMOVE_MEMBER: "Участник удалён из таблицы",
+
+		MESSAGE_INPUT_INVITE_ROLE:     "Пожалуйста, выберите роль, которую даёт приглашение: редактор или наблюдатель",
+		MESSAGE_INPUT_INVITE_USES:     "Пожалуйста, введите, сколько раз можно использовать приглашение",
+		MESSAGE_INCORRECT_INVITE_USES: "Число использований должно быть от 1 до 100",
+		MESSAGE_CREATED_INVITE:        "Новое приглашение создано! Поделитесь этой ссылкой:\n%s\n\nЧтобы вместо этого подключить к таблице группу, добавьте в неё бота по этой ссылке:\n%s\n\nРоль: %s\nИспользований: %d\nДействует до: %s",
+		MESSAGE_LIST_INVITES_OUTRO:    "Чтобы создать новое приглашение, нажмите /createInvite\nЧтобы отозвать приглашение, нажмите /revokeInvite",
+		MESSAGE_LIST_INVITES_ITEM:     "%2d. %s\n    Роль: %s, осталось использований: %d, действует до: %s\n\n",
+		MESSAGE_NO_INVITES:            "В этой таблице нет действующих приглашений",
+		MESSAGE_INPUT_INVITE_TOKEN:    "Пожалуйста, выберите приглашение, которое нужно отозвать",
+		MESSAGE_INCORRECT_INVITE:      "Это приглашение недействительно, истекло или уже использовано",
+		MESSAGE_SUCCESS_REVOKE_INVITE: "Приглашение отозвано",
+	},
+	plurals: map[string][]string{
+		MESSAGE_LIST_SHEETS_INTRO:       {"Вы участник следующей %d таблицы:", "Вы участник следующих %d таблиц:", "Вы участник следующих %d таблиц:"},
+		MESSAGE_SUCCESS_EXPORT:          {"Выгружен %d платёж", "Выгружено %d платежа", "Выгружено %d платежей"},
+		MESSAGE_IMPORT_PREVIEW_INTRO:    {"Будет загружен %d платёж, %d уже существующих будут пропущены, %d строк не удалось прочитать:", "Будет загружено %d платежа, %d уже существующих будут пропущены, %d строк не удалось прочитать:", "Будет загружено %d платежей, %d уже существующих будут пропущены, %d строк не удалось прочитать:"},
+		MESSAGE_SUCCESS_IMPORT:          {"Загружен %d платёж", "Загружено %d платежа", "Загружено %d платежей"},
+		MESSAGE_LIST_CATEGORIES_INTRO:   {"В этой таблице %d категория:", "В этой таблице %d категории:", "В этой таблице %d категорий:"},
+		MESSAGE_LIST_RULES_INTRO:        {"В этой таблице %d правило категоризации, применяется первое подходящее:", "В этой таблице %d правила категоризации, применяется первое подходящее:", "В этой таблице %d правил категоризации, применяется первое подходящее:"},
+		MESSAGE_LIST_MEMBERS_INTRO:      {"В этой таблице %d участник:", "В этой таблице %d участника:", "В этой таблице %d участников:"},
+		MESSAGE_SUCCESS_CREATE_PAYMENTS: {"Добавлен %d платёж:", "Добавлено %d платежа:", "Добавлено %d платежей:"},
+		MESSAGE_FAILURE_CREATE_PAYMENTS: {"Не удалось прочитать %d строку, поэтому ни один платёж не сохранён. Пожалуйста, исправьте её и отправьте сообщение снова:", "Не удалось прочитать %d строки, поэтому ни один платёж не сохранён. Пожалуйста, исправьте их и отправьте сообщение снова:", "Не удалось прочитать %d строк, поэтому ни один платёж не сохранён. Пожалуйста, исправьте их и отправьте сообщение снова:"},
+		MESSAGE_LIST_INVITES_INTRO:      {"В этой таблице %d действующее приглашение:", "В этой таблице %d действующих приглашения:", "В этой таблице %d действующих приглашений:"},
+	},
+	decimalSeparator:   ",",
+	thousandsSeparator: "\u00a0",
+	dateLayout:         "02.01.2006",
+	dateTimeLayout:     "02.01.2006 15:04",
+}
+
+// russianPluralForm picks between the forms for 1, 21, 31..., for 2-4, 22-24... and for all the other counts
+func russianPluralForm(n int) int {
+	if n < 0 {
+		n = -n
+	}
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return 0
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return 1
+	}
+	return 2
+}